@@ -0,0 +1,56 @@
+package jsn
+
+import "strconv"
+
+// AppendBool appends the JSON literal for v to dst and returns the extended
+// buffer.
+//
+// The Append functions format single values exactly as Marshal does, without
+// allocating anything besides growing dst. They are building blocks for
+// encoders on hot paths, such as structured loggers.
+func AppendBool(dst []byte, v bool) []byte {
+	return strconv.AppendBool(dst, v)
+}
+
+// AppendInt appends the JSON number for v to dst and returns the extended
+// buffer.
+func AppendInt(dst []byte, v int64) []byte {
+	return strconv.AppendInt(dst, v, 10)
+}
+
+// AppendUint appends the JSON number for v to dst and returns the extended
+// buffer.
+func AppendUint(dst []byte, v uint64) []byte {
+	return strconv.AppendUint(dst, v, 10)
+}
+
+// AppendFloat appends the JSON number for v to dst and returns the extended
+// buffer. The marshal options that affect numbers (FloatPrecision,
+// IntegralFloats, PreserveNumbers, NonFiniteFloats) are honored. Infinities
+// and NaN are rejected by default, dst is returned unchanged along with the
+// error.
+func AppendFloat(dst []byte, v float64, opts ...any) ([]byte, error) {
+	mo, err := parseMarshalOptions(opts)
+	if err != nil {
+		return dst, err
+	}
+	return appendFloat(dst, v, &mo)
+}
+
+// AppendString appends s as a quoted and escaped JSON string to dst and returns
+// the extended buffer. The opts are the marshal options, of which only
+// EscapeNonPrintable and EscapeNonASCII apply to strings, the others are
+// ignored.
+func AppendString(dst []byte, s string, opts ...any) []byte {
+	mo, _ := parseMarshalOptions(opts)
+	dst = append(dst, '"')
+	dst = appendEscaped(dst, s, &mo)
+	return append(dst, '"')
+}
+
+// Quote returns s as a quoted and escaped JSON string literal, exactly as
+// Marshal would write it, e.g. for templating or assembling JSON text by hand.
+// The opts are the marshal options, see AppendString.
+func Quote(s string, opts ...any) string {
+	return string(AppendString(make([]byte, 0, len(s)+2), s, opts...))
+}
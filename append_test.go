@@ -0,0 +1,139 @@
+package jsn
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAppend(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		opts []any
+		got  func(dst []byte) ([]byte, error)
+	}{
+		{name: "true", v: true, got: func(dst []byte) ([]byte, error) { return AppendBool(dst, true), nil }},
+		{name: "false", v: false, got: func(dst []byte) ([]byte, error) { return AppendBool(dst, false), nil }},
+		{name: "int", v: int64(-42), got: func(dst []byte) ([]byte, error) { return AppendInt(dst, -42), nil }},
+		{name: "min int", v: int64(math.MinInt64), got: func(dst []byte) ([]byte, error) { return AppendInt(dst, math.MinInt64), nil }},
+		{name: "uint", v: uint64(math.MaxUint64), got: func(dst []byte) ([]byte, error) { return AppendUint(dst, math.MaxUint64), nil }},
+		{name: "float", v: 3.14159265, got: func(dst []byte) ([]byte, error) { return AppendFloat(dst, 3.14159265) }},
+		{name: "float precision", v: 3.14159265, opts: []any{FloatPrecision{2}},
+			got: func(dst []byte) ([]byte, error) { return AppendFloat(dst, 3.14159265, FloatPrecision{2}) }},
+		{name: "integral float", v: 1e20, opts: []any{IntegralFloats{}},
+			got: func(dst []byte) ([]byte, error) { return AppendFloat(dst, 1e20, IntegralFloats{}) }},
+		{name: "shortest float", v: 0.1, opts: []any{PreserveNumbers{}},
+			got: func(dst []byte) ([]byte, error) { return AppendFloat(dst, 0.1, PreserveNumbers{}) }},
+		{name: "string", v: "a\"b\\c\n\x01\x1fé", got: func(dst []byte) ([]byte, error) { return AppendString(dst, "a\"b\\c\n\x01\x1fé"), nil }},
+		{name: "empty string", v: "", got: func(dst []byte) ([]byte, error) { return AppendString(dst, ""), nil }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := Marshal(tt.v, tt.opts...)
+			if err != nil {
+				t.Fatalf("Marshal() unexpected error = %v", err)
+			}
+			got, err := tt.got([]byte("prefix:"))
+			if err != nil {
+				t.Fatalf("Append unexpected error = %v", err)
+			}
+			if string(got) != "prefix:"+want {
+				t.Errorf("Append = %s, want prefix:%s", got, want)
+			}
+		})
+	}
+
+	dst := []byte("x")
+	if got, err := AppendFloat(dst, math.NaN()); err == nil || string(got) != "x" {
+		t.Errorf("AppendFloat(NaN) = %q, %v, want an error", got, err)
+	}
+	if got, err := AppendFloat(dst, math.Inf(-1), NonFiniteFloats{Mode: NonFiniteString}); err != nil || string(got) != `x"-Infinity"` {
+		t.Errorf("AppendFloat(-Inf) = %q, %v, want %q", got, err, `x"-Infinity"`)
+	}
+	if _, err := AppendFloat(nil, 1, FloatPrecision{-1}); err == nil {
+		t.Errorf("AppendFloat() with invalid precision did not fail")
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input string
+		opts  []any
+		want  string
+	}{
+		{input: "", want: `""`},
+		{input: "plain", want: `"plain"`},
+		{input: "a\"b\\c/d", want: `"a\"b\\c/d"`},
+		{input: "\b\f\n\r\t\x00\x1f", want: `"\b\f\n\r\t\u0000\u001f"`},
+		{input: "\x7f\u200b", want: "\"\x7f\u200b\""},
+		{input: "\x7f\u200b", opts: []any{EscapeNonPrintable{}}, want: `"\u007f\u200b"`},
+	}
+	for _, tt := range tests {
+		if got := Quote(tt.input, tt.opts...); got != tt.want {
+			t.Errorf("Quote(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+		if got, _ := Marshal(tt.input, tt.opts...); got != tt.want {
+			t.Errorf("Marshal(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestAppendAllocs(t *testing.T) {
+	buf := make([]byte, 0, 64)
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"AppendBool", func() { AppendBool(buf, true) }},
+		{"AppendInt", func() { AppendInt(buf, -1234567890) }},
+		{"AppendUint", func() { AppendUint(buf, 1234567890) }},
+		{"AppendFloat", func() { _, _ = AppendFloat(buf, 3.14159) }},
+		{"AppendFloat with options", func() { _, _ = AppendFloat(buf, 3.14159, FloatPrecision{3}, IntegralFloats{}) }},
+		{"AppendString", func() { AppendString(buf, "hello \"world\"\n") }},
+	}
+	for _, tt := range tests {
+		if n := testing.AllocsPerRun(100, tt.fn); n != 0 {
+			t.Errorf("%s allocates %v times per run, want 0", tt.name, n)
+		}
+	}
+}
+
+func BenchmarkAppendInt(b *testing.B) {
+	b.ReportAllocs()
+	buf := make([]byte, 0, 64)
+	for i := 0; i < b.N; i++ {
+		buf = AppendInt(buf[:0], int64(i))
+	}
+}
+
+func BenchmarkAppendFloat(b *testing.B) {
+	b.ReportAllocs()
+	buf := make([]byte, 0, 64)
+	for i := 0; i < b.N; i++ {
+		buf, _ = AppendFloat(buf[:0], float64(i)+0.5)
+	}
+}
+
+func BenchmarkAppendBool(b *testing.B) {
+	b.ReportAllocs()
+	buf := make([]byte, 0, 64)
+	for i := 0; i < b.N; i++ {
+		buf = AppendBool(buf[:0], i&1 == 0)
+	}
+}
+
+func BenchmarkAppendString(b *testing.B) {
+	b.ReportAllocs()
+	buf := make([]byte, 0, 64)
+	for i := 0; i < b.N; i++ {
+		buf = AppendString(buf[:0], "request \"id\"\tcompleted\n")
+	}
+}
+
+func BenchmarkMarshalInt(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = Marshal(i)
+	}
+}
@@ -0,0 +1,177 @@
+package jsn
+
+import (
+	"io"
+	"strconv"
+)
+
+// CopyValue reads exactly one JSON value from the scanner and writes it to w,
+// applying the marshal options (see Marshal) to the output.
+//
+// The value is transcoded in a single pass without building the intermediate
+// tree: insignificant whitespace is dropped and strings are decoded and
+// re-escaped by the writer. Numbers are copied verbatim to preserve their
+// exact source representation.
+//
+// Unless w is already buffered (a *bufio.Writer, *bytes.Buffer or
+// *strings.Builder), the output goes through a bufio.Writer that is flushed
+// before returning, so that each token does not cost a write to w. Output
+// produced before an error is flushed as well.
+func CopyValue(w io.Writer, s *Scanner, opts ...any) error {
+	mo, err := parseMarshalOptions(opts)
+	if err != nil {
+		return err
+	}
+	d := decorator{out: w, marshalOptions: mo}
+	flush := d.buffered()
+	err = copyValue(&d, s)
+	if ferr := flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// copyValue transcodes a single value from the scanner into the decorator
+func copyValue(d *decorator, s *Scanner) error {
+	s.skipWhitespace()
+
+	if s.IsEOF() {
+		return ErrUnexpectedEOF
+	}
+	if err := s.countValue(); err != nil {
+		return err
+	}
+
+	switch s.peek() {
+	case '{':
+		s.cur++
+		if err := s.enterContainer(); err != nil {
+			return err
+		}
+		d.objectBegin()
+		n := 0
+		var seen map[string]bool
+		s.skipWhitespace()
+		if !s.skipByte('}') {
+			for {
+				if err := s.checkMembers(n + 1); err != nil {
+					return err
+				}
+				s.skipWhitespace()
+				if s.IsEOF() {
+					return ErrUnexpectedEOF
+				}
+				key, err := s.parseKey()
+				if err != nil {
+					return err
+				}
+				if err = s.seenKey(&seen, key); err != nil {
+					return err
+				}
+
+				s.skipWhitespace()
+				if s.IsEOF() {
+					return ErrUnexpectedEOF
+				}
+				if !s.skipByte(':') {
+					return ErrUnexpectedToken
+				}
+
+				d.objectField(key, n == 0)
+				n++
+				if err = copyValue(d, s); err != nil {
+					return prependPath(err, "/"+escapePointerToken(key))
+				}
+
+				s.skipWhitespace()
+				if s.IsEOF() {
+					return ErrUnexpectedEOF
+				}
+				if s.skipByte('}') {
+					break
+				}
+				if !s.skipByte(',') {
+					return ErrUnexpectedToken
+				}
+			}
+		}
+		s.leaveContainer()
+		d.objectEnd(n == 0)
+
+	case '[':
+		s.cur++
+		if err := s.enterContainer(); err != nil {
+			return err
+		}
+		d.arrayBegin()
+		n := 0
+		s.skipWhitespace()
+		if !s.skipByte(']') {
+			for {
+				d.arrayElement(n == 0)
+				if err := copyValue(d, s); err != nil {
+					return prependPath(err, "/"+strconv.Itoa(n))
+				}
+				n++
+
+				s.skipWhitespace()
+				if s.IsEOF() {
+					return ErrUnexpectedEOF
+				}
+				if s.skipByte(']') {
+					break
+				}
+				if !s.skipByte(',') {
+					return ErrUnexpectedToken
+				}
+			}
+		}
+		s.leaveContainer()
+		d.arrayEnd(n == 0)
+
+	case '"':
+		str, err := s.parseString()
+		if err != nil {
+			return err
+		}
+		d.marshalString(str)
+
+	case 't':
+		if !s.skipSequence([]byte("true")) {
+			return ErrUnexpectedToken
+		}
+		d.marshalBool(true)
+
+	case 'f':
+		if !s.skipSequence([]byte("false")) {
+			return ErrUnexpectedToken
+		}
+		d.marshalBool(false)
+
+	case 'n':
+		if !s.skipSequence([]byte("null")) {
+			return ErrUnexpectedToken
+		}
+		d.marshalNull()
+
+	case '+', '.':
+		if s.flags&ScannerFlagAllowJSON5Numbers == 0 {
+			return s.unexpectedToken()
+		}
+		fallthrough
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		token, err := s.scanNumber()
+		if err != nil {
+			return err
+		}
+		if s.flags&ScannerFlagAllowLeadingZeros != 0 {
+			token = trimLeadingZeros(token)
+		}
+		d.writeNumber(token)
+
+	default:
+		return s.unexpectedToken()
+	}
+
+	return d.err
+}
@@ -0,0 +1,130 @@
+package jsn
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCopyValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    []any
+		want    string
+		wantErr error
+	}{
+		{name: "number verbatim", input: "1.50e+10", want: "1.50e+10"},
+		{name: "allowed leading zeros trimmed", input: `[012,-01,-00,00.5]`, opts: []any{ScannerFlagAllowLeadingZeros}, want: `[12,-1,-0,0.5]`},
+		{name: "string re-escaped", input: `"A\/é\n"`, want: `"A/é\n"`},
+		{name: "literals", input: `[true, false, null]`, want: `[true,false,null]`},
+		{name: "empty containers", input: `{ "a" : [ ], "b" : { } }`, want: `{"a":[],"b":{}}`},
+		{
+			name:  "nested structure",
+			input: "{\n  \"name\": \"John\",\n  \"orders\": [\n    {\"id\": \"A123\", \"total\": 50.00}\n  ]\n}",
+			want:  `{"name":"John","orders":[{"id":"A123","total":50.00}]}`,
+		},
+		{name: "key order preserved", input: `{"b":1,"a":2}`, want: `{"b":1,"a":2}`},
+
+		{name: "empty input", input: "", wantErr: ErrUnexpectedEOF},
+		{name: "unterminated array", input: "[1, 2", wantErr: ErrUnexpectedEOF},
+		{name: "unterminated object", input: `{"a": 1`, wantErr: ErrUnexpectedEOF},
+		{name: "missing colon", input: `{"a" 1}`, wantErr: ErrUnexpectedToken},
+		{name: "trailing comma", input: `[1,]`, wantErr: ErrUnexpectedToken},
+		{name: "invalid number", input: `[01]`, wantErr: ErrInvalidNumber},
+		{name: "invalid string", input: `["\x"]`, wantErr: ErrInvalidString},
+		{name: "invalid literal", input: `[tru]`, wantErr: ErrUnexpectedToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			s := NewScanner([]byte(tt.input), tt.opts...)
+			err := CopyValue(&sb, s)
+			if err == nil {
+				err = s.Finalize()
+			}
+			if err != tt.wantErr {
+				t.Errorf("CopyValue() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && sb.String() != tt.want {
+				t.Errorf("CopyValue() = %v, want %v", sb.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCopyValueSequence(t *testing.T) {
+	var sb strings.Builder
+	s := NewScanner([]byte(`{"a":1} [2] "three"`))
+	for !s.IsEOF() {
+		if err := CopyValue(&sb, s); err != nil {
+			t.Fatalf("CopyValue() unexpected error = %v", err)
+		}
+		sb.WriteString("\n")
+		s.skipWhitespace()
+	}
+	if want := "{\"a\":1}\n[2]\n\"three\"\n"; sb.String() != want {
+		t.Errorf("CopyValue() = %q, want %q", sb.String(), want)
+	}
+}
+
+func TestCopyValueErrors(t *testing.T) {
+	testErr := fmt.Errorf("test error")
+	err := CopyValue(&errorWriter{err: testErr}, NewScanner([]byte(`[1,2]`)))
+	if err != testErr {
+		t.Errorf("CopyValue() writer error = %v, want %v", err, testErr)
+	}
+
+	err = CopyValue(&strings.Builder{}, NewScanner([]byte(`1`)), FloatPrecision{Precision: -1})
+	if err == nil {
+		t.Error("CopyValue() expected error for invalid option, got nil")
+	}
+}
+
+// writeCounter counts the writes that reach it
+type writeCounter struct {
+	strings.Builder
+	writes int
+}
+
+func (w *writeCounter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Builder.Write(p)
+}
+
+func TestCopyValueBuffering(t *testing.T) {
+	input := `{"a":[1,2,3],"b":{"c":"d"},"e":null}`
+
+	var cw writeCounter
+	if err := CopyValue(&cw, NewScannerString(input)); err != nil {
+		t.Fatalf("CopyValue() unexpected error = %v", err)
+	}
+	if cw.String() != input {
+		t.Errorf("CopyValue() = %s, want %s", cw.String(), input)
+	}
+	if cw.writes != 1 {
+		t.Errorf("CopyValue() made %d writes, want 1", cw.writes)
+	}
+
+	// a bufio.Writer is used as is and left for the caller to flush
+	var sb strings.Builder
+	bw := bufio.NewWriter(&sb)
+	if err := CopyValue(bw, NewScannerString(input)); err != nil {
+		t.Fatalf("CopyValue() unexpected error = %v", err)
+	}
+	if sb.Len() != 0 || bw.Buffered() != len(input) {
+		t.Errorf("CopyValue() wrote %d bytes through and buffered %d", sb.Len(), bw.Buffered())
+	}
+
+	// output produced before a syntax error is flushed
+	cw = writeCounter{}
+	if err := CopyValue(&cw, NewScannerString(`[1,2,x]`)); err != ErrUnexpectedToken {
+		t.Errorf("CopyValue() error = %v, want %v", err, ErrUnexpectedToken)
+	}
+	if cw.String() != "[1,2," {
+		t.Errorf("CopyValue() = %s, want [1,2,", cw.String())
+	}
+}
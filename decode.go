@@ -0,0 +1,856 @@
+package jsn
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidDecodeTarget = errors.New("decode target must be a non-nil pointer")
+	ErrTypeMismatch        = errors.New("type mismatch")
+	ErrLengthMismatch      = errors.New("length mismatch")
+	ErrUnknownType         = errors.New("unknown type discriminator")
+)
+
+// Unmarshaler is implemented by types that can decode themselves from a value
+// of the generic tree produced by ReadValue (nil, bool, float64, string, []any
+// or map[string]any).
+type Unmarshaler interface {
+	UnmarshalJSN(v any) error
+}
+
+// DecodeError is returned by Decode when a tree value cannot be converted into
+// the target Go type.
+type DecodeError struct {
+	Path  string       // JSON Pointer to the offending value, empty for the root
+	Value any          // The offending tree value
+	Type  reflect.Type // The Go type the value was decoded into
+	Err   error        // The cause, e.g. ErrTypeMismatch or ErrNumberNotInteger
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("cannot decode %s at %q into %v: %v", treeKindName(e.Value), e.Path, e.Type, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Decode populates the value pointed to by v from a tree produced by ReadValue,
+// possibly merged or patched before binding.
+//
+// The mapping mirrors ReadValue:
+//   - null sets the target to its zero value
+//   - booleans decode into bool kinds
+//   - numbers (float64 or Number) decode into float kinds, and into integer
+//     kinds if they are integral and within range
+//   - strings decode into string kinds, []byte and [N]byte of the same
+//     length, and into types implementing encoding.TextUnmarshaler
+//   - arrays decode into slices, and into Go arrays of the same length
+//   - objects decode into maps with string keys and into structs, and into
+//     complex kinds from their "real" and "imag" members (see ComplexAsObject),
+//     missing members are zero
+//   - any value decodes into an empty interface as is
+//
+// Struct fields are matched by the name given in the `jsn` tag, or by the Go
+// field name when there is no tag. Fields tagged with `jsn:"-"` and
+// unexported fields are ignored, as are object members without a matching
+// field. Fields of embedded structs are promoted into the parent. Numeric and
+// bool fields tagged with the string modifier, e.g. `jsn:"id,string"`, also
+// accept their value quoted as a string, as written by Quoted. As in
+// encoding/json, the modifier does not apply to the elements of slices,
+// arrays and maps. time.Time fields tagged with the unix or unixmilli
+// modifier, e.g. `jsn:"ts,unix"`, also accept a Unix timestamp in seconds or
+// milliseconds, see UnixTime.
+//
+// Types implementing Unmarshaler receive the tree value as is. Otherwise, types
+// implementing json.Unmarshaler receive the value marshaled back into compact
+// JSON text (null included), numbers read with ScannerFlagUseNumber keep their
+// source text. This lets types with existing encoding/json decoding logic,
+// json.RawMessage among them, work unchanged. encoding.TextUnmarshaler comes
+// last and is only used for strings.
+//
+// Supported options:
+//   - TypeDiscriminator{Field: "type"} - decode objects into interface values
+//     by the types registered with RegisterType
+//   - KeyTransform(fn) - transform object keys before they are stored in maps
+//     or matched against struct fields
+//   - RejectDuplicateKeys{} - fail with ErrDuplicateKey when keys collide after
+//     KeyTransform
+//   - CaseInsensitiveFields{} - match object keys to struct fields ignoring
+//     case, see CaseInsensitiveFields for the precedence rules
+//   - StringNumbers{} - accept numbers quoted as strings for numeric targets,
+//     as the `jsn:"name,string"` tag modifier does for a single field
+//   - UnixTime{} - decode time.Time from Unix timestamps in seconds, or in
+//     milliseconds with UnixTime{Millis: true}
+func Decode(tree any, v any, opts ...any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return ErrInvalidDecodeTarget
+	}
+	d := decoder{decodeOptions: parseDecodeOptions(opts)}
+	return d.decodeValue("", tree, rv.Elem())
+}
+
+// TypeDiscriminator is a Decode option that enables polymorphic decoding of
+// objects into interface values. The string member named Field (default
+// "type") selects a concrete type registered with RegisterType, the object is
+// decoded into a value created by its factory, which must be assignable to the
+// interface.
+//
+// Objects without the member decode into empty interfaces as generic trees,
+// unknown discriminator values fail with ErrUnknownType.
+type TypeDiscriminator struct {
+	Field string
+}
+
+// StringNumbers is a Decode option that accepts strings holding a JSON number,
+// e.g. {"id":"12345"} from JavaScript producers, for integer, float and Number
+// targets, as well as plain numbers. The string must be a valid JSON number
+// without surrounding whitespace, other strings fail with ErrInvalidNumber.
+// The `jsn:"name,string"` tag modifier enables the same for a single field.
+type StringNumbers struct{}
+
+// CaseInsensitiveFields is a Decode option that matches object keys to struct
+// field names case-insensitively (using Unicode case folding), like
+// encoding/json does, e.g. the key "Name" fills a field tagged `jsn:"name"`.
+//
+// Exact matches always take precedence: a field takes the member whose key
+// equals its name if there is one, and a key that equals the name of some
+// field is never used for any other field. Every key fills at most one field,
+// the remaining keys are considered in sorted order and each one fills the
+// first field (in declaration order) that matches it and is not filled yet.
+type CaseInsensitiveFields struct{}
+
+type decodeOptions struct {
+	discriminator   string       // member that selects registered types, empty if disabled
+	transformKey    KeyTransform // optional object key transformation
+	rejectDupKeys   bool         // fail when transformed keys collide
+	caseInsensitive bool         // match struct fields ignoring case
+	stringNums      bool         // accept numbers quoted as strings
+	unixTime        unixUnit     // decode time.Time from Unix timestamps
+}
+
+func parseDecodeOptions(opts []any) (do decodeOptions) {
+	for _, opt := range opts {
+		switch v := opt.(type) {
+		case TypeDiscriminator:
+			do.discriminator = v.Field
+			if do.discriminator == "" {
+				do.discriminator = "type"
+			}
+		case KeyTransform:
+			do.transformKey = v
+		case func(string) string:
+			do.transformKey = v
+		case RejectDuplicateKeys:
+			do.rejectDupKeys = true
+		case CaseInsensitiveFields:
+			do.caseInsensitive = true
+		case StringNumbers:
+			do.stringNums = true
+		case UnixTime:
+			do.unixTime = v.unit()
+		}
+	}
+	return
+}
+
+var (
+	typeRegistryMu sync.RWMutex
+	typeRegistry   = map[string]func() any{}
+)
+
+// RegisterType registers a factory for the concrete type selected by the given
+// discriminator value, see TypeDiscriminator. The factory typically returns a
+// pointer to a new zero value, e.g. func() any { return &Created{} }.
+//
+// RegisterType is meant to be called during initialization and panics if the
+// discriminator is already registered.
+func RegisterType(discriminator string, factory func() any) {
+	typeRegistryMu.Lock()
+	defer typeRegistryMu.Unlock()
+	if _, ok := typeRegistry[discriminator]; ok {
+		panic(fmt.Sprintf("jsn: type discriminator %q registered twice", discriminator))
+	}
+	typeRegistry[discriminator] = factory
+}
+
+func lookupType(discriminator string) func() any {
+	typeRegistryMu.RLock()
+	defer typeRegistryMu.RUnlock()
+	return typeRegistry[discriminator]
+}
+
+type decoder struct {
+	decodeOptions
+	quoted bool     // decoding a field with the string tag modifier
+	unix   unixUnit // decoding a field with the unix or unixmilli tag modifier
+}
+
+func (d *decoder) decodeValue(path string, src any, dst reflect.Value) error {
+	if dst.Type() == timeType && (d.unix != unixNone || d.unixTime != unixNone) {
+		if ok, err := d.decodeUnixTime(src, dst); ok || err != nil {
+			if err != nil {
+				return &DecodeError{Path: path, Value: src, Type: dst.Type(), Err: err}
+			}
+			return nil
+		}
+	}
+	if dst.CanAddr() {
+		pv := dst.Addr()
+		if pv.Type().Implements(unmarshalerType) {
+			if err := pv.Interface().(Unmarshaler).UnmarshalJSN(src); err != nil {
+				return &DecodeError{Path: path, Value: src, Type: dst.Type(), Err: err}
+			}
+			return nil
+		}
+		if pv.Type().Implements(jsonUnmarshalerType) {
+			if err := unmarshalJSON(src, pv.Interface().(json.Unmarshaler)); err != nil {
+				return &DecodeError{Path: path, Value: src, Type: dst.Type(), Err: err}
+			}
+			return nil
+		}
+		if str, ok := src.(string); ok && pv.Type().Implements(textUnmarshalerType) {
+			if err := pv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(str)); err != nil {
+				return &DecodeError{Path: path, Value: src, Type: dst.Type(), Err: err}
+			}
+			return nil
+		}
+	}
+
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	mismatch := func() error {
+		return &DecodeError{Path: path, Value: src, Type: dst.Type(), Err: ErrTypeMismatch}
+	}
+
+	if str, ok := src.(string); ok && (d.stringNums || d.quoted) && isNumberKind(dst) {
+		if !isValidNumber(str) {
+			return &DecodeError{Path: path, Value: src, Type: dst.Type(), Err: ErrInvalidNumber}
+		}
+		src = Number(str)
+	}
+	if str, ok := src.(string); ok && d.quoted && isBoolKind(dst) {
+		switch str {
+		case "true":
+			src = true
+		case "false":
+			src = false
+		default:
+			return mismatch()
+		}
+	}
+
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return d.decodeValue(path, src, dst.Elem())
+
+	case reflect.Interface:
+		if d.discriminator != "" {
+			if obj, ok := src.(map[string]any); ok {
+				if disc, ok := obj[d.discriminator]; ok {
+					return d.decodeTyped(path, obj, disc, dst)
+				}
+			}
+		}
+		sv := reflect.ValueOf(src)
+		if !sv.Type().AssignableTo(dst.Type()) {
+			return mismatch()
+		}
+		dst.Set(sv)
+		return nil
+
+	case reflect.Bool:
+		b, ok := src.(bool)
+		if !ok {
+			return mismatch()
+		}
+		dst.SetBool(b)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := treeInt(src)
+		if err == nil && dst.OverflowInt(i) {
+			err = ErrNumericValueOutOfRange
+		}
+		if err != nil {
+			return &DecodeError{Path: path, Value: src, Type: dst.Type(), Err: err}
+		}
+		dst.SetInt(i)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := treeUint(src)
+		if err == nil && dst.OverflowUint(u) {
+			err = ErrNumericValueOutOfRange
+		}
+		if err != nil {
+			return &DecodeError{Path: path, Value: src, Type: dst.Type(), Err: err}
+		}
+		dst.SetUint(u)
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		f, err := treeFloat(src)
+		if err == nil && dst.OverflowFloat(f) {
+			err = ErrNumericValueOutOfRange
+		}
+		if err != nil {
+			return &DecodeError{Path: path, Value: src, Type: dst.Type(), Err: err}
+		}
+		dst.SetFloat(f)
+		return nil
+
+	case reflect.Complex64, reflect.Complex128:
+		obj, ok := src.(map[string]any)
+		if !ok {
+			return mismatch()
+		}
+		var parts [2]float64
+		for i, key := range [2]string{"real", "imag"} {
+			v, ok := obj[key]
+			if !ok {
+				continue
+			}
+			f, err := treeFloat(v)
+			if err != nil {
+				return &DecodeError{Path: path + "/" + key, Value: v, Type: dst.Type(), Err: err}
+			}
+			parts[i] = f
+		}
+		c := complex(parts[0], parts[1])
+		if dst.OverflowComplex(c) {
+			return &DecodeError{Path: path, Value: src, Type: dst.Type(), Err: ErrNumericValueOutOfRange}
+		}
+		dst.SetComplex(c)
+		return nil
+
+	case reflect.String:
+		if dst.Type() == numberType {
+			switch v := src.(type) {
+			case Number:
+				dst.SetString(string(v))
+				return nil
+			case float64:
+				dst.SetString(strconv.FormatFloat(v, 'g', -1, 64))
+				return nil
+			}
+		}
+		str, ok := src.(string)
+		if !ok {
+			return mismatch()
+		}
+		dst.SetString(str)
+		return nil
+
+	case reflect.Slice:
+		if str, ok := src.(string); ok && dst.Type().Elem().Kind() == reflect.Uint8 {
+			sv := reflect.MakeSlice(dst.Type(), len(str), len(str))
+			copyBytes(sv, str)
+			dst.Set(sv)
+			return nil
+		}
+		arr, ok := src.([]any)
+		if !ok {
+			return mismatch()
+		}
+		ed := d.elems()
+		sv := reflect.MakeSlice(dst.Type(), len(arr), len(arr))
+		for i, elem := range arr {
+			if err := ed.decodeValue(indexPath(path, i), elem, sv.Index(i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(sv)
+		return nil
+
+	case reflect.Array:
+		if str, ok := src.(string); ok && dst.Type().Elem().Kind() == reflect.Uint8 {
+			if len(str) != dst.Len() {
+				return &DecodeError{Path: path, Value: src, Type: dst.Type(), Err: ErrLengthMismatch}
+			}
+			copyBytes(dst, str)
+			return nil
+		}
+		arr, ok := src.([]any)
+		if !ok {
+			return mismatch()
+		}
+		if len(arr) != dst.Len() {
+			return &DecodeError{Path: path, Value: src, Type: dst.Type(), Err: ErrLengthMismatch}
+		}
+		ed := d.elems()
+		for i, elem := range arr {
+			if err := ed.decodeValue(indexPath(path, i), elem, dst.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		obj, ok := src.(map[string]any)
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			return mismatch()
+		}
+		obj, err := d.transformKeys(path, obj, dst.Type())
+		if err != nil {
+			return err
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(dst.Type(), len(obj)))
+		}
+		kt, et := dst.Type().Key(), dst.Type().Elem()
+		ed := d.elems()
+		for k, v := range obj {
+			ev := reflect.New(et).Elem()
+			if err := ed.decodeValue(path+"/"+escapePointerToken(k), v, ev); err != nil {
+				return err
+			}
+			dst.SetMapIndex(reflect.ValueOf(k).Convert(kt), ev)
+		}
+		return nil
+
+	case reflect.Struct:
+		obj, ok := src.(map[string]any)
+		if !ok {
+			return mismatch()
+		}
+		obj, err := d.transformKeys(path, obj, dst.Type())
+		if err != nil {
+			return err
+		}
+		fields := structFields(dst.Type())
+		var folded map[int]string
+		if d.caseInsensitive {
+			folded = foldMembers(obj, fields)
+		}
+		for i, f := range fields {
+			key := f.name
+			v, ok := obj[key]
+			if !ok && folded != nil {
+				if key, ok = folded[i]; ok {
+					v = obj[key]
+				}
+			}
+			if !ok {
+				continue
+			}
+			fv, err := fieldByIndex(dst, f.index)
+			if err != nil {
+				return &DecodeError{Path: path, Value: src, Type: dst.Type(), Err: err}
+			}
+			fd := d
+			if f.asString != d.quoted || f.unix != d.unix {
+				fd = &decoder{decodeOptions: d.decodeOptions, quoted: f.asString, unix: f.unix}
+			}
+			if err := fd.decodeValue(path+"/"+escapePointerToken(key), v, fv); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return mismatch()
+}
+
+// elems returns the decoder for the elements of slices, arrays and maps, the
+// string tag modifier applies to the field itself only, as in encoding/json
+func (d *decoder) elems() *decoder {
+	if !d.quoted {
+		return d
+	}
+	return &decoder{decodeOptions: d.decodeOptions, unix: d.unix}
+}
+
+// isNumberKind reports whether dst, or the value it points to, takes numbers,
+// see StringNumbers
+func isNumberKind(dst reflect.Value) bool {
+	t := dst.Type()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return t == numberType
+}
+
+// isBoolKind reports whether dst, or the value it points to, is a bool kind
+func isBoolKind(dst reflect.Value) bool {
+	t := dst.Type()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Bool
+}
+
+// unmarshalJSON passes a tree value to a json.Unmarshaler as JSON text,
+// marshaled with PreserveNumbers so that Number values keep their source text
+func unmarshalJSON(src any, u json.Unmarshaler) error {
+	text, err := Marshal(src, PreserveNumbers{})
+	if err != nil {
+		return err
+	}
+	return u.UnmarshalJSON([]byte(text))
+}
+
+// foldMembers assigns the object keys that are not the exact name of any field
+// to the fields without an exact match, comparing case-insensitively. Keys are
+// considered in sorted order, each one is assigned to the first free matching
+// field. The result maps field indices to keys.
+func foldMembers(obj map[string]any, fields []structField) map[int]string {
+	var keys []string
+	for k := range obj {
+		exact := false
+		for _, f := range fields {
+			if f.name == k {
+				exact = true
+				break
+			}
+		}
+		if !exact {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+
+	folded := map[int]string{}
+	for _, k := range keys {
+		for i, f := range fields {
+			if _, taken := folded[i]; taken || !strings.EqualFold(k, f.name) {
+				continue
+			}
+			if _, exact := obj[f.name]; exact {
+				continue
+			}
+			folded[i] = k
+			break
+		}
+	}
+	return folded
+}
+
+// decodeTyped decodes an object into a new value of the type registered for
+// its discriminator and stores it in the interface dst
+func (d *decoder) decodeTyped(path string, obj map[string]any, disc any, dst reflect.Value) error {
+	name, ok := disc.(string)
+	if !ok {
+		return &DecodeError{Path: path + "/" + escapePointerToken(d.discriminator), Value: disc, Type: dst.Type(), Err: ErrTypeMismatch}
+	}
+	factory := lookupType(name)
+	if factory == nil {
+		return &DecodeError{Path: path + "/" + escapePointerToken(d.discriminator), Value: disc, Type: dst.Type(), Err: ErrUnknownType}
+	}
+	tv := reflect.ValueOf(factory())
+	if !tv.IsValid() || !tv.Type().AssignableTo(dst.Type()) {
+		return &DecodeError{Path: path, Value: obj, Type: dst.Type(), Err: ErrTypeMismatch}
+	}
+	if tv.Kind() == reflect.Ptr && !tv.IsNil() {
+		if err := d.decodeValue(path, obj, tv.Elem()); err != nil {
+			return err
+		}
+	} else {
+		// non-pointer values are decoded through an addressable copy
+		cv := reflect.New(tv.Type()).Elem()
+		cv.Set(tv)
+		if err := d.decodeValue(path, obj, cv); err != nil {
+			return err
+		}
+		tv = cv
+	}
+	dst.Set(tv)
+	return nil
+}
+
+// treeFloat converts a tree number (float64 or Number) to float64
+func treeFloat(src any) (float64, error) {
+	switch v := src.(type) {
+	case float64:
+		return v, nil
+	case Number:
+		f, err := v.Float64()
+		if err != nil {
+			if err.(*strconv.NumError).Err == strconv.ErrRange {
+				return 0, ErrNumericValueOutOfRange
+			}
+			return 0, ErrInvalidNumber
+		}
+		return f, nil
+	}
+	return 0, ErrTypeMismatch
+}
+
+// treeInt converts an integral tree number to int64, Number values are
+// converted from their text so that large integers do not lose precision
+func treeInt(src any) (int64, error) {
+	if n, ok := src.(Number); ok {
+		i, err := strconv.ParseInt(string(n), 10, 64)
+		if err == nil {
+			return i, nil
+		}
+		if err.(*strconv.NumError).Err == strconv.ErrRange {
+			return 0, ErrNumericValueOutOfRange
+		}
+		// a fraction or an exponent, e.g. 1.0 or 1e3
+	}
+	f, err := treeFloat(src)
+	if err != nil {
+		return 0, err
+	}
+	if f != math.Trunc(f) {
+		return 0, ErrNumberNotInteger
+	}
+	if f < math.MinInt64 || f >= -math.MinInt64 {
+		return 0, ErrNumericValueOutOfRange
+	}
+	return int64(f), nil
+}
+
+// treeUint converts a non-negative integral tree number to uint64, see treeInt
+func treeUint(src any) (uint64, error) {
+	if n, ok := src.(Number); ok {
+		u, err := strconv.ParseUint(string(n), 10, 64)
+		if err == nil {
+			return u, nil
+		}
+		if err.(*strconv.NumError).Err == strconv.ErrRange {
+			return 0, ErrNumericValueOutOfRange
+		}
+	}
+	f, err := treeFloat(src)
+	if err != nil {
+		return 0, err
+	}
+	if f != math.Trunc(f) {
+		return 0, ErrNumberNotInteger
+	}
+	if f < 0 || f >= 2*-math.MinInt64 {
+		return 0, ErrNumericValueOutOfRange
+	}
+	return uint64(f), nil
+}
+
+// transformKeys returns obj with KeyTransform applied to its keys, colliding
+// members are resolved in the order of their original keys
+func (d *decoder) transformKeys(path string, obj map[string]any, typ reflect.Type) (map[string]any, error) {
+	if d.transformKey == nil {
+		return obj, nil
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	m := make(map[string]any, len(obj))
+	for _, k := range keys {
+		tk := d.transformKey(k)
+		if _, dup := m[tk]; dup && d.rejectDupKeys {
+			return nil, &DecodeError{Path: path + "/" + escapePointerToken(k), Value: obj[k], Type: typ, Err: ErrDuplicateKey}
+		}
+		m[tk] = obj[k]
+	}
+	return m, nil
+}
+
+// copyBytes copies a string into a slice or array of uint8 kind
+func copyBytes(dst reflect.Value, str string) {
+	for i := 0; i < len(str); i++ {
+		dst.Index(i).SetUint(uint64(str[i]))
+	}
+}
+
+func indexPath(path string, i int) string {
+	return path + "/" + strconv.Itoa(i)
+}
+
+// fieldByIndex returns the nested struct field, allocating embedded pointers
+// along the way
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cannot set embedded pointer to unexported struct %v", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+// decodeUnixTime decodes a number into a time.Time as a Unix timestamp, see
+// UnixTime, and reports whether src was a number
+func (d *decoder) decodeUnixTime(src any, dst reflect.Value) (bool, error) {
+	var text string
+	switch v := src.(type) {
+	case float64:
+		text = strconv.FormatFloat(v, 'g', -1, 64)
+	case Number:
+		text = string(v)
+	default:
+		return false, nil
+	}
+	var r big.Rat
+	if _, ok := r.SetString(text); !ok {
+		return true, ErrInvalidNumber
+	}
+	unit := d.unix
+	if unit == unixNone {
+		unit = d.unixTime
+	}
+	// nanoseconds, rounded to the nearest
+	scale := big.NewRat(1e9, 1)
+	if unit == unixMillis {
+		scale.SetInt64(1e6)
+	}
+	r.Mul(&r, scale)
+	ns, rem := new(big.Int).DivMod(r.Num(), r.Denom(), new(big.Int))
+	if rem.Lsh(rem, 1).Cmp(r.Denom()) >= 0 {
+		ns.Add(ns, big.NewInt(1))
+	}
+	sec, nsec := ns.DivMod(ns, big.NewInt(1e9), new(big.Int))
+	if !sec.IsInt64() {
+		return true, ErrNumericValueOutOfRange
+	}
+	dst.Set(reflect.ValueOf(time.Unix(sec.Int64(), nsec.Int64()).UTC()))
+	return true, nil
+}
+
+// structField describes a struct field that can be decoded
+type structField struct {
+	name     string   // member name in the JSON object
+	index    []int    // index sequence for reflect.Value.FieldByIndex
+	asString bool     // tagged with the string modifier, see Quoted
+	unix     unixUnit // tagged with the unix or unixmilli modifier, see UnixTime
+}
+
+var structFieldsCache sync.Map // map[reflect.Type][]structField
+
+// structFields returns the decodable fields of a struct type, fields of
+// embedded structs are promoted unless shadowed by a field at a shallower depth
+func structFields(typ reflect.Type) []structField {
+	if cached, ok := structFieldsCache.Load(typ); ok {
+		return cached.([]structField)
+	}
+
+	var fields []structField
+	seen := map[string]bool{}
+	type level struct {
+		typ   reflect.Type
+		index []int
+	}
+	current := []level{{typ: typ}}
+	visited := map[reflect.Type]bool{}
+	for len(current) > 0 {
+		var nextLevel []level
+		names := map[string]int{} // name occurrences at this depth
+		var found []structField
+		for _, l := range current {
+			if visited[l.typ] {
+				continue
+			}
+			visited[l.typ] = true
+			for i, n := 0, l.typ.NumField(); i < n; i++ {
+				sf := l.typ.Field(i)
+				tag := sf.Tag.Get("jsn")
+				if tag == "-" {
+					continue
+				}
+				index := append(append([]int(nil), l.index...), i)
+				name, mods, _ := strings.Cut(tag, ",")
+
+				if sf.Anonymous && name == "" {
+					ft := sf.Type
+					if ft.Kind() == reflect.Ptr {
+						ft = ft.Elem()
+					}
+					if ft.Kind() == reflect.Struct {
+						nextLevel = append(nextLevel, level{typ: ft, index: index})
+						continue
+					}
+				}
+				if !sf.IsExported() {
+					continue
+				}
+				if name == "" {
+					name = sf.Name
+				}
+				names[name]++
+				f := structField{name: name, index: index, asString: hasTagModifier(mods, "string")}
+				if hasTagModifier(mods, "unix") {
+					f.unix = unixSeconds
+				} else if hasTagModifier(mods, "unixmilli") {
+					f.unix = unixMillis
+				}
+				found = append(found, f)
+			}
+		}
+		for _, f := range found {
+			// ambiguous names at the same depth are dropped, like encoding/json
+			if seen[f.name] || names[f.name] > 1 {
+				continue
+			}
+			fields = append(fields, f)
+		}
+		for name := range names {
+			seen[name] = true
+		}
+		current = nextLevel
+	}
+
+	structFieldsCache.Store(typ, fields)
+	return fields
+}
+
+// hasTagModifier reports whether the comma-separated modifiers of a struct tag
+// include mod
+func hasTagModifier(mods, mod string) bool {
+	for mods != "" {
+		var m string
+		m, mods, _ = strings.Cut(mods, ",")
+		if m == mod {
+			return true
+		}
+	}
+	return false
+}
+
+// treeKindName returns the JSON kind of a tree value for error messages
+func treeKindName(v any) string {
+	if k := KindOf(v); k != KindInvalid {
+		return k.String()
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+var (
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
@@ -0,0 +1,753 @@
+package jsn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type decodeAddress struct {
+	City string `jsn:"city"`
+	Zip  string `jsn:"zip"`
+}
+
+type decodeBase struct {
+	ID      int64 `jsn:"id"`
+	Created time.Time
+}
+
+type decodePerson struct {
+	decodeBase
+	Name     string         `jsn:"name"`
+	Age      uint8          `jsn:"age"`
+	Score    float32        `jsn:"score"`
+	Active   bool           `jsn:"active"`
+	Tags     []string       `jsn:"tags"`
+	Address  *decodeAddress `jsn:"address"`
+	Extra    map[string]any `jsn:"extra"`
+	Counts   map[string]int `jsn:"counts"`
+	Raw      any            `jsn:"raw"`
+	Ignored  string         `jsn:"-"`
+	Untagged string
+	private  string
+}
+
+type upperString string
+
+func (u *upperString) UnmarshalJSN(v any) error {
+	s, ok := v.(string)
+	if !ok {
+		return errors.New("expected a string")
+	}
+	*u = upperString(strings.ToUpper(s))
+	return nil
+}
+
+func TestDecode(t *testing.T) {
+	input := `{
+		"id": 9007199254740991,
+		"Created": "2024-01-02T03:04:05Z",
+		"name": "John",
+		"age": 30,
+		"score": 4.5,
+		"active": true,
+		"tags": ["a", "b"],
+		"address": {"city": "New York", "zip": "10001", "unknown": 1},
+		"extra": {"nested": [1, {"x": null}]},
+		"counts": {"x": 1, "y": 2},
+		"raw": [1, "two"],
+		"Ignored": "nope",
+		"-": "nope",
+		"Untagged": "yes",
+		"private": "nope"
+	}`
+
+	want := decodePerson{
+		decodeBase: decodeBase{ID: 9007199254740991, Created: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		Name:       "John",
+		Age:        30,
+		Score:      4.5,
+		Active:     true,
+		Tags:       []string{"a", "b"},
+		Address:    &decodeAddress{City: "New York", Zip: "10001"},
+		Extra:      map[string]any{"nested": []any{float64(1), map[string]any{"x": nil}}},
+		Counts:     map[string]int{"x": 1, "y": 2},
+		Raw:        []any{float64(1), "two"},
+		Untagged:   "yes",
+	}
+
+	tree, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("Parse() unexpected error = %v", err)
+	}
+	var got decodePerson
+	if err := Decode(tree, &got); err != nil {
+		t.Fatalf("Decode() unexpected error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeValues(t *testing.T) {
+	type namedByte uint8
+
+	tests := []struct {
+		name   string
+		input  string
+		target any // pointer to a zero value of the target type
+		want   any
+	}{
+		{name: "int", input: "-42", target: new(int), want: -42},
+		{name: "uint16", input: "65535", target: new(uint16), want: uint16(65535)},
+		{name: "float64", input: "1.5", target: new(float64), want: 1.5},
+		{name: "string", input: `"hi"`, target: new(string), want: "hi"},
+		{name: "null into pointer", input: "null", target: func() **int { v := 1; p := &v; return &p }(), want: (*int)(nil)},
+		{name: "null into int", input: "null", target: func() *int { v := 1; return &v }(), want: 0},
+		{name: "pointer", input: "7", target: new(*int), want: func() *int { v := 7; return &v }()},
+		{name: "any", input: `{"a":[true]}`, target: new(any), want: map[string]any{"a": []any{true}}},
+		{name: "slice", input: "[1,2,3]", target: new([]int), want: []int{1, 2, 3}},
+		{name: "empty slice", input: "[]", target: new([]int), want: []int{}},
+		{name: "nested slices", input: "[[1],[2,3]]", target: new([][]int), want: [][]int{{1}, {2, 3}}},
+		{name: "array", input: `["a","b"]`, target: new([2]string), want: [2]string{"a", "b"}},
+		{name: "bytes", input: `"abc"`, target: new([]byte), want: []byte("abc")},
+		{name: "named bytes", input: `"ab"`, target: new([]namedByte), want: []namedByte{'a', 'b'}},
+		{name: "byte array", input: `"abcd"`, target: new([4]byte), want: [4]byte{'a', 'b', 'c', 'd'}},
+		{name: "map", input: `{"a":"x"}`, target: new(map[string]string), want: map[string]string{"a": "x"}},
+		{name: "map with named keys", input: `{"a":1}`, target: new(map[namedStatus]float64), want: map[namedStatus]float64{"a": 1}},
+		{name: "unmarshaler", input: `"abc"`, target: new(upperString), want: upperString("ABC")},
+		{name: "text unmarshaler", input: `"2024-01-02T03:04:05Z"`, target: new(time.Time), want: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{name: "complex128", input: `{"real":1.5,"imag":-2}`, target: new(complex128), want: complex(1.5, -2)},
+		{name: "complex64 missing imag", input: `{"real":3}`, target: new(complex64), want: complex64(3)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() unexpected error = %v", err)
+			}
+			if err := Decode(tree, tt.target); err != nil {
+				t.Fatalf("Decode() unexpected error = %v", err)
+			}
+			got := reflect.ValueOf(tt.target).Elem().Interface()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		target   any
+		wantErr  error
+		wantPath string
+	}{
+		{name: "string into int", input: `"1"`, target: new(int), wantErr: ErrTypeMismatch, wantPath: ""},
+		{name: "fraction into int", input: `1.5`, target: new(int), wantErr: ErrNumberNotInteger},
+		{name: "int8 overflow", input: `128`, target: new(int8), wantErr: ErrNumericValueOutOfRange},
+		{name: "negative into uint", input: `-1`, target: new(uint), wantErr: ErrNumericValueOutOfRange},
+		{name: "float32 overflow", input: `1e300`, target: new(float32), wantErr: ErrNumericValueOutOfRange},
+		{name: "number into bool", input: `1`, target: new(bool), wantErr: ErrTypeMismatch},
+		{name: "object into slice", input: `{}`, target: new([]int), wantErr: ErrTypeMismatch},
+		{name: "array length", input: `[1,2,3]`, target: new([2]int), wantErr: ErrLengthMismatch},
+		{name: "byte array length", input: `"abc"`, target: new([4]byte), wantErr: ErrLengthMismatch},
+		{name: "non-string map keys", input: `{"1":1}`, target: new(map[int]int), wantErr: ErrTypeMismatch},
+		{name: "array into struct", input: `[]`, target: new(decodeAddress), wantErr: ErrTypeMismatch},
+		{name: "nested field", input: `{"address":{"city":1}}`, target: new(decodePerson), wantErr: ErrTypeMismatch, wantPath: "/address/city"},
+		{name: "nested element", input: `{"tags":["a",2]}`, target: new(decodePerson), wantErr: ErrTypeMismatch, wantPath: "/tags/1"},
+		{name: "number into complex", input: `1`, target: new(complex128), wantErr: ErrTypeMismatch},
+		{name: "complex part", input: `{"real":1,"imag":"x"}`, target: new(complex128), wantErr: ErrTypeMismatch, wantPath: "/imag"},
+		{name: "complex64 overflow", input: `{"real":1e300}`, target: new(complex64), wantErr: ErrNumericValueOutOfRange},
+		{name: "escaped map key", input: `{"a/b":"x"}`, target: new(map[string]int), wantErr: ErrTypeMismatch, wantPath: "/a~1b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() unexpected error = %v", err)
+			}
+			err = Decode(tree, tt.target)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Decode() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			var de *DecodeError
+			if !errors.As(err, &de) {
+				t.Errorf("Decode() error type = %T, want *DecodeError", err)
+				return
+			}
+			if de.Path != tt.wantPath && tt.wantPath != "" {
+				t.Errorf("DecodeError.Path = %q, want %q", de.Path, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestDecodeCallbackErrors(t *testing.T) {
+	var u upperString
+	err := Decode(float64(1), &u)
+	if err == nil || err.Error() != `cannot decode number at "" into jsn.upperString: expected a string` {
+		t.Errorf("Decode() error = %v", err)
+	}
+
+	var tm time.Time
+	if err := Decode("not a time", &tm); err == nil {
+		t.Error("Decode() expected error for invalid time, got nil")
+	}
+
+	if err := Decode(float64(1), nil); err != ErrInvalidDecodeTarget {
+		t.Errorf("Decode() error = %v, want %v", err, ErrInvalidDecodeTarget)
+	}
+	var i int
+	if err := Decode(float64(1), i); err != ErrInvalidDecodeTarget {
+		t.Errorf("Decode() error = %v, want %v", err, ErrInvalidDecodeTarget)
+	}
+}
+
+func TestDecodeEmbeddedShadowing(t *testing.T) {
+	type Inner struct {
+		Name string
+		Kind string
+	}
+	type outer struct {
+		*Inner
+		Name string
+	}
+
+	var got outer
+	if err := Decode(map[string]any{"Name": "outer", "Kind": "k"}, &got); err != nil {
+		t.Fatalf("Decode() unexpected error = %v", err)
+	}
+	if got.Name != "outer" || got.Inner == nil || got.Inner.Kind != "k" || got.Inner.Name != "" {
+		t.Errorf("Decode() = %+v, inner = %+v", got, got.Inner)
+	}
+}
+
+type decodeEvent interface {
+	eventName() string
+}
+
+type decodeCreated struct {
+	Type string `jsn:"type"`
+	ID   int    `jsn:"id"`
+}
+
+func (e *decodeCreated) eventName() string { return "created" }
+
+type decodeDeleted struct {
+	ID int `jsn:"id"`
+}
+
+func (e decodeDeleted) eventName() string { return "deleted" }
+
+func init() {
+	RegisterType("test.created", func() any { return &decodeCreated{} })
+	RegisterType("test.deleted", func() any { return decodeDeleted{} })
+	RegisterType("test.string", func() any { return "" })
+}
+
+func TestDecodeTypeDiscriminator(t *testing.T) {
+	type envelope struct {
+		Event   decodeEvent   `jsn:"event"`
+		Events  []decodeEvent `jsn:"events"`
+		Payload any           `jsn:"payload"`
+	}
+
+	input := `{
+		"event": {"type": "test.created", "id": 1},
+		"events": [{"kind": "test.deleted", "id": 2}, null, {"kind": "test.created", "id": 3}],
+		"payload": {"kind": "test.deleted", "id": 4}
+	}`
+	tree, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("Parse() unexpected error = %v", err)
+	}
+
+	var got envelope
+	if err := Decode(tree, &got, TypeDiscriminator{}); err == nil {
+		t.Errorf("Decode() with the default field expected an error for member %q", "events")
+	}
+
+	input = strings.ReplaceAll(input, `"type"`, `"kind"`)
+	tree, _ = Parse([]byte(input))
+	got = envelope{}
+	if err := Decode(tree, &got, TypeDiscriminator{Field: "kind"}); err != nil {
+		t.Fatalf("Decode() unexpected error = %v", err)
+	}
+	want := envelope{
+		Event:   &decodeCreated{ID: 1},
+		Events:  []decodeEvent{decodeDeleted{ID: 2}, nil, &decodeCreated{ID: 3}},
+		Payload: decodeDeleted{ID: 4},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode() = %+v, want %+v", got, want)
+	}
+
+	// without the option, interface fields only accept assignable tree values
+	got = envelope{}
+	var de *DecodeError
+	if err := Decode(tree, &got); !errors.As(err, &de) || de.Err != ErrTypeMismatch || de.Path != "/event" {
+		t.Errorf("Decode() without option error = %v, want a type mismatch at /event", err)
+	}
+
+	// the discriminator member also populates a matching field
+	var ev decodeEvent
+	tree, _ = Parse([]byte(`{"type":"test.created","id":5}`))
+	if err := Decode(tree, &ev, TypeDiscriminator{}); err != nil {
+		t.Fatalf("Decode() unexpected error = %v", err)
+	}
+	if want := (&decodeCreated{Type: "test.created", ID: 5}); !reflect.DeepEqual(ev, decodeEvent(want)) {
+		t.Errorf("Decode() = %+v, want %+v", ev, want)
+	}
+
+	// objects without the discriminator decode into empty interfaces as is
+	var v any
+	tree, _ = Parse([]byte(`{"id":6}`))
+	if err := Decode(tree, &v, TypeDiscriminator{}); err != nil || !reflect.DeepEqual(v, tree) {
+		t.Errorf("Decode() = %v, %v, want %v", v, err, tree)
+	}
+}
+
+func TestDecodeTypeDiscriminatorErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantPath string
+		wantErr  error
+	}{
+		{name: "unknown", input: `{"type":"test.unknown"}`, wantPath: "/type", wantErr: ErrUnknownType},
+		{name: "not a string", input: `{"type":1}`, wantPath: "/type", wantErr: ErrTypeMismatch},
+		{name: "not assignable", input: `{"type":"test.string"}`, wantPath: "", wantErr: ErrTypeMismatch},
+		{name: "missing", input: `{"id":1}`, wantPath: "", wantErr: ErrTypeMismatch},
+		{name: "invalid member", input: `{"type":"test.created","id":"x"}`, wantPath: "/id", wantErr: ErrTypeMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() unexpected error = %v", err)
+			}
+			var ev decodeEvent
+			err = Decode(tree, &ev, TypeDiscriminator{})
+			var de *DecodeError
+			if !errors.As(err, &de) {
+				t.Fatalf("Decode() error = %v, want *DecodeError", err)
+			}
+			if de.Path != tt.wantPath || de.Err != tt.wantErr {
+				t.Errorf("Decode() error at %q = %v, want %v at %q", de.Path, de.Err, tt.wantErr, tt.wantPath)
+			}
+		})
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterType() with a duplicate discriminator did not panic")
+		}
+	}()
+	RegisterType("test.created", func() any { return &decodeCreated{} })
+}
+
+func TestDecodeKeyTransform(t *testing.T) {
+	type config struct {
+		Host    string         `jsn:"host"`
+		Port    int            `jsn:"port"`
+		Options map[string]int `jsn:"options"`
+	}
+
+	tree, err := Parse([]byte(`{"HOST":"localhost","Port":8080,"Options":{"Retry_Count":3}}`))
+	if err != nil {
+		t.Fatalf("Parse() unexpected error = %v", err)
+	}
+	lower := KeyTransform(strings.ToLower)
+	var got config
+	if err := Decode(tree, &got, lower); err != nil {
+		t.Fatalf("Decode() unexpected error = %v", err)
+	}
+	want := config{Host: "localhost", Port: 8080, Options: map[string]int{"retry_count": 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode() = %+v, want %+v", got, want)
+	}
+
+	// without the transform, nothing matches
+	got = config{}
+	if err := Decode(tree, &got); err != nil || !reflect.DeepEqual(got, config{}) {
+		t.Errorf("Decode() = %+v, %v, want zero value", got, err)
+	}
+
+	// colliding keys: the original key that sorts last wins, unless rejected
+	tree, _ = Parse([]byte(`{"port":1,"PORT":2,"Port":3}`))
+	got = config{}
+	if err := Decode(tree, &got, lower); err != nil || got.Port != 1 {
+		t.Errorf("Decode() port = %v, %v, want 1", got.Port, err)
+	}
+	var de *DecodeError
+	if err := Decode(tree, &got, lower, RejectDuplicateKeys{}); !errors.As(err, &de) || de.Err != ErrDuplicateKey {
+		t.Errorf("Decode() error = %v, want %v", err, ErrDuplicateKey)
+	} else if de.Path != "/Port" {
+		t.Errorf("Decode() error path = %q, want %q", de.Path, "/Port")
+	}
+}
+
+func TestDecodeCaseInsensitiveFields(t *testing.T) {
+	type account struct {
+		Name  string `jsn:"name"`
+		Email string `jsn:"email"`
+		ID    int
+		Alias string `jsn:"NAME"`
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  account
+	}{
+		{name: "folded", input: `{"Name":"a","EMAIL":"b","id":1}`, want: account{Name: "a", Email: "b", ID: 1}},
+		{name: "exact before folded", input: `{"email":"exact","Email":"folded"}`, want: account{Email: "exact"}},
+		{name: "exact key reserved", input: `{"NAME":"alias"}`, want: account{Alias: "alias"}},
+		{name: "both exact", input: `{"name":"n","NAME":"alias"}`, want: account{Name: "n", Alias: "alias"}},
+		{name: "first sorted candidate", input: `{"eMail":"x","EMail":"y"}`, want: account{Email: "y"}},
+		{name: "unicode folding", input: `{"ıd":2,"Id":3}`, want: account{ID: 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() unexpected error = %v", err)
+			}
+			var got account
+			if err := Decode(tree, &got, CaseInsensitiveFields{}); err != nil {
+				t.Fatalf("Decode() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// errors refer to the key found in the object
+	tree, _ := Parse([]byte(`{"EMAIL":1}`))
+	var got account
+	var de *DecodeError
+	if err := Decode(tree, &got, CaseInsensitiveFields{}); !errors.As(err, &de) || de.Path != "/EMAIL" {
+		t.Errorf("Decode() error = %v, want a DecodeError at /EMAIL", err)
+	}
+}
+
+func TestDecodeStringNumbers(t *testing.T) {
+	type record struct {
+		ID    int64    `jsn:"id"`
+		Count *uint8   `jsn:"count"`
+		Ratio float32  `jsn:"ratio"`
+		Raw   Number   `jsn:"raw"`
+		Name  string   `jsn:"name"`
+		Any   any      `jsn:"any"`
+		List  []uint16 `jsn:"list"`
+	}
+	three := uint8(3)
+
+	tests := []struct {
+		name     string
+		input    string
+		want     record
+		wantErr  error
+		wantPath string
+	}{
+		{name: "quoted", input: `{"id":"9007199254740993","count":"3","ratio":"0.5","raw":"1.50","list":["1",2]}`,
+			want: record{ID: 9007199254740993, Count: &three, Ratio: 0.5, Raw: "1.50", List: []uint16{1, 2}}},
+		{name: "plain numbers still accepted", input: `{"id":1,"ratio":2}`, want: record{ID: 1, Ratio: 2}},
+		{name: "other targets unaffected", input: `{"name":"12","any":"12"}`, want: record{Name: "12", Any: "12"}},
+		{name: "not a number", input: `{"id":"12a"}`, wantErr: ErrInvalidNumber, wantPath: "/id"},
+		{name: "whitespace", input: `{"id":" 12"}`, wantErr: ErrInvalidNumber, wantPath: "/id"},
+		{name: "empty", input: `{"count":""}`, wantErr: ErrInvalidNumber, wantPath: "/count"},
+		{name: "fraction into integer", input: `{"id":"1.5"}`, wantErr: ErrNumberNotInteger, wantPath: "/id"},
+		{name: "out of range", input: `{"count":"256"}`, wantErr: ErrNumericValueOutOfRange, wantPath: "/count"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() unexpected error = %v", err)
+			}
+			var got record
+			err = Decode(tree, &got, StringNumbers{})
+			var de *DecodeError
+			if tt.wantErr != nil {
+				if !errors.As(err, &de) || de.Err != tt.wantErr || de.Path != tt.wantPath {
+					t.Errorf("Decode() error = %v, want %v at %s", err, tt.wantErr, tt.wantPath)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode() unexpected error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// without the option, quoted numbers are a type mismatch
+	tree, _ := Parse([]byte(`{"id":"1"}`))
+	var got record
+	if err := Decode(tree, &got); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Decode() error = %v, want %v", err, ErrTypeMismatch)
+	}
+}
+
+// quotedRecord marshals its fields as encoding/json does with the ,string tag
+// modifier
+type quotedRecord struct {
+	ID     int64   `jsn:"id,string"`
+	Score  float64 `jsn:"score,omitempty,string"`
+	Active bool    `jsn:"active,string"`
+	Ref    *uint32 `jsn:"ref,string"`
+	IDs    []int   `jsn:"ids,string"`
+	Plain  int     `jsn:"plain"`
+}
+
+func (r quotedRecord) MarshalJSN(w ObjectWriter) error {
+	w.Member("id", Quoted{r.ID})
+	w.Member("score", Quoted{r.Score})
+	w.Member("active", Quoted{r.Active})
+	w.Member("ref", Quoted{r.Ref})
+	w.Member("ids", r.IDs)
+	w.Member("plain", r.Plain)
+	return nil
+}
+
+func TestDecodeStringTag(t *testing.T) {
+	ref := uint32(7)
+	for _, want := range []quotedRecord{
+		{ID: 1<<53 + 1, Score: 0.1, Active: true, Ref: &ref, IDs: []int{1, 2}, Plain: 3},
+		{ID: -(1<<53 + 1), Score: -1e300, IDs: []int{-1}},
+	} {
+		text, err := Marshal(want, PreserveNumbers{})
+		if err != nil {
+			t.Fatalf("Marshal() unexpected error = %v", err)
+		}
+		tree, err := Parse([]byte(text))
+		if err != nil {
+			t.Fatalf("Parse(%s) unexpected error = %v", text, err)
+		}
+		var got quotedRecord
+		if err := Decode(tree, &got); err != nil {
+			t.Fatalf("Decode(%s) unexpected error = %v", text, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("round trip of %s = %+v, want %+v", text, got, want)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		wantErr  error
+		wantPath string
+	}{
+		{name: "unquoted values still accepted", input: `{"id":1,"active":true}`},
+		{name: "invalid bool", input: `{"active":"yes"}`, wantErr: ErrTypeMismatch, wantPath: "/active"},
+		{name: "invalid number", input: `{"id":"0x10"}`, wantErr: ErrInvalidNumber, wantPath: "/id"},
+		{name: "modifier applies to its own field only", input: `{"plain":"1"}`, wantErr: ErrTypeMismatch, wantPath: "/plain"},
+		{name: "modifier does not apply to elements", input: `{"ids":["1"]}`, wantErr: ErrTypeMismatch, wantPath: "/ids/0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, _ := Parse([]byte(tt.input))
+			var got quotedRecord
+			err := Decode(tree, &got)
+			var de *DecodeError
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && (!errors.As(err, &de) || de.Err != tt.wantErr || de.Path != tt.wantPath) {
+				t.Errorf("Decode() error = %v, want %v at %s", err, tt.wantErr, tt.wantPath)
+			}
+		})
+	}
+}
+
+// legacyTemp has encoding/json decoding logic only
+type legacyTemp struct {
+	Celsius float64
+}
+
+func (l *legacyTemp) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Value float64 `json:"value"`
+		Unit  string  `json:"unit"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch v.Unit {
+	case "C":
+		l.Celsius = v.Value
+	case "F":
+		l.Celsius = (v.Value - 32) * 5 / 9
+	default:
+		return fmt.Errorf("unknown unit %q", v.Unit)
+	}
+	return nil
+}
+
+// bothUnmarshalers implements UnmarshalJSN and UnmarshalJSON
+type bothUnmarshalers string
+
+func (b *bothUnmarshalers) UnmarshalJSN(v any) error {
+	*b = "jsn"
+	return nil
+}
+
+func (b *bothUnmarshalers) UnmarshalJSON([]byte) error {
+	*b = "json"
+	return nil
+}
+
+func TestDecodeJSONUnmarshaler(t *testing.T) {
+	type target struct {
+		Temp  legacyTemp       `jsn:"temp"`
+		Temps []*legacyTemp    `jsn:"temps"`
+		Raw   json.RawMessage  `jsn:"raw"`
+		Null  json.RawMessage  `jsn:"null"`
+		Both  bothUnmarshalers `jsn:"both"`
+	}
+	input := `{
+		"temp": {"value": 212, "unit": "F"},
+		"temps": [{"unit": "C", "value": 1.5}, null],
+		"raw": {"b": [1.50, 10000000000000000001], "a": "x"},
+		"null": null,
+		"both": 1
+	}`
+	tree, err := Parse([]byte(input), ScannerFlagUseNumber)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	var got target
+	if err := Decode(tree, &got); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got.Temp.Celsius != 100 || len(got.Temps) != 2 || got.Temps[0].Celsius != 1.5 || got.Temps[1] != nil {
+		t.Errorf("Decode() temperatures = %+v, %+v", got.Temp, got.Temps)
+	}
+	if string(got.Raw) != `{"a":"x","b":[1.50,10000000000000000001]}` {
+		t.Errorf("Decode() raw = %s", got.Raw)
+	}
+	if string(got.Null) != `null` {
+		t.Errorf("Decode() null = %s", got.Null)
+	}
+	if got.Both != "jsn" {
+		t.Errorf("Decode() both = %q, want UnmarshalJSN to take precedence", got.Both)
+	}
+
+	var de *DecodeError
+	err = Decode(map[string]any{"temp": map[string]any{"value": 1.0, "unit": "K"}}, &got)
+	if !errors.As(err, &de) || de.Path != "/temp" || de.Err.Error() != `unknown unit "K"` {
+		t.Errorf("Decode() error = %v", err)
+	}
+}
+
+type unixRecord struct {
+	Seconds time.Time   `jsn:"s,unix"`
+	Millis  time.Time   `jsn:"ms,unixmilli"`
+	Ptr     *time.Time  `jsn:"ptr,unix"`
+	List    []time.Time `jsn:"list,unixmilli"`
+	Plain   time.Time   `jsn:"plain"`
+}
+
+func TestDecodeUnixTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     []any
+		scan     []any
+		want     unixRecord
+		wantErr  error
+		wantPath string
+	}{
+		{name: "seconds", input: `{"s":1700000000}`, want: unixRecord{Seconds: time.Unix(1700000000, 0)}},
+		{name: "fractional seconds", input: `{"s":1700000000.123456789}`, scan: []any{ScannerFlagUseNumber},
+			want: unixRecord{Seconds: time.Unix(1700000000, 123456789)}},
+		{name: "float seconds", input: `{"s":1.5}`, want: unixRecord{Seconds: time.Unix(1, 500000000)}},
+		{name: "rounded to nanoseconds", input: `{"s":0.0000000015}`, want: unixRecord{Seconds: time.Unix(0, 2)}},
+		{name: "negative", input: `{"s":-1.5}`, want: unixRecord{Seconds: time.Unix(-2, 500000000)}},
+		{name: "exponent", input: `{"s":1.7e9}`, scan: []any{ScannerFlagUseNumber}, want: unixRecord{Seconds: time.Unix(1700000000, 0)}},
+		{name: "millis", input: `{"ms":1700000000250}`, want: unixRecord{Millis: time.Unix(1700000000, 250000000)}},
+		{name: "fractional millis", input: `{"ms":1.5}`, want: unixRecord{Millis: time.Unix(0, 1500000)}},
+		{name: "pointer and list", input: `{"ptr":2,"list":[1000,null,2500]}`,
+			want: unixRecord{Ptr: timePtr(time.Unix(2, 0)), List: []time.Time{time.Unix(1, 0), {}, time.Unix(2, 500000000)}}},
+		{name: "strings still accepted", input: `{"s":"2023-11-14T22:13:20Z"}`, want: unixRecord{Seconds: time.Unix(1700000000, 0)}},
+		{name: "option", input: `{"plain":60}`, opts: []any{UnixTime{}}, want: unixRecord{Plain: time.Unix(60, 0)}},
+		{name: "tag overrides option", input: `{"s":60,"plain":60}`, opts: []any{UnixTime{Millis: true}},
+			want: unixRecord{Seconds: time.Unix(60, 0), Plain: time.Unix(0, 60000000)}},
+		{name: "out of range", input: `{"s":1e300}`, wantErr: ErrNumericValueOutOfRange, wantPath: "/s"},
+		{name: "invalid number", input: `{"s":1e99999}`, scan: []any{ScannerFlagUseNumber}, wantErr: ErrNumericValueOutOfRange, wantPath: "/s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := Parse([]byte(tt.input), tt.scan...)
+			if err != nil {
+				t.Fatalf("Parse() unexpected error = %v", err)
+			}
+			var got unixRecord
+			err = Decode(tree, &got, tt.opts...)
+			var de *DecodeError
+			if tt.wantErr != nil {
+				if !errors.As(err, &de) || !errors.Is(err, tt.wantErr) || de.Path != tt.wantPath {
+					t.Errorf("Decode() error = %v, want %v at %q", err, tt.wantErr, tt.wantPath)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode() unexpected error = %v", err)
+			}
+			if !unixRecordsEqual(got, tt.want) {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.want)
+			}
+			if got.Seconds.Location() != time.UTC && !got.Seconds.IsZero() {
+				t.Errorf("Decode() location = %v, want UTC", got.Seconds.Location())
+			}
+		})
+	}
+
+	// untagged fields without the option keep the time.Time encoding
+	tree, _ := Parse([]byte(`{"plain":60}`))
+	var rec unixRecord
+	var de *DecodeError
+	if err := Decode(tree, &rec); !errors.As(err, &de) || de.Path != "/plain" {
+		t.Errorf("Decode() error = %v, want *DecodeError at /plain", err)
+	}
+
+	// round trip through Marshal
+	want := time.Unix(1700000000, 123456789)
+	text, _ := Marshal(want, UnixTime{})
+	tree, _ = Parse([]byte(text), ScannerFlagUseNumber)
+	var got time.Time
+	if err := Decode(tree, &got, UnixTime{}); err != nil || !got.Equal(want) {
+		t.Errorf("round trip of %s = %v, %v, want %v", text, got, err, want)
+	}
+}
+
+func timePtr(t time.Time) *time.Time { return &t }
+
+// unixRecordsEqual compares the instants of the times in two records
+func unixRecordsEqual(a, b unixRecord) bool {
+	if a.Ptr == nil != (b.Ptr == nil) || a.Ptr != nil && !a.Ptr.Equal(*b.Ptr) || len(a.List) != len(b.List) {
+		return false
+	}
+	for i := range a.List {
+		if !a.List[i].Equal(b.List[i]) {
+			return false
+		}
+	}
+	return a.Seconds.Equal(b.Seconds) && a.Millis.Equal(b.Millis) && a.Plain.Equal(b.Plain)
+}
@@ -1,20 +1,34 @@
 package jsn
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math"
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 // decorator handles the low-level writing of JSON values with proper formatting.
 type decorator struct {
-	out            io.Writer // The underlying writer where JSON output is written
-	floatPrecision int       // Precision used when formatting floating-point numbers
-	err            error     // Whether an error has occurred
+	out io.Writer // The underlying writer where JSON output is written
+	marshalOptions
+	err   error           // Whether an error has occurred
+	buf   []byte          // Scratch buffer for formatting scalars
+	ctx   context.Context // Checked between members and elements, may be nil
+	depth int             // Nesting level of the containers being written
 }
 
 // handleError sets the error if it hasn't been set yet.
@@ -29,6 +43,27 @@ func (d *decorator) hadError() bool {
 	return d.err != nil
 }
 
+// canceled reports whether the marshaling context is done, in which case its
+// error is recorded.
+func (d *decorator) canceled() bool {
+	if d.ctx == nil {
+		return false
+	}
+	if err := d.ctx.Err(); err != nil {
+		d.handleError(err)
+		return true
+	}
+	return false
+}
+
+// context returns the marshaling context.
+func (d *decorator) context() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
+}
+
 // put writes a string to the underlying writer.
 func (d *decorator) put(s string) {
 	if d.err != nil {
@@ -40,6 +75,45 @@ func (d *decorator) put(s string) {
 	}
 }
 
+// write writes a byte slice to the underlying writer.
+func (d *decorator) write(p []byte) {
+	if d.err != nil {
+		return // block output if an error has occurred
+	}
+	_, err := d.out.Write(p)
+	if err != nil {
+		d.handleError(err)
+	}
+}
+
+// isBuffered reports whether writes to w are cheap enough not to need another
+// buffer: w buffers itself or writes to memory
+func isBuffered(w io.Writer) bool {
+	switch w.(type) {
+	case *bufio.Writer, *bufio.ReadWriter, *bytes.Buffer, *strings.Builder:
+		return true
+	}
+	return false
+}
+
+// buffered routes the output through a bufio.Writer, unless it is already
+// buffered, so that the many small writes of a value do not each reach the
+// underlying writer. The returned function flushes the buffer and must be
+// called once writing is done, it records and returns any flush error.
+func (d *decorator) buffered() (flush func() error) {
+	if isBuffered(d.out) {
+		return func() error { return d.err }
+	}
+	bw := bufio.NewWriter(d.out)
+	d.out = bw
+	return func() error {
+		if err := bw.Flush(); err != nil {
+			d.handleError(err)
+		}
+		return d.err
+	}
+}
+
 func (d *decorator) marshalNull() {
 	d.put("null")
 }
@@ -53,10 +127,128 @@ func (d *decorator) marshalBool(v bool) {
 }
 
 func (d *decorator) marshalFloat64(v float64) {
+	d.writeFloat(v, &d.marshalOptions)
+}
+
+// writeFloat formats and writes a float according to mo
+func (d *decorator) writeFloat(v float64, mo *marshalOptions) {
+	var err error
+	d.buf, err = appendFloat(d.buf[:0], v, mo)
+	if err != nil {
+		d.handleError(err)
+		return
+	}
 	if math.IsInf(v, 0) || math.IsNaN(v) {
-		d.handleError(fmt.Errorf("unsupported float value: %v", v))
+		d.write(d.buf) // as selected by NonFiniteFloats, never quoted again
+		return
+	}
+	d.writeNumber(d.buf)
+}
+
+// writeNumber writes the text of a number, quoted with NumbersAsStrings
+func (d *decorator) writeNumber(b []byte) {
+	if d.numbersAsStr {
+		d.put("\"")
+		d.write(b)
+		d.put("\"")
+		return
+	}
+	d.write(b)
+}
+
+// appendFloat appends the formatted float to dst, according to the options
+func appendFloat(dst []byte, v float64, mo *marshalOptions) ([]byte, error) {
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return appendNonFinite(dst, v, mo.nonFinite)
+	}
+	if mo.integralFloats && v == math.Trunc(v) && v >= math.MinInt64 && v < -math.MinInt64 {
+		return strconv.AppendInt(dst, int64(v), 10), nil
+	}
+	if mo.preserveNums {
+		return strconv.AppendFloat(dst, v, 'g', -1, 64), nil
+	}
+	return strconv.AppendFloat(dst, v, 'g', mo.floatPrecision, 64), nil
+}
+
+// appendNonFinite appends an infinity or NaN as selected by NonFiniteFloats
+func appendNonFinite(dst []byte, v float64, mode NonFiniteMode) ([]byte, error) {
+	var text string
+	switch {
+	case math.IsNaN(v):
+		text = "NaN"
+	case v > 0:
+		text = "Infinity"
+	default:
+		text = "-Infinity"
+	}
+	switch mode {
+	case NonFiniteNull:
+		return append(dst, "null"...), nil
+	case NonFiniteString:
+		return append(append(append(dst, '"'), text...), '"'), nil
+	case NonFiniteLiteral:
+		return append(dst, text...), nil
+	}
+	return dst, fmt.Errorf("unsupported float value: %v", v)
+}
+
+// marshalPrecise formats the value with its own precision, see Precise
+func (d *decorator) marshalPrecise(p Precise) {
+	mo := d.marshalOptions
+	mo.floatPrecision = p.Precision
+	mo.preserveNums = false
+	if p.Precision < 0 {
+		mo.floatPrecision = -1
+	}
+	d.writeFloat(p.Value, &mo)
+}
+
+// marshalQuoted marshals a number or boolean as a string, see Quoted
+func (d *decorator) marshalQuoted(q Quoted) {
+	val := reflect.ValueOf(q.Value)
+	for val.Kind() == reflect.Ptr && !val.IsNil() {
+		val = val.Elem()
+	}
+	switch val.Kind() {
+	case reflect.Invalid, reflect.Ptr:
+		d.marshalNull()
+		return
+	case reflect.Bool:
+		if val.Bool() {
+			d.put(`"true"`)
+		} else {
+			d.put(`"false"`)
+		}
+		return
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+	default:
+		if val.Type() != numberType && val.Type() != preciseType {
+			d.handleError(&UnsupportedTypeError{val.Type()})
+			return
+		}
 	}
-	d.put(strconv.FormatFloat(v, 'g', d.floatPrecision, 64))
+	saved := d.numbersAsStr
+	d.numbersAsStr = true
+	d.marshalValue(val.Interface())
+	d.numbersAsStr = saved
+}
+
+func (d *decorator) marshalNumber(n Number) {
+	if !isValidNumber(string(n)) {
+		d.handleError(fmt.Errorf("invalid number: %q", string(n)))
+		return
+	}
+	if d.preserveNums {
+		d.buf = append(d.buf[:0], n...)
+		d.writeNumber(d.buf)
+		return
+	}
+	// the syntax is valid, so the only possible error is a range error, in
+	// which case v is infinite and rejected by marshalFloat64
+	v, _ := n.Float64()
+	d.marshalFloat64(v)
 }
 
 func (d *decorator) marshalString(v string) {
@@ -69,21 +261,41 @@ func (d *decorator) marshalString(v string) {
 func (d *decorator) objectBegin() {}
 
 func (d *decorator) objectField(name string, first bool) {
+	if !d.indenting() {
+		if first {
+			d.put("{\"")
+		} else {
+			d.put(",\"")
+		}
+		d.scrambleStr(name)
+		d.put("\":")
+		return
+	}
 	if first {
-		d.put("{\"")
+		d.put("{")
+		d.depth++
 	} else {
-		d.put(",\"")
+		d.put(",")
 	}
+	d.newline()
+	d.put("\"")
 	d.scrambleStr(name)
-	d.put("\":")
+	d.put("\": ")
 }
 
 func (d *decorator) objectEnd(wasEmpty bool) {
 	if wasEmpty {
 		d.put("{}")
-	} else {
-		d.put("}")
+		return
 	}
+	if d.indenting() {
+		if d.trailingCommas {
+			d.put(",")
+		}
+		d.depth--
+		d.newline()
+	}
+	d.put("}")
 }
 
 func (d *decorator) marshalObj(m ObjMarshaler) {
@@ -97,7 +309,7 @@ func (d *decorator) marshalObj(m ObjMarshaler) {
 	if err != nil {
 		d.handleError(err)
 	}
-	d.objectEnd(ow.fieldCounter == 0)
+	ow.end()
 }
 
 // Array handling methods
@@ -106,16 +318,51 @@ func (d *decorator) arrayBegin() {}
 func (d *decorator) arrayElement(first bool) {
 	if first {
 		d.put("[")
+		d.depth++
 	} else {
 		d.put(",")
 	}
+	d.newline()
 }
 
 func (d *decorator) arrayEnd(wasEmpty bool) {
 	if wasEmpty {
 		d.put("[]")
-	} else {
-		d.put("]")
+		return
+	}
+	if d.trailingCommas && d.indenting() {
+		d.put(",")
+	}
+	d.depth--
+	d.newline()
+	d.put("]")
+}
+
+// lessKey reports whether key a sorts before key b, see KeyComparator
+func (d *decorator) lessKey(a, b string) bool {
+	if d.keyLess != nil {
+		return d.keyLess(a, b)
+	}
+	return a < b
+}
+
+// indenting reports whether the output is indented, see Indent and IndentFunc
+func (d *decorator) indenting() bool {
+	return d.indent != "" || d.indentFunc != nil
+}
+
+// newline starts a new line indented for the current depth, unless the output
+// is compact
+func (d *decorator) newline() {
+	switch {
+	case d.indentFunc != nil:
+		d.put("\n")
+		d.put(d.indentFunc(d.depth))
+	case d.indent != "":
+		d.put("\n")
+		for i := 0; i < d.depth; i++ {
+			d.put(d.indent)
+		}
 	}
 }
 
@@ -132,6 +379,15 @@ func (d *decorator) marshalArr(m ArrMarshaler) {
 	d.arrayEnd(aw.elementCounter == 0)
 }
 
+// marshalDocument marshals a top-level value, followed by the newline
+// requested with TrailingNewline
+func (d *decorator) marshalDocument(v any) {
+	d.marshalValue(v)
+	if d.trailingNL {
+		d.put("\n")
+	}
+}
+
 // Complex value handling
 func (d *decorator) marshalValue(v any) {
 	if d.hadError() {
@@ -147,6 +403,7 @@ func (d *decorator) marshalValue(v any) {
 	}
 	if val.Kind() == reflect.Ptr && val.IsNil() {
 		d.marshalNull()
+		return
 	}
 
 	// Handle functional inputs
@@ -173,7 +430,7 @@ func (d *decorator) marshalValue(v any) {
 		d.objectBegin()
 		ow := objectWriter{d: d}
 		typ(&ow)
-		d.objectEnd(ow.fieldCounter == 0)
+		ow.end()
 		return
 
 	case func(ObjectWriter) error:
@@ -184,7 +441,7 @@ func (d *decorator) marshalValue(v any) {
 			d.handleError(err)
 			return
 		}
-		d.objectEnd(ow.fieldCounter == 0)
+		ow.end()
 		return
 	}
 
@@ -199,64 +456,51 @@ func (d *decorator) marshalValue(v any) {
 
 	typ := val.Type()
 
-	if val.CanInterface() {
-		if typ.Implements(objMarshalerType) {
-			d.marshalObj(val.Interface().(ObjMarshaler))
-			return
-		} else if typ.Implements(arrMarshalerType) {
-			d.marshalArr(val.Interface().(ArrMarshaler))
-			return
-		} else if typ.Implements(strMarshalerType) {
-			s, err := val.Interface().(StrMarshaler).MarshalJSN()
-			if err != nil {
-				d.handleError(err)
-				return
-			}
-			d.marshalString(s)
-			return
-		} else if typ.Implements(textMarshalerType) {
-			s, err := val.Interface().(encoding.TextMarshaler).MarshalText()
-			if err != nil {
-				d.handleError(err)
-			}
-			d.marshalString(string(s))
-			return
-		}
+	if typ == numberType {
+		d.marshalNumber(Number(val.String()))
+		return
+	}
+	if typ == preciseType {
+		d.marshalPrecise(val.Interface().(Precise))
+		return
+	}
+	if typ == quotedType {
+		d.marshalQuoted(val.Interface().(Quoted))
+		return
+	}
+	if typ == durationType && d.durationAsStr {
+		d.marshalString(time.Duration(val.Int()).String())
+		return
+	}
+	if typ == timeType && d.unixTime != unixNone {
+		d.buf = appendUnixTime(d.buf[:0], val.Interface().(time.Time), d.unixTime)
+		d.writeNumber(d.buf)
+		return
 	}
 
-	if val.CanAddr() {
-		pv := val.Addr()
-		if pv.CanInterface() {
-			if pv.Type().Implements(objMarshalerType) {
-				d.marshalObj(pv.Interface().(ObjMarshaler))
-				return
-			} else if pv.Type().Implements(arrMarshalerType) {
-				d.marshalArr(pv.Interface().(ArrMarshaler))
-				return
-			} else if pv.Type().Implements(strMarshalerType) {
-				s, err := pv.Interface().(StrMarshaler).MarshalJSN()
-				if err != nil {
-					d.handleError(err)
-					return
-				}
-				d.marshalString(s)
-				return
-			} else if pv.Type().Implements(textMarshalerType) {
-				s, err := pv.Interface().(encoding.TextMarshaler).MarshalText()
-				if err != nil {
-					d.handleError(err)
-					return
-				}
-				d.marshalString(string(s))
-				return
-			}
+	if m := marshalerOf(val); m != nil {
+		d.marshalWith(m)
+		return
+	}
+
+	if d.marshalAtomic(val) {
+		return
+	}
+
+	if d.errorsAsString {
+		if e, ok := asError(val); ok {
+			d.marshalString(e.Error())
+			return
 		}
 	}
 
 	k := val.Kind()
-	if (k == reflect.Slice || val.Kind() == reflect.Array) && typ.Elem().Kind() != reflect.Uint8 {
+	if (k == reflect.Slice || val.Kind() == reflect.Array) && !d.isByteString(typ) {
 		d.arrayBegin()
 		for i, n := 0, val.Len(); i < n; i++ {
+			if d.canceled() {
+				return
+			}
 			d.arrayElement(i == 0)
 			d.marshalValue(val.Index(i).Interface())
 			if d.hadError() {
@@ -268,45 +512,64 @@ func (d *decorator) marshalValue(v any) {
 	}
 
 	// TODO: keys convertible to string
-	if k == reflect.Map && typ.Key().Kind() == reflect.String {
+	if k == reflect.Map && (typ.Key().Kind() == reflect.String || d.isByteKey(typ.Key())) {
 		type pair struct {
 			k string
 			v reflect.Value
 		}
-		pairs := make([]pair, val.Len())
+		pairs := make([]pair, 0, val.Len())
 		mi := val.MapRange()
-		for i := 0; mi.Next(); i++ {
-			pairs[i].k = mi.Key().String()
-			pairs[i].v = mi.Value()
+		for mi.Next() {
+			if d.omitNulls && isNull(mi.Value()) {
+				continue
+			}
+			pairs = append(pairs, pair{k: d.mapKey(mi.Key()), v: mi.Value()})
 		}
 
 		// coming from a map, the only way to produce a stable repeatable output
 		// is to sort the keys
-		sort.Slice(pairs, func(i, j int) bool { return pairs[i].k < pairs[j].k })
+		sort.Slice(pairs, func(i, j int) bool { return d.lessKey(pairs[i].k, pairs[j].k) })
 
 		d.objectBegin()
 		for i, kv := range pairs {
+			if d.canceled() {
+				return
+			}
 			d.objectField(kv.k, i == 0)
 			d.marshalValue(kv.v.Interface())
 			if d.hadError() {
 				return // early exit if an error has occurred
 			}
 		}
-		d.objectEnd(val.Len() == 0)
+		d.objectEnd(len(pairs) == 0)
 		return
 	}
 
 	// simple types
 	switch k {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		d.put(strconv.FormatInt(val.Int(), 10))
+		d.buf = strconv.AppendInt(d.buf[:0], val.Int(), 10)
+		d.writeNumber(d.buf)
 		return
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		d.put(strconv.FormatUint(val.Uint(), 10))
+		d.buf = strconv.AppendUint(d.buf[:0], val.Uint(), 10)
+		d.writeNumber(d.buf)
 		return
 	case reflect.Float32, reflect.Float64:
 		d.marshalFloat64(val.Float())
 		return
+	case reflect.Complex64, reflect.Complex128:
+		if !d.complexAsObj {
+			break
+		}
+		c := val.Complex()
+		d.objectBegin()
+		d.objectField("real", true)
+		d.marshalFloat64(real(c))
+		d.objectField("imag", false)
+		d.marshalFloat64(imag(c))
+		d.objectEnd(false)
+		return
 	case reflect.String:
 		d.marshalString(val.String())
 		return
@@ -314,7 +577,7 @@ func (d *decorator) marshalValue(v any) {
 		d.marshalBool(val.Bool())
 		return
 	case reflect.Array:
-		if typ.Elem().Kind() != reflect.Uint8 {
+		if !d.isByteString(typ) {
 			break
 		}
 		// [...]byte
@@ -322,78 +585,332 @@ func (d *decorator) marshalValue(v any) {
 		if val.CanAddr() {
 			bytes = val.Slice(0, val.Len()).Bytes()
 		} else {
+			// not addressable (e.g. passed by value), copy element by element,
+			// reflect.Copy would panic for named byte element types
 			bytes = make([]byte, val.Len())
-			reflect.Copy(reflect.ValueOf(bytes), val)
+			for i := range bytes {
+				bytes[i] = byte(val.Index(i).Uint())
+			}
 		}
 		d.marshalString(string(bytes))
 		return
 
 	case reflect.Slice:
-		if typ.Elem().Kind() != reflect.Uint8 {
+		if !d.isByteString(typ) {
 			break
 		}
 		d.marshalString(string(val.Bytes()))
 		return
+
+	case reflect.Struct:
+		if !d.emptyStructObj || hasExportedFields(typ) {
+			break
+		}
+		d.objectBegin()
+		d.objectEnd(true)
+		return
 	}
 	d.handleError(&UnsupportedTypeError{typ})
 }
 
+// appendUnixTime appends t as a number of seconds or milliseconds since the
+// epoch, with the sub-second or sub-millisecond part as a fraction
+func appendUnixTime(dst []byte, t time.Time, unit unixUnit) []byte {
+	whole, frac, scale := t.Unix(), int64(t.Nanosecond()), int64(1e9)
+	if unit == unixMillis {
+		whole, frac, scale = whole*1000+frac/1e6, frac%1e6, 1e6
+	}
+	if whole < 0 && frac > 0 {
+		// the fraction counts up from the whole part, e.g. -2 + 0.5 is -1.5
+		whole++
+		frac = scale - frac
+		if whole == 0 {
+			dst = append(dst, '-')
+		}
+	}
+	dst = strconv.AppendInt(dst, whole, 10)
+	if frac == 0 {
+		return dst
+	}
+	// the digits of scale+frac are those of frac with leading zeros after a 1,
+	// which the decimal point replaces
+	n := len(dst)
+	dst = strconv.AppendInt(dst, scale+frac, 10)
+	dst[n] = '.'
+	for dst[len(dst)-1] == '0' {
+		dst = dst[:len(dst)-1]
+	}
+	return dst
+}
+
+// isByteString reports whether slices or arrays of type t marshal as strings:
+// their elements are bytes, named byte types included, unless BytesAsArray is
+// given or the element type has a marshaler of its own
+func (d *decorator) isByteString(t reflect.Type) bool {
+	e := t.Elem()
+	if e.Kind() != reflect.Uint8 || d.bytesAsArr {
+		return false
+	}
+	pe := reflect.PointerTo(e)
+	for _, mt := range marshalerTypes {
+		if e.Implements(mt) || pe.Implements(mt) {
+			return false
+		}
+	}
+	return true
+}
+
+// isByteKey reports whether maps with keys of type t marshal with ByteKeys
+func (d *decorator) isByteKey(t reflect.Type) bool {
+	return d.byteKeys && t.Kind() == reflect.Array && t.Elem().Kind() == reflect.Uint8
+}
+
+// mapKey returns the object key of a string or byte array map key
+func (d *decorator) mapKey(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	// map keys are not addressable, copy element by element
+	b := make([]byte, k.Len())
+	for i := range b {
+		b[i] = byte(k.Index(i).Uint())
+	}
+	if d.byteKeyEnc == ByteKeysBase64 {
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	return hex.EncodeToString(b)
+}
+
+// marshalerTypes lists the marshaler interfaces in order of precedence
+var marshalerTypes = [...]reflect.Type{objMarshalerType, arrMarshalerType, strMarshalerType, jsonMarshalerType, textMarshalerType}
+
+// marshalerOf returns val, or a pointer to it, as the marshaler interface
+// with the highest precedence (ObjMarshaler, ArrMarshaler, StrMarshaler,
+// json.Marshaler, encoding.TextMarshaler) implemented by either of them, or nil. The value and
+// the pointer receiver method sets are considered together, so the outcome
+// does not depend on the receivers the methods were declared with.
+func marshalerOf(val reflect.Value) any {
+	if !val.CanInterface() {
+		return nil
+	}
+	pv, hasPtr := pointerTo(val)
+	for _, mt := range marshalerTypes {
+		if val.Type().Implements(mt) {
+			return val.Interface()
+		}
+		if hasPtr && pv.Type().Implements(mt) {
+			return pv.Interface()
+		}
+	}
+	return nil
+}
+
+// marshalWith marshals using a marshaler returned by marshalerOf
+func (d *decorator) marshalWith(m any) {
+	switch m := m.(type) {
+	case ObjMarshaler:
+		d.marshalObj(m)
+	case ArrMarshaler:
+		d.marshalArr(m)
+	case StrMarshaler:
+		s, err := m.MarshalJSN()
+		if err != nil {
+			d.handleError(err)
+			return
+		}
+		d.marshalString(s)
+	case json.Marshaler:
+		b, err := m.MarshalJSON()
+		if err != nil {
+			d.handleError(err)
+			return
+		}
+		d.marshalRaw(b, m)
+	case encoding.TextMarshaler:
+		s, err := m.MarshalText()
+		if err != nil {
+			d.handleError(err)
+			return
+		}
+		d.marshalString(string(s))
+	}
+}
+
+// marshalRaw validates JSON text produced by the json.Marshaler m and writes it
+// compacted, with the marshal options applied as in CopyValue
+func (d *decorator) marshalRaw(b []byte, m json.Marshaler) {
+	s := NewScanner(b)
+	err := copyValue(d, s)
+	if err == nil {
+		err = s.Finalize()
+	}
+	if err != nil {
+		d.handleError(fmt.Errorf("invalid output of MarshalJSON for %T: %w", m, err))
+	}
+}
+
+// marshalAtomic marshals the loaded value of the sync/atomic types and reports
+// whether val was one of them. All of them have a Load method with a pointer
+// receiver, values that are not addressable are copied first.
+func (d *decorator) marshalAtomic(val reflect.Value) bool {
+	if val.Kind() != reflect.Struct || val.Type().PkgPath() != "sync/atomic" || !val.CanInterface() {
+		return false
+	}
+	var pv reflect.Value
+	if val.CanAddr() {
+		pv = val.Addr()
+	} else {
+		pv = reflect.New(val.Type())
+		pv.Elem().Set(val)
+	}
+	load := pv.MethodByName("Load")
+	if !load.IsValid() || load.Type().NumIn() != 0 || load.Type().NumOut() != 1 {
+		return false
+	}
+	d.marshalValue(load.Call(nil)[0].Interface())
+	return true
+}
+
+// isNull reports whether val marshals as JSON null, i.e. it is invalid (a nil
+// any) or a nil pointer or interface, possibly behind other pointers
+func isNull(val reflect.Value) bool {
+	for {
+		switch val.Kind() {
+		case reflect.Invalid:
+			return true
+		case reflect.Ptr, reflect.Interface:
+			if val.IsNil() {
+				return true
+			}
+			val = val.Elem()
+		default:
+			return false
+		}
+	}
+}
+
+// pointerTo returns a pointer to val for dispatching to pointer receiver
+// marshalers. Values that are not addressable (passed by value, stored in a map
+// or in an interface) are copied when their pointer type implements one of the
+// marshaler interfaces, so that the outcome does not depend on how the value
+// was reached.
+func pointerTo(val reflect.Value) (reflect.Value, bool) {
+	if val.CanAddr() {
+		return val.Addr(), true
+	}
+	if !val.CanInterface() {
+		return reflect.Value{}, false
+	}
+	pt := reflect.PointerTo(val.Type())
+	for _, mt := range marshalerTypes {
+		if pt.Implements(mt) {
+			pv := reflect.New(val.Type())
+			pv.Elem().Set(val)
+			return pv, true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// asError returns the error implemented by val or by its address
+func asError(val reflect.Value) (error, bool) {
+	if val.CanInterface() && val.Type().Implements(errorType) {
+		return val.Interface().(error), true
+	}
+	if val.CanAddr() {
+		pv := val.Addr()
+		if pv.CanInterface() && pv.Type().Implements(errorType) {
+			return pv.Interface().(error), true
+		}
+	}
+	return nil, false
+}
+
+// hasExportedFields reports whether a struct type has any exported fields
+func hasExportedFields(typ reflect.Type) bool {
+	for i, n := 0, typ.NumField(); i < n; i++ {
+		if typ.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
+
 // String handling utilities
 func (d *decorator) scrambleStr(s string) {
 	if s == "" || d.hadError() {
 		return
 	}
-	c, b := 0, 0
-	e := len(s)
-
-	replace := func(with string) {
-		d.put(s[b:c])
-		c++
-		b = c
-		d.put(with)
-	}
+	d.buf = appendEscaped(d.buf[:0], s, &d.marshalOptions)
+	d.write(d.buf)
+}
 
-	for c != e {
+// appendEscaped appends s to dst with the characters that are not allowed in
+// JSON strings escaped, without the enclosing quotes, and with the additional
+// escaping of EscapeNonPrintable and EscapeNonASCII.
+func appendEscaped(dst []byte, s string, mo *marshalOptions) []byte {
+	nonPrint := mo.escapeNonPrint
+	b := 0
+	for c := 0; c < len(s); {
 		cp := s[c]
+		var esc string
 		switch cp {
 		case '\b':
-			replace("\\b")
+			esc = `\b`
 		case '\f':
-			replace("\\f")
+			esc = `\f`
 		case '\n':
-			replace("\\n")
+			esc = `\n`
 		case '\r':
-			replace("\\r")
+			esc = `\r`
 		case '\t':
-			replace("\\t")
+			esc = `\t`
 		case '\\':
-			replace("\\\\")
+			esc = `\\`
 		case '"':
-			replace("\\\"")
+			esc = `\"`
 		default:
-			if cp <= 0x0f {
-				with := []byte("\\u0000")
-				with[5] += cp
-				if cp >= 0x0a {
-					with[5] += 'a' - ':'
+			if cp >= utf8.RuneSelf && (nonPrint || mo.escapeNonASCII) {
+				r, size := utf8.DecodeRuneInString(s[c:])
+				if !mo.escapeNonASCII && (r == utf8.RuneError || unicode.IsPrint(r)) {
+					// invalid UTF-8 is copied through as is
+					c += size
+					continue
 				}
-				replace(string(with))
-			} else if cp <= 0x1f {
-				with := []byte("\\u0010")
-				with[5] += cp - 16
-				if cp >= 0x1a {
-					with[5] += 'a' - ':'
+				// for ASCII-only output, invalid UTF-8 becomes U+FFFD, which
+				// is what DecodeRuneInString returns for it
+				dst = append(dst, s[b:c]...)
+				if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+					dst = appendUnicodeEscape(dst, r1)
+					dst = appendUnicodeEscape(dst, r2)
+				} else {
+					dst = appendUnicodeEscape(dst, r)
 				}
-				replace(string(with))
-			} else {
+				c += size
+				b = c
+				continue
+			}
+			if cp > 0x1f && (cp != 0x7f || !nonPrint) {
 				c++
+				continue
 			}
 		}
+		dst = append(dst, s[b:c]...)
+		if esc != "" {
+			dst = append(dst, esc...)
+		} else {
+			dst = appendUnicodeEscape(dst, rune(cp))
+		}
+		c++
+		b = c
 	}
+	return append(dst, s[b:]...)
+}
 
-	if c != b {
-		d.put(s[b:c])
-	}
+// appendUnicodeEscape appends the \uXXXX escape for a rune from the BMP
+func appendUnicodeEscape(dst []byte, r rune) []byte {
+	const hex = "0123456789abcdef"
+	return append(dst, '\\', 'u', hex[r>>12&0xf], hex[r>>8&0xf], hex[r>>4&0xf], hex[r&0xf])
 }
 
 var (
@@ -401,4 +918,11 @@ var (
 	objMarshalerType  = reflect.TypeOf((*ObjMarshaler)(nil)).Elem()
 	arrMarshalerType  = reflect.TypeOf((*ArrMarshaler)(nil)).Elem()
 	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	numberType        = reflect.TypeOf(Number(""))
+	errorType         = reflect.TypeOf((*error)(nil)).Elem()
+	durationType      = reflect.TypeOf(time.Duration(0))
+	timeType          = reflect.TypeOf(time.Time{})
+	preciseType       = reflect.TypeOf(Precise{})
+	quotedType        = reflect.TypeOf(Quoted{})
 )
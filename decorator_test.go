@@ -212,3 +212,109 @@ func TestDecoratorErrorCases(t *testing.T) {
 		})
 	}
 }
+
+// memberSequenceMarshaler writes its members in order through ObjectWriter
+type memberSequenceMarshaler []any
+
+func (m memberSequenceMarshaler) MarshalJSN(w ObjectWriter) error {
+	for i, v := range m {
+		w.Member(fmt.Sprintf("m%d", i), v)
+	}
+	return nil
+}
+
+// elementSequenceMarshaler writes its elements in order through ArrayWriter
+type elementSequenceMarshaler []any
+
+func (m elementSequenceMarshaler) MarshalJSN(w ArrayWriter) error {
+	for _, v := range m {
+		w.Element(v)
+	}
+	return nil
+}
+
+func TestWritersAfterNestedError(t *testing.T) {
+	testErr := fmt.Errorf("nested error")
+	calls := 0
+	counted := func(w ObjectWriter) {
+		calls++
+		w.Member("x", 1)
+	}
+
+	tests := []struct {
+		name  string
+		input any
+		opts  []any
+		want  string // output up to the error point
+	}{
+		{
+			name:  "ObjMarshaler with failing member",
+			input: memberSequenceMarshaler{1, errorStrMarshaler{err: testErr}, counted, 3},
+			want:  `{"m0":1,"m1":`,
+		},
+		{
+			name:  "ObjMarshaler with failing nested object",
+			input: memberSequenceMarshaler{memberSequenceMarshaler{true, errorObjMarshaler{err: testErr}}, counted},
+			want:  `{"m0":{"m0":true,"m1":`,
+		},
+		{
+			name:  "ObjMarshaler with failing nested array",
+			input: memberSequenceMarshaler{elementSequenceMarshaler{"a", math.NaN(), counted}, counted},
+			want:  `{"m0":["a",`,
+		},
+		{
+			name:  "ArrMarshaler with failing element",
+			input: elementSequenceMarshaler{nil, errorArrMarshaler{err: testErr}, counted},
+			want:  `[null,`,
+		},
+		{
+			name: "func(ObjectWriter) error with failing member",
+			input: func(w ObjectWriter) error {
+				w.Member("a", errorStrMarshaler{err: testErr})
+				w.Member("b", counted)
+				return nil
+			},
+			want: `{"a":`,
+		},
+		{
+			name: "func(ArrayWriter) with failing element",
+			input: func(w ArrayWriter) {
+				w.Element(1)
+				w.Element(errorObjMarshaler{err: testErr})
+				w.Element(counted)
+			},
+			want: `[1,`,
+		},
+		{
+			name:  "sorted ObjMarshaler with failing member",
+			input: memberSequenceMarshaler{1, errorStrMarshaler{err: testErr}, counted},
+			opts:  []any{SortObjectWriterKeys{}},
+			want:  ``,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			mo, err := parseMarshalOptions(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sb strings.Builder
+			d := decorator{out: &sb, marshalOptions: mo}
+			d.marshalValue(tt.input)
+			if d.err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if got := sb.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+			if calls != 0 {
+				t.Errorf("%d values were marshaled after the error", calls)
+			}
+			if _, err := Marshal(tt.input, tt.opts...); err == nil {
+				t.Error("Marshal() expected an error, got nil")
+			}
+		})
+	}
+}
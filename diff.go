@@ -0,0 +1,267 @@
+package jsn
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Operations reported by Diff, named after their RFC 6902 (JSON Patch)
+// counterparts
+const (
+	DiffAdd     = "add"
+	DiffRemove  = "remove"
+	DiffReplace = "replace"
+)
+
+// DiffOp describes a single difference between two JSON documents.
+//
+// Path is a JSON Pointer (RFC 6901) to the affected value. OldValue is set for
+// remove and replace operations, NewValue for add and replace operations.
+type DiffOp struct {
+	Op       string
+	Path     string
+	OldValue any
+	NewValue any
+}
+
+// MarshalJSN writes the operation as an RFC 6902 JSON Patch operation, so that
+// marshaling the result of Diff produces a patch that transforms a into b.
+func (op DiffOp) MarshalJSN(w ObjectWriter) error {
+	w.Member("op", op.Op)
+	w.Member("path", op.Path)
+	if op.Op != DiffRemove {
+		w.Member("value", op.NewValue)
+	}
+	return nil
+}
+
+// Diff parses two JSON documents and returns the list of operations that
+// transform a into b.
+//
+// Object members are compared by key and reported in key order. Arrays are
+// compared index by index: common indices are diffed recursively, surplus
+// elements of a are removed starting from the end and surplus elements of b
+// are appended, so the operations can be applied sequentially.
+func Diff(a, b []byte) ([]DiffOp, error) {
+	va, err := Parse(a)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := Parse(b)
+	if err != nil {
+		return nil, err
+	}
+	return diffValues(nil, "", va, vb), nil
+}
+
+func diffValues(ops []DiffOp, path string, a, b any) []DiffOp {
+	switch a := a.(type) {
+	case map[string]any:
+		if b, ok := b.(map[string]any); ok {
+			return diffObjects(ops, path, a, b)
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			return diffArrays(ops, path, a, b)
+		}
+	}
+	if !Equal(a, b) {
+		ops = append(ops, DiffOp{Op: DiffReplace, Path: path, OldValue: a, NewValue: b})
+	}
+	return ops
+}
+
+func diffObjects(ops []DiffOp, path string, a, b map[string]any) []DiffOp {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := path + "/" + escapePointerToken(k)
+		va, inA := a[k]
+		vb, inB := b[k]
+		switch {
+		case !inB:
+			ops = append(ops, DiffOp{Op: DiffRemove, Path: p, OldValue: va})
+		case !inA:
+			ops = append(ops, DiffOp{Op: DiffAdd, Path: p, NewValue: vb})
+		default:
+			ops = diffValues(ops, p, va, vb)
+		}
+	}
+	return ops
+}
+
+func diffArrays(ops []DiffOp, path string, a, b []any) []DiffOp {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		ops = diffValues(ops, path+"/"+strconv.Itoa(i), a[i], b[i])
+	}
+	for i := len(a) - 1; i >= n; i-- {
+		ops = append(ops, DiffOp{Op: DiffRemove, Path: path + "/" + strconv.Itoa(i), OldValue: a[i]})
+	}
+	for i := n; i < len(b); i++ {
+		ops = append(ops, DiffOp{Op: DiffAdd, Path: path + "/" + strconv.Itoa(i), NewValue: b[i]})
+	}
+	return ops
+}
+
+// MarshalPatch marshals from and to (with the options of Marshal) and returns
+// an RFC 7386 JSON merge patch that turns the first document into the second,
+// e.g. for PATCH requests that only send what changed.
+//
+// The patch of two objects holds the members of to that were added or whose
+// value changed, changed nested objects as patches themselves, and null for
+// the members that were removed. Other values are replaced as a whole, so the
+// patch is to itself. As merge patches use null for removal, a member whose
+// value changes to null is removed by the patch instead, and arrays are
+// always replaced completely. Numbers are compared by their marshaled text.
+// OmitNullMembers omits null values from the documents, not the removals.
+func MarshalPatch(from, to any, opts ...any) (string, error) {
+	a, err := marshalTree(from, opts)
+	if err != nil {
+		return "", err
+	}
+	b, err := marshalTree(to, opts)
+	if err != nil {
+		return "", err
+	}
+	// the nulls of the patch are removals, OmitNullMembers only applies to
+	// the values
+	out := []any{PreserveNumbers{}}
+	for _, opt := range opts {
+		if _, ok := opt.(OmitNullMembers); !ok {
+			out = append(out, opt)
+		}
+	}
+	return Marshal(mergePatch(a, b), out...)
+}
+
+// marshalTree converts a Go value into the tree it marshals to
+func marshalTree(v any, opts []any) (any, error) {
+	text, err := Marshal(v, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return Parse([]byte(text), ScannerFlagUseNumber)
+}
+
+// mergePatch returns the RFC 7386 merge patch that turns a into b
+func mergePatch(a, b any) any {
+	oa, ok := a.(map[string]any)
+	if !ok {
+		return b
+	}
+	ob, ok := b.(map[string]any)
+	if !ok {
+		return b
+	}
+	patch := map[string]any{}
+	for k := range oa {
+		if _, ok := ob[k]; !ok {
+			patch[k] = nil
+		}
+	}
+	for k, vb := range ob {
+		va, ok := oa[k]
+		switch {
+		case !ok:
+			patch[k] = vb
+		case isObject(va) && isObject(vb):
+			if p := mergePatch(va, vb).(map[string]any); len(p) != 0 {
+				patch[k] = p
+			}
+		case !Equal(va, vb):
+			patch[k] = vb
+		}
+	}
+	return patch
+}
+
+// ApplyMergePatch applies an RFC 7386 JSON merge patch to a tree produced by
+// ReadValue and returns the result: an object patch is merged into the
+// document member by member, recursively, where null removes the member and
+// objects are merged into the existing value (an empty object if it is not an
+// object). Any other patch replaces the document as a whole.
+//
+// The document is not modified, the result shares the unchanged parts of the
+// document and the values of the patch.
+func ApplyMergePatch(doc, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	target, _ := doc.(map[string]any)
+	result := make(map[string]any, len(target)+len(p))
+	for k, v := range target {
+		result[k] = v
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(result, k)
+		} else {
+			result[k] = ApplyMergePatch(result[k], v)
+		}
+	}
+	return result
+}
+
+func isObject(v any) bool {
+	_, ok := v.(map[string]any)
+	return ok
+}
+
+// escapePointerToken escapes a reference token for use in a JSON Pointer
+func escapePointerToken(s string) string {
+	if !strings.ContainsAny(s, "~/") {
+		return s
+	}
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// Equal reports whether two trees produced by ReadValue are equal. Objects are
+// compared regardless of member order, values of types that are not part of
+// the tree representation are never equal.
+func Equal(a, b any) bool {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, va := range a {
+			vb, ok := b[k]
+			if !ok || !Equal(va, vb) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !Equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case nil:
+		return b == nil
+	case bool, float64, string, Number:
+		return a == b
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+package jsn
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []DiffOp
+	}{
+		{name: "equal scalars", a: `1`, b: `1.0`, want: nil},
+		{name: "equal objects", a: `{"a":1,"b":[1,2]}`, b: `{"b":[1,2],"a":1}`, want: nil},
+		{
+			name: "root replace",
+			a:    `1`, b: `"one"`,
+			want: []DiffOp{{Op: DiffReplace, Path: "", OldValue: float64(1), NewValue: "one"}},
+		},
+		{
+			name: "object members",
+			a:    `{"a":1,"b":2,"c":3}`, b: `{"b":2,"c":4,"d":5}`,
+			want: []DiffOp{
+				{Op: DiffRemove, Path: "/a", OldValue: float64(1)},
+				{Op: DiffReplace, Path: "/c", OldValue: float64(3), NewValue: float64(4)},
+				{Op: DiffAdd, Path: "/d", NewValue: float64(5)},
+			},
+		},
+		{
+			name: "type change",
+			a:    `{"a":{"x":1}}`, b: `{"a":[1]}`,
+			want: []DiffOp{{Op: DiffReplace, Path: "/a", OldValue: map[string]any{"x": float64(1)}, NewValue: []any{float64(1)}}},
+		},
+		{
+			name: "array shrink",
+			a:    `[1,2,3,4]`, b: `[1,5]`,
+			want: []DiffOp{
+				{Op: DiffReplace, Path: "/1", OldValue: float64(2), NewValue: float64(5)},
+				{Op: DiffRemove, Path: "/3", OldValue: float64(4)},
+				{Op: DiffRemove, Path: "/2", OldValue: float64(3)},
+			},
+		},
+		{
+			name: "array grow",
+			a:    `[1]`, b: `[1,2,3]`,
+			want: []DiffOp{
+				{Op: DiffAdd, Path: "/1", NewValue: float64(2)},
+				{Op: DiffAdd, Path: "/2", NewValue: float64(3)},
+			},
+		},
+		{
+			name: "nested arrays of objects",
+			a:    `{"orders":[{"id":"A123","total":50},{"id":"B456","total":30}]}`,
+			b:    `{"orders":[{"id":"A123","total":55},{"id":"B456","total":30,"paid":true}]}`,
+			want: []DiffOp{
+				{Op: DiffReplace, Path: "/orders/0/total", OldValue: float64(50), NewValue: float64(55)},
+				{Op: DiffAdd, Path: "/orders/1/paid", NewValue: true},
+			},
+		},
+		{
+			name: "pointer escaping",
+			a:    `{"a/b":1,"m~n":null}`, b: `{"a/b":2}`,
+			want: []DiffOp{
+				{Op: DiffReplace, Path: "/a~1b", OldValue: float64(1), NewValue: float64(2)},
+				{Op: DiffRemove, Path: "/m~0n", OldValue: nil},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Diff([]byte(tt.a), []byte(tt.b))
+			if err != nil {
+				t.Errorf("Diff() unexpected error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffErrors(t *testing.T) {
+	if _, err := Diff([]byte(`{`), []byte(`{}`)); err != ErrUnexpectedEOF {
+		t.Errorf("Diff() error = %v, want %v", err, ErrUnexpectedEOF)
+	}
+	if _, err := Diff([]byte(`{}`), []byte(`{} x`)); err != ErrUnexpectedToken {
+		t.Errorf("Diff() error = %v, want %v", err, ErrUnexpectedToken)
+	}
+}
+
+func TestDiffMarshalPatch(t *testing.T) {
+	ops, err := Diff([]byte(`{"a":1,"b":[1,2]}`), []byte(`{"b":[1],"c":"x"}`))
+	if err != nil {
+		t.Fatalf("Diff() unexpected error = %v", err)
+	}
+	got, err := Marshal(ops)
+	if err != nil {
+		t.Fatalf("Marshal() unexpected error = %v", err)
+	}
+	want := `[{"op":"remove","path":"/a"},{"op":"remove","path":"/b/1"},{"op":"add","path":"/c","value":"x"}]`
+	if got != want {
+		t.Errorf("Marshal() = %v, want %v", got, want)
+	}
+}
+
+type patchUser struct {
+	Name  string
+	Email *string
+	Tags  []string
+}
+
+func (u patchUser) MarshalJSN(w ObjectWriter) error {
+	w.Member("name", u.Name)
+	w.Member("email", u.Email)
+	w.Member("tags", u.Tags)
+	return nil
+}
+
+func TestMarshalPatch(t *testing.T) {
+	email := "a@example.com"
+	tests := []struct {
+		name     string
+		from, to any
+		opts     []any
+		want     string
+	}{
+		{name: "unchanged", from: map[string]any{"a": 1}, to: map[string]int{"a": 1}, want: `{}`},
+		{name: "changed member", from: map[string]any{"a": 1, "b": "x"}, to: map[string]any{"a": 2, "b": "x"}, want: `{"a":2}`},
+		{name: "added and removed", from: map[string]any{"a": 1}, to: map[string]any{"b": true}, want: `{"a":null,"b":true}`},
+		{name: "nested objects", from: map[string]any{"o": map[string]any{"x": 1, "y": 2}, "p": map[string]any{"z": 1}},
+			to: map[string]any{"o": map[string]any{"x": 1, "y": 3}, "p": map[string]any{"z": 1}}, want: `{"o":{"y":3}}`},
+		{name: "arrays replaced", from: map[string]any{"a": []int{1, 2}}, to: map[string]any{"a": []int{1}}, want: `{"a":[1]}`},
+		{name: "object replaced by scalar", from: map[string]any{"a": map[string]any{}}, to: map[string]any{"a": 1}, want: `{"a":1}`},
+		{name: "non-objects", from: []int{1}, to: "x", want: `"x"`},
+		{name: "marshalers", from: patchUser{Name: "a", Tags: []string{"t"}}, to: patchUser{Name: "a", Email: &email, Tags: []string{"t"}},
+			want: `{"email":"a@example.com"}`},
+		{name: "to null removes", from: patchUser{Name: "a", Email: &email}, to: patchUser{Name: "a"}, want: `{"email":null}`},
+		{name: "removals with OmitNullMembers", from: patchUser{Name: "a", Email: &email}, to: patchUser{Name: "b"},
+			opts: []any{OmitNullMembers{}}, want: `{"email":null,"name":"b"}`},
+		{name: "precision", from: map[string]any{"f": 1.0001}, to: map[string]any{"f": 1.0002}, opts: []any{FloatPrecision{Precision: 3}},
+			want: `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MarshalPatch(tt.from, tt.to, tt.opts...)
+			if err != nil {
+				t.Fatalf("MarshalPatch() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("MarshalPatch() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := MarshalPatch(map[string]any{}, make(chan int)); err == nil {
+		t.Error("MarshalPatch() expected error for unsupported type, got nil")
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	// the examples of RFC 7386, appendix A
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{doc: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{doc: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{doc: `{"a":"b"}`, patch: `{"a":null}`, want: `{}`},
+		{doc: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{doc: `{"a":["b"]}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{doc: `{"a":"c"}`, patch: `{"a":["b"]}`, want: `{"a":["b"]}`},
+		{doc: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, want: `{"a":{"b":"d"}}`},
+		{doc: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, want: `{"a":[1]}`},
+		{doc: `["a","b"]`, patch: `["c","d"]`, want: `["c","d"]`},
+		{doc: `{"a":"b"}`, patch: `["c"]`, want: `["c"]`},
+		{doc: `{"a":"foo"}`, patch: `null`, want: `null`},
+		{doc: `{"a":"foo"}`, patch: `"bar"`, want: `"bar"`},
+		{doc: `{"e":null}`, patch: `{"a":1}`, want: `{"e":null,"a":1}`},
+		{doc: `[1,2]`, patch: `{"a":"b","c":null}`, want: `{"a":"b"}`},
+		{doc: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, want: `{"a":{"bb":{}}}`},
+		// removing a missing member, and a null member of the document
+		{doc: `{"a":1}`, patch: `{"b":null}`, want: `{"a":1}`},
+		{doc: `{"a":null}`, patch: `{"a":null}`, want: `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.doc+" "+tt.patch, func(t *testing.T) {
+			doc, _ := Parse([]byte(tt.doc))
+			patch, _ := Parse([]byte(tt.patch))
+			want, _ := Parse([]byte(tt.want))
+			before, _ := Parse([]byte(tt.doc))
+
+			got := ApplyMergePatch(doc, patch)
+			if !Equal(got, want) {
+				t.Errorf("ApplyMergePatch() = %v, want %v", got, want)
+			}
+			if !Equal(doc, before) {
+				t.Errorf("ApplyMergePatch() modified the document: %v", doc)
+			}
+		})
+	}
+}
+
+func TestMarshalPatchApply(t *testing.T) {
+	from := map[string]any{"a": 1, "b": map[string]any{"c": []any{1, 2}, "d": "x"}, "e": true}
+	to := map[string]any{"a": 1, "b": map[string]any{"c": []any{2}, "f": nil}, "g": "y"}
+	patch, err := MarshalPatch(from, to, OmitNullMembers{})
+	if err != nil {
+		t.Fatalf("MarshalPatch() unexpected error = %v", err)
+	}
+	p, _ := Parse([]byte(patch), ScannerFlagUseNumber)
+	doc, _ := marshalTree(from, nil)
+	want, _ := marshalTree(to, []any{OmitNullMembers{}})
+	if got := ApplyMergePatch(doc, p); !reflect.DeepEqual(got, want) {
+		t.Errorf("ApplyMergePatch(MarshalPatch()) = %v, want %v", got, want)
+	}
+}
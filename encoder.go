@@ -0,0 +1,67 @@
+package jsn
+
+import (
+	"bytes"
+	"io"
+)
+
+// Encoder writes a stream of JSON values to an io.Writer, each followed by a
+// newline, which produces newline-delimited JSON.
+//
+// Each value is marshaled into an internal buffer and written with a single
+// call, so a value that fails to marshal leaves nothing in the stream. The
+// buffer and the rest of the encoder state are kept between values, and Reset
+// retargets the encoder at another writer, so that encoders can be pooled,
+// e.g. in servers encoding many responses:
+//
+//	var encoders = sync.Pool{New: func() any { return jsn.NewEncoder(nil) }}
+//
+//	func respond(w http.ResponseWriter, v any) error {
+//	    e := encoders.Get().(*jsn.Encoder)
+//	    defer encoders.Put(e)
+//	    e.Reset(w)
+//	    return e.Encode(v)
+//	}
+//
+// An Encoder is not safe for concurrent use.
+type Encoder struct {
+	w      io.Writer
+	out    bytes.Buffer
+	d      decorator
+	optErr error
+}
+
+// NewEncoder creates an encoder writing to w, with the options of Marshal
+func NewEncoder(w io.Writer, opts ...any) *Encoder {
+	e := &Encoder{w: w}
+	e.d.marshalOptions, e.optErr = parseMarshalOptions(opts)
+	e.d.trailingNL = true
+	return e
+}
+
+// Reset makes the encoder write to w, discarding any error of a previous
+// Encode. The options given to NewEncoder are kept.
+func (e *Encoder) Reset(w io.Writer) {
+	e.w = w
+	e.out.Reset()
+	e.d.err = nil
+	e.d.depth = 0
+}
+
+// Encode writes v to the stream, followed by a newline. Errors of the options
+// passed to NewEncoder are returned by every call.
+func (e *Encoder) Encode(v any) error {
+	if e.optErr != nil {
+		return e.optErr
+	}
+	e.out.Reset()
+	e.d.out = &e.out
+	e.d.err = nil
+	e.d.depth = 0
+	e.d.marshalDocument(v)
+	if e.d.err != nil {
+		return e.d.err
+	}
+	_, err := e.w.Write(e.out.Bytes())
+	return err
+}
@@ -0,0 +1,71 @@
+package jsn
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEncoder(t *testing.T) {
+	var sb strings.Builder
+	e := NewEncoder(&sb, FloatPrecision{Precision: 2})
+	for _, v := range []any{map[string]any{"a": 1.234}, []int{1, 2}, "x"} {
+		if err := e.Encode(v); err != nil {
+			t.Fatalf("Encode() unexpected error = %v", err)
+		}
+	}
+	if want := "{\"a\":1.2}\n[1,2]\n\"x\"\n"; sb.String() != want {
+		t.Errorf("Encode() = %q, want %q", sb.String(), want)
+	}
+
+	// a failed value leaves nothing in the stream, and does not stick
+	if err := e.Encode([]any{1, make(chan int)}); err == nil {
+		t.Error("Encode() expected error for unsupported type, got nil")
+	}
+	if err := e.Encode(true); err != nil {
+		t.Fatalf("Encode() unexpected error = %v", err)
+	}
+	if want := "{\"a\":1.2}\n[1,2]\n\"x\"\ntrue\n"; sb.String() != want {
+		t.Errorf("Encode() = %q, want %q", sb.String(), want)
+	}
+}
+
+func TestEncoderReset(t *testing.T) {
+	testErr := fmt.Errorf("test error")
+	e := NewEncoder(&errorWriter{err: testErr}, SortObjectWriterKeys{})
+	if err := e.Encode(1); err != testErr {
+		t.Errorf("Encode() error = %v, want %v", err, testErr)
+	}
+
+	var sb strings.Builder
+	e.Reset(&sb)
+	v := func(w ObjectWriter) {
+		w.Member("b", 2)
+		w.Member("a", 1)
+	}
+	if err := e.Encode(v); err != nil {
+		t.Fatalf("Encode() unexpected error = %v", err)
+	}
+	if want := "{\"a\":1,\"b\":2}\n"; sb.String() != want {
+		t.Errorf("Encode() = %q, want %q", sb.String(), want)
+	}
+
+	e = NewEncoder(&sb, FloatPrecision{Precision: -1})
+	if err := e.Encode(1); err == nil {
+		t.Error("Encode() expected error for invalid option, got nil")
+	}
+}
+
+func BenchmarkEncoderPooled(b *testing.B) {
+	v := map[string]any{"id": 1, "name": "x", "tags": []string{"a", "b"}}
+	var sb strings.Builder
+	e := NewEncoder(&sb)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sb.Reset()
+		e.Reset(&sb)
+		if err := e.Encode(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package jsn
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GoLiteral returns Go source code for an expression that reconstructs a tree
+// produced by ReadValue, e.g. map[string]any{"a": float64(1)}. It is meant for
+// generating test fixtures from real payloads.
+//
+// Object members are emitted sorted by key, numbers are typed explicitly so
+// that they remain float64 (or Number, qualified as jsn.Number) when the
+// expression is assigned to an any, and empty arrays distinguish between nil
+// and non-nil slices. Values of types that are not part of the tree
+// representation are rejected with UnsupportedTypeError.
+func GoLiteral(v any) (string, error) {
+	var b strings.Builder
+	if err := writeGoLiteral(&b, v); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func writeGoLiteral(b *strings.Builder, v any) error {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("nil")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case string:
+		b.WriteString(strconv.Quote(v))
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return fmt.Errorf("unsupported float value: %v", v)
+		}
+		b.WriteString("float64(")
+		b.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		b.WriteString(")")
+	case Number:
+		b.WriteString("jsn.Number(")
+		b.WriteString(strconv.Quote(string(v)))
+		b.WriteString(")")
+	case []any:
+		if v == nil {
+			b.WriteString("[]any(nil)")
+			return nil
+		}
+		b.WriteString("[]any{")
+		for i, e := range v {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			if err := writeGoLiteral(b, e); err != nil {
+				return err
+			}
+		}
+		b.WriteString("}")
+	case map[string]any:
+		if v == nil {
+			b.WriteString("map[string]any(nil)")
+			return nil
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteString("map[string]any{")
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(strconv.Quote(k))
+			b.WriteString(": ")
+			if err := writeGoLiteral(b, v[k]); err != nil {
+				return err
+			}
+		}
+		b.WriteString("}")
+	default:
+		return &UnsupportedTypeError{reflect.TypeOf(v)}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package jsn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGoLiteral(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   any
+		want    string
+		wantErr bool
+	}{
+		{name: "null", input: nil, want: `nil`},
+		{name: "bool", input: true, want: `true`},
+		{name: "number", input: float64(1), want: `float64(1)`},
+		{name: "fraction", input: -0.5, want: `float64(-0.5)`},
+		{name: "exponent", input: 1e21, want: `float64(1e+21)`},
+		{name: "Number", input: Number("1.50"), want: `jsn.Number("1.50")`},
+		{name: "string", input: "a\"b\\c\né\x01", want: `"a\"b\\c\né\x01"`},
+		{name: "empty array", input: []any(nil), want: `[]any(nil)`},
+		{name: "non-nil empty array", input: []any{}, want: `[]any{}`},
+		{name: "empty object", input: map[string]any{}, want: `map[string]any{}`},
+		{
+			name:  "nested",
+			input: map[string]any{"b": []any{float64(1), "x", nil}, "a": map[string]any{"c": false}},
+			want:  `map[string]any{"a": map[string]any{"c": false}, "b": []any{float64(1), "x", nil}}`,
+		},
+		{name: "escaped key", input: map[string]any{"\"k\"": true}, want: `map[string]any{"\"k\"": true}`},
+		{name: "unsupported", input: []any{1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GoLiteral(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GoLiteral() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GoLiteral() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	var ute *UnsupportedTypeError
+	if _, err := GoLiteral(map[string]any{"a": int64(1)}); !errors.As(err, &ute) {
+		t.Errorf("GoLiteral() error = %v, want UnsupportedTypeError", err)
+	}
+}
@@ -0,0 +1,39 @@
+// Package jsntest provides helpers for testing code that exchanges data
+// through the jsn package.
+package jsntest
+
+import (
+	"testing"
+
+	"github.com/adnsv/jsn"
+)
+
+// AssertRoundTrip verifies that data survives a round trip through the jsn
+// package: it is parsed, marshaled back and parsed again, and both trees must
+// be equal according to jsn.Equal. Failures are reported through t.
+//
+// The options are passed to jsn.Parse. Numbers are marshaled with the
+// jsn.PreserveNumbers option, so that any float64 (or jsn.Number, with
+// jsn.ScannerFlagUseNumber) is reproduced exactly.
+func AssertRoundTrip(t testing.TB, data []byte, opts ...any) {
+	t.Helper()
+
+	v, err := jsn.Parse(data, opts...)
+	if err != nil {
+		t.Errorf("jsntest: parse %q: %v", data, err)
+		return
+	}
+	out, err := jsn.Marshal(v, jsn.PreserveNumbers{})
+	if err != nil {
+		t.Errorf("jsntest: marshal %q: %v", data, err)
+		return
+	}
+	w, err := jsn.Parse([]byte(out), opts...)
+	if err != nil {
+		t.Errorf("jsntest: parse re-marshaled %q: %v", out, err)
+		return
+	}
+	if !jsn.Equal(v, w) {
+		t.Errorf("jsntest: round trip of %q produced %q with a different value", data, out)
+	}
+}
@@ -0,0 +1,57 @@
+package jsntest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/adnsv/jsn"
+)
+
+// recorder captures failures reported by the helpers under test
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertRoundTrip(t *testing.T) {
+	inputs := []string{
+		`null`,
+		`{"name":"John","tags":["a","b"],"nested":{"x":[1,2.5,-3e-7]}}`,
+		`[0.1, 1e300, 5e-324, 9007199254740993]`,
+		`"é𝄞\n\"quoted\""`,
+		"[\"a\x7fa\"]",
+	}
+	for _, input := range inputs {
+		AssertRoundTrip(t, []byte(input))
+		AssertRoundTrip(t, []byte(input), jsn.ScannerFlagUseNumber)
+	}
+
+	// out of float64 range, only representable as jsn.Number
+	AssertRoundTrip(t, []byte(`[1e400, -0, 1.50]`), jsn.ScannerFlagUseNumber)
+}
+
+func TestAssertRoundTripFailure(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "invalid json", input: `{"a":}`},
+		{name: "trailing data", input: `1 2`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			AssertRoundTrip(r, []byte(tt.input))
+			if len(r.errors) != 1 {
+				t.Errorf("AssertRoundTrip() reported %d errors, want 1", len(r.errors))
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package jsn
+
+// Kind is the kind of a JSON value
+type Kind int
+
+const (
+	KindInvalid Kind = iota // returned along with errors
+	KindObject
+	KindArray
+	KindString
+	KindNumber
+	KindBool
+	KindNull
+)
+
+var kindNames = [...]string{
+	KindInvalid: "invalid",
+	KindObject:  "object",
+	KindArray:   "array",
+	KindString:  "string",
+	KindNumber:  "number",
+	KindBool:    "boolean",
+	KindNull:    "null",
+}
+
+func (k Kind) String() string {
+	if k >= 0 && int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return "invalid"
+}
+
+// PeekKind skips whitespace and classifies the value that follows by its first
+// byte, without consuming it, e.g. to choose between ReadObject and ReadArray.
+// The value itself is not validated, reading it may still fail. At the end of
+// input it returns ErrUnexpectedEOF, bytes that cannot start a value fail with
+// ErrUnexpectedToken.
+func PeekKind(s *Scanner) (Kind, error) {
+	s.skipWhitespace()
+	if s.IsEOF() {
+		return KindInvalid, ErrUnexpectedEOF
+	}
+	switch s.peek() {
+	case '{':
+		return KindObject, nil
+	case '[':
+		return KindArray, nil
+	case '"':
+		return KindString, nil
+	case 't', 'f':
+		return KindBool, nil
+	case 'n':
+		return KindNull, nil
+	case '+', '.':
+		if s.flags&ScannerFlagAllowJSON5Numbers == 0 {
+			return KindInvalid, s.unexpectedToken()
+		}
+		return KindNumber, nil
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return KindNumber, nil
+	}
+	return KindInvalid, s.unexpectedToken()
+}
+
+// KindOf returns the kind of a value of the tree produced by ReadValue, or
+// KindInvalid for values of other types
+func KindOf(v any) Kind {
+	switch v.(type) {
+	case map[string]any:
+		return KindObject
+	case []any:
+		return KindArray
+	case string:
+		return KindString
+	case float64, Number:
+		return KindNumber
+	case bool:
+		return KindBool
+	case nil:
+		return KindNull
+	}
+	return KindInvalid
+}
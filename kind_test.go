@@ -0,0 +1,58 @@
+package jsn
+
+import "testing"
+
+func TestPeekKind(t *testing.T) {
+	tests := []struct {
+		input   string
+		opts    []any
+		want    Kind
+		wantErr error
+	}{
+		{input: ` {"a":1}`, want: KindObject},
+		{input: "\n[1]", want: KindArray},
+		{input: `"s"`, want: KindString},
+		{input: `-1`, want: KindNumber},
+		{input: `0`, want: KindNumber},
+		{input: `true`, want: KindBool},
+		{input: `false`, want: KindBool},
+		{input: `null`, want: KindNull},
+		{input: `.5`, opts: []any{ScannerFlagAllowJSON5Numbers}, want: KindNumber},
+		{input: `.5`, wantErr: ErrUnexpectedToken},
+		{input: `}`, wantErr: ErrUnexpectedToken},
+		{input: ` `, wantErr: ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			s := NewScanner([]byte(tt.input), tt.opts...)
+			got, err := PeekKind(s)
+			if err != tt.wantErr || got != tt.want {
+				t.Fatalf("PeekKind() = %v, %v, want %v, %v", got, err, tt.want, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			// nothing is consumed but whitespace
+			v, err := ReadValue(s)
+			if err != nil {
+				t.Fatalf("ReadValue() unexpected error = %v", err)
+			}
+			if k := KindOf(v); k != tt.want {
+				t.Errorf("KindOf() = %v, want %v", k, tt.want)
+			}
+		})
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	if k := KindOf(Number("1")); k != KindNumber {
+		t.Errorf("KindOf(Number) = %v, want %v", k, KindNumber)
+	}
+	if k := KindOf(1); k != KindInvalid || k.String() != "invalid" {
+		t.Errorf("KindOf(int) = %v, want %v", k, KindInvalid)
+	}
+	if s := Kind(-1).String(); s != "invalid" {
+		t.Errorf("Kind(-1).String() = %q, want %q", s, "invalid")
+	}
+}
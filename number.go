@@ -0,0 +1,64 @@
+package jsn
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Number is a JSON number kept as its source text. ReadValue produces Number
+// values instead of float64 when the scanner is created with
+// ScannerFlagUseNumber, which preserves integers beyond 2^53, values outside
+// the float64 range and the original formatting.
+//
+// Marshal emits a Number as a JSON number: verbatim with the PreserveNumbers
+// option, otherwise formatted like float64 values.
+type Number string
+
+// String returns the source text of the number
+func (n Number) String() string {
+	return string(n)
+}
+
+// Float64 returns the number as float64
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// Int64 returns the number as int64, it fails for numbers that have a fraction
+// or an exponent
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(n), 10, 64)
+}
+
+// IsInt reports whether the number was written without a fraction or an
+// exponent, e.g. 1 rather than 1.0 or 1e0, so that APIs can echo integers and
+// floats back in their original form. It says nothing about the magnitude, see
+// Int64 for a range-checked conversion.
+func (n Number) IsInt() bool {
+	return n != "" && !strings.ContainsAny(string(n), ".eE")
+}
+
+// isValidNumber reports whether str is a single JSON number
+func isValidNumber(str string) bool {
+	s := Scanner{data: []byte(str)}
+	_, err := s.scanNumber()
+	return err == nil && s.IsEOF()
+}
+
+// trimLeadingZeros removes the leading zeros accepted with
+// ScannerFlagAllowLeadingZeros from a number token, so that it can be emitted
+// as valid JSON
+func trimLeadingZeros(token []byte) []byte {
+	i := 0
+	if len(token) > 0 && token[0] == '-' {
+		i = 1
+	}
+	j := i
+	for j+1 < len(token) && token[j] == '0' && token[j+1] >= '0' && token[j+1] <= '9' {
+		j++
+	}
+	if j == i {
+		return token
+	}
+	return append(token[:i:i], token[j:]...)
+}
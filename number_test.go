@@ -0,0 +1,198 @@
+package jsn
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNumber(t *testing.T) {
+	n := Number("9007199254740993")
+	if i, err := n.Int64(); err != nil || i != 9007199254740993 {
+		t.Errorf("Int64() = %v, %v, want %v", i, err, int64(9007199254740993))
+	}
+	if f, err := n.Float64(); err != nil || f != 9007199254740992 {
+		t.Errorf("Float64() = %v, %v, want %v", f, err, 9007199254740992.0)
+	}
+	if _, err := Number("1.5").Int64(); err == nil {
+		t.Error("Int64() of a fraction expected an error")
+	}
+	if n.String() != "9007199254740993" {
+		t.Errorf("String() = %q", n.String())
+	}
+}
+
+func TestNumberIsInt(t *testing.T) {
+	tests := []struct {
+		n    Number
+		want bool
+	}{
+		{"1", true},
+		{"-0", true},
+		{"123456789012345678901234567890", true},
+		{"1.0", false},
+		{"1e0", false},
+		{"1E+2", false},
+		{"-0.5", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := tt.n.IsInt(); got != tt.want {
+			t.Errorf("Number(%q).IsInt() = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+
+	v, err := Parse([]byte(`[1, 1.0]`), ScannerFlagUseNumber)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if a := v.([]any); !a[0].(Number).IsInt() || a[1].(Number).IsInt() {
+		t.Errorf("IsInt() does not tell %v from %v", a[0], a[1])
+	}
+}
+
+func TestScannerUseNumber(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    []any
+		want    any
+		wantErr error
+	}{
+		{name: "integer", input: `9007199254740993`, want: Number("9007199254740993")},
+		{name: "formatting kept", input: `[1.50, -0, 1E+2]`, want: []any{Number("1.50"), Number("-0"), Number("1E+2")}},
+		{name: "out of float64 range", input: `1e400`, want: Number("1e400")},
+		{name: "nested", input: `{"a":{"b":[0.1]}}`, want: map[string]any{"a": map[string]any{"b": []any{Number("0.1")}}}},
+		{name: "invalid syntax", input: `[1.]`, wantErr: ErrInvalidNumber},
+		{name: "leading zeros rejected", input: `012`, wantErr: ErrInvalidNumber},
+		{name: "leading zeros trimmed", input: `[012, -007.5, 0, -0]`, opts: []any{ScannerFlagAllowLeadingZeros},
+			want: []any{Number("12"), Number("-7.5"), Number("0"), Number("-0")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.input), append(tt.opts, ScannerFlagUseNumber)...)
+			if err != tt.wantErr {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// point3 returns 0.1+0.2 computed at run time, which is not exactly 0.3
+func point3() float64 {
+	a, b := 0.1, 0.2
+	return a + b
+}
+
+func TestMarshalNumber(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   any
+		opts    []any
+		want    string
+		wantErr bool
+	}{
+		{name: "formatted", input: Number("1.50"), want: `1.5`},
+		{name: "formatted precision", input: Number("3.14159265"), opts: []any{FloatPrecision{Precision: 3}}, want: `3.14`},
+		{name: "formatted out of range", input: Number("1e400"), wantErr: true},
+		{name: "preserved", input: Number("1.50"), opts: []any{PreserveNumbers{}}, want: `1.50`},
+		{name: "preserved out of range", input: Number("1e400"), opts: []any{PreserveNumbers{}}, want: `1e400`},
+		{name: "preserved pointer", input: func() *Number { n := Number("-0"); return &n }(), opts: []any{PreserveNumbers{}}, want: `-0`},
+		{name: "invalid", input: Number("01"), opts: []any{PreserveNumbers{}}, wantErr: true},
+		{name: "empty", input: Number(""), wantErr: true},
+		{name: "float shortest", input: point3(), opts: []any{PreserveNumbers{}}, want: `0.30000000000000004`},
+		{name: "float large", input: 1e21, opts: []any{PreserveNumbers{}}, want: `1e+21`},
+		{name: "float ignores precision", input: 3.14159265, opts: []any{PreserveNumbers{}, FloatPrecision{Precision: 2}}, want: `3.14159265`},
+		{name: "float integral", input: 1e15, opts: []any{PreserveNumbers{}, IntegralFloats{}}, want: `1000000000000000`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Marshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreserveNumbersRoundTrip(t *testing.T) {
+	for _, tt := range NSTTestSuiteData {
+		if tt.Kind == "n" || !strings.HasPrefix(tt.Name, "number") {
+			continue
+		}
+		t.Run(tt.Name, func(t *testing.T) {
+			v, err := Parse([]byte(tt.Content), ScannerFlagUseNumber)
+			if err != nil {
+				t.Fatalf("Parse() unexpected error = %v", err)
+			}
+			got, err := Marshal(v, PreserveNumbers{})
+			if err != nil {
+				t.Fatalf("Marshal() unexpected error = %v", err)
+			}
+
+			// CopyValue produces the compact form with numbers copied verbatim
+			var want strings.Builder
+			if err := CopyValue(&want, NewScanner([]byte(tt.Content))); err != nil {
+				t.Fatalf("CopyValue() unexpected error = %v", err)
+			}
+			if got != want.String() {
+				t.Errorf("Marshal() = %q, want %q", got, want.String())
+			}
+		})
+	}
+}
+
+func TestDecodeNumber(t *testing.T) {
+	var target struct {
+		I   int64   `jsn:"i"`
+		U   uint64  `jsn:"u"`
+		F   float32 `jsn:"f"`
+		E   int     `jsn:"e"`
+		N   Number  `jsn:"n"`
+		M   Number  `jsn:"m"`
+		Any any     `jsn:"any"`
+	}
+	tree, err := Parse([]byte(`{"i":9007199254740993,"u":18446744073709551615,"f":1.5,"e":1e3,"n":1.50,"any":2}`), ScannerFlagUseNumber)
+	if err != nil {
+		t.Fatalf("Parse() unexpected error = %v", err)
+	}
+	tree.(map[string]any)["m"] = 0.25
+	if err := Decode(tree, &target); err != nil {
+		t.Fatalf("Decode() unexpected error = %v", err)
+	}
+	if target.I != 9007199254740993 || target.U != 18446744073709551615 || target.F != 1.5 || target.E != 1000 ||
+		target.N != "1.50" || target.M != "0.25" || target.Any != Number("2") {
+		t.Errorf("Decode() = %+v", target)
+	}
+
+	tests := []struct {
+		input   string
+		target  any
+		wantErr error
+	}{
+		{input: `9223372036854775808`, target: new(int64), wantErr: ErrNumericValueOutOfRange},
+		{input: `-1`, target: new(uint), wantErr: ErrNumericValueOutOfRange},
+		{input: `300`, target: new(uint8), wantErr: ErrNumericValueOutOfRange},
+		{input: `1.5`, target: new(int), wantErr: ErrNumberNotInteger},
+		{input: `1e400`, target: new(float64), wantErr: ErrNumericValueOutOfRange},
+		{input: `"1"`, target: new(int), wantErr: ErrTypeMismatch},
+	}
+	for _, tt := range tests {
+		tree, err := Parse([]byte(tt.input), ScannerFlagUseNumber)
+		if err != nil {
+			t.Fatalf("Parse(%s) unexpected error = %v", tt.input, err)
+		}
+		if err := Decode(tree, tt.target); !errors.Is(err, tt.wantErr) {
+			t.Errorf("Decode(%s) error = %v, want %v", tt.input, err, tt.wantErr)
+		}
+	}
+}
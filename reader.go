@@ -1,8 +1,12 @@
 package jsn
 
+import "strconv"
+
 // ReadObjectCallback reads a JSON object and invokes the callback function for each key-value pair.
 // The callback receives the key as a string and the value as an interface{}.
 // This allows for memory-efficient processing of JSON objects without storing the entire structure.
+// Errors returned by the callback are passed through unchanged, unless the scanner is created with
+// ScannerFlagWrapCallbackErrors, which wraps them in a *MemberError.
 //
 // Example:
 //
@@ -13,40 +17,127 @@ package jsn
 //	    return nil
 //	})
 func ReadObjectCallback(s *Scanner, callback func(k string, v any) error) error {
+	return readObject(s, nil, callback, nil)
+}
+
+// ReadObjectCallbackRaw is like ReadObjectCallback, but passes the exact source
+// bytes of each member value, without the surrounding whitespace, instead of
+// the decoded value, see ReadValueRaw. Values are validated but not built, so
+// that a proxy can pass most members through verbatim and parse only those it
+// transforms.
+//
+// The raw slice aliases the scanner's data, it is only valid as long as that
+// data is and must be copied if it is modified or retained independently.
+func ReadObjectCallbackRaw(s *Scanner, callback func(k string, raw []byte) error) error {
+	return readObject(s, nil, nil, callback)
+}
+
+// ReadObjectUntil is like ReadObjectCallback, but calls stop with each key
+// before its value is read, and returns as soon as stop reports true. This
+// reads a prefix of an object, e.g. to dispatch on a "type" discriminator
+// without parsing the whole object first.
+//
+// When stopped, the scanner is left mid-object, positioned at the value of
+// the stopping key, and the callback is not invoked for it. Consume that value
+// with ReadValue or SkipValue, then call FinishObject to skip the rest of the
+// object. If stop never reports true, the whole object is read and must not
+// be finished again.
+func ReadObjectUntil(s *Scanner, stop func(key string) bool, callback func(k string, v any) error) error {
+	return readObject(s, stop, callback, nil)
+}
+
+// FinishObject skips the remaining members of an object left unfinished by
+// ReadObjectUntil, up to and including the closing brace. The scanner must be
+// positioned after a member value.
+func FinishObject(s *Scanner) error {
+	for {
+		s.skipWhitespace()
+		if s.IsEOF() {
+			return ErrUnexpectedEOF
+		}
+		if s.skipByte('}') {
+			s.leaveContainer()
+			return nil
+		}
+		if !s.skipByte(',') {
+			return ErrUnexpectedToken
+		}
+		s.skipWhitespace()
+		if s.IsEOF() {
+			return ErrUnexpectedEOF
+		}
+		if _, err := s.parseKey(); err != nil {
+			return err
+		}
+		s.skipWhitespace()
+		if s.IsEOF() {
+			return ErrUnexpectedEOF
+		}
+		if !s.skipByte(':') {
+			return ErrUnexpectedToken
+		}
+		if err := SkipValue(s); err != nil {
+			return err
+		}
+	}
+}
+
+// readObject implements ReadObjectCallback, ReadObjectCallbackRaw and
+// ReadObjectUntil. Stop is nil for the first two, values are passed to
+// callback, or as raw bytes to rawCallback if callback is nil.
+func readObject(s *Scanner, stop func(string) bool, callback func(k string, v any) error, rawCallback func(k string, raw []byte) error) error {
 	if !s.skipByte('{') {
-		return ErrUnexpectedToken
+		return s.unexpectedToken()
+	}
+	if err := s.countValue(); err != nil {
+		return err
+	}
+	if err := s.enterContainer(); err != nil {
+		return err
 	}
 
 	s.skipWhitespace()
 	if s.skipByte('}') {
+		s.leaveContainer()
 		return nil
 	}
 
 	var err error
-	var key string
 	var value any
+	f := readFrame{isObj: true}
 
 	for {
-		// Parse key
 		s.skipWhitespace()
-		key, err = s.parseString()
-		if err != nil {
+		start := s.cur
+		if err = readMemberKey(s, &f, true); err != nil {
 			return err
 		}
-
-		s.skipWhitespace()
-		if !s.skipByte(':') {
-			return ErrUnexpectedToken
-		}
+		key := f.key
 
 		// Parse value
 		s.skipWhitespace()
-		value, err = ReadValue(s)
+		if stop != nil && stop(key) {
+			return nil
+		}
+		valueStart := s.cur
+		if callback != nil {
+			value, err = ReadValue(s)
+		} else {
+			err = SkipValue(s)
+		}
 		if err != nil {
-			return err
+			return prependPath(err, "/"+escapePointerToken(key))
+		}
+		if callback != nil {
+			err = callback(key, value)
+		} else {
+			err = rawCallback(key, s.data[valueStart:s.cur:s.cur])
 		}
-		err = callback(key, value)
 		if err != nil {
+			if s.flags&ScannerFlagWrapCallbackErrors != 0 {
+				line, col := s.position(start)
+				err = &MemberError{Key: key, Offset: start, Line: line, Column: col, Err: err}
+			}
 			return err
 		}
 
@@ -58,6 +149,7 @@ func ReadObjectCallback(s *Scanner, callback func(k string, v any) error) error
 			continue
 		}
 		if s.skipByte('}') {
+			s.leaveContainer()
 			return nil
 		}
 		return ErrUnexpectedToken
@@ -66,137 +158,388 @@ func ReadObjectCallback(s *Scanner, callback func(k string, v any) error) error
 
 // ReadObject reads a JSON object and returns it as map[string]any
 func ReadObject(s *Scanner) (map[string]any, error) {
-	m := make(map[string]any)
-	err := ReadObjectCallback(s, func(key string, value any) error {
-		m[key] = value
-		return nil
-	})
-	if err != nil {
+	m := s.newObject()
+	if err := ReadObjectInto(s, m); err != nil {
 		return nil, err
 	}
 	return m, nil
 }
 
+// ReadObjectInto reads a JSON object and stores its members in m, which must
+// not be nil. This aggregates objects that arrive in fragments, e.g. across
+// the frames of a stream, and reuses the map between reads.
+//
+// Members replace the entries of m with the same key, so across fragments the
+// last value wins. DuplicateKeysAsArray and RejectDuplicateKeys only apply to
+// keys repeated within the object being read. On error, m keeps the members
+// stored before it.
+func ReadObjectInto(s *Scanner, m map[string]any) error {
+	var multi map[string]bool
+	var stored map[string]bool // keys stored by this read, with DuplicateKeysAsArray
+	return ReadObjectCallback(s, func(key string, value any) error {
+		if s.dupKeysAsArr && !stored[key] {
+			if stored == nil {
+				stored = make(map[string]bool)
+			}
+			stored[key] = true
+			m[key] = value
+			return nil
+		}
+		s.storeMember(m, &multi, key, value)
+		return nil
+	})
+}
+
 // ReadValue reads any JSON value and returns it as a Go value.
 // The mapping of JSON types to Go types is as follows:
 //   - JSON null -> nil
 //   - JSON boolean -> bool
-//   - JSON number -> float64
+//   - JSON number -> float64 (Number with ScannerFlagUseNumber)
 //   - JSON string -> string
 //   - JSON array -> []any
 //   - JSON object -> map[string]any
 //
-// This function is recursive and will handle nested structures of any depth,
-// limited only by available stack space.
+// Nested arrays and objects are tracked on an explicit stack rather than by
+// recursion, so deep documents do not exhaust the goroutine stack. The nesting
+// depth is still limited by ScannerMaxDepth (DefaultMaxDepth unless specified
+// otherwise), use ScannerMaxDepth(0) to read arbitrarily deep documents.
 func ReadValue(s *Scanner) (any, error) {
-	s.skipWhitespace()
+	return readValue(s, true)
+}
 
+// ReadValueExact reads a value like ReadValue, but without skipping leading
+// whitespace: the value must start at the current position, otherwise it fails
+// with ErrUnexpectedToken (ErrUnexpectedBOM for a BOM). Like ReadValue, it
+// stops right after the value. This is the entry point for protocol parsers
+// that handle whitespace themselves, see also Scanner.SkipWhitespace.
+// ReadObjectCallback and ReadArrayCallback do not skip leading whitespace
+// either.
+func ReadValueExact(s *Scanner) (any, error) {
 	if s.IsEOF() {
 		return nil, ErrUnexpectedEOF
 	}
+	if isWhitespace[s.peek()] {
+		return nil, ErrUnexpectedToken
+	}
+	if s.atBOM() {
+		return nil, ErrUnexpectedBOM
+	}
+	return ReadValue(s)
+}
+
+// ReadValueN reads a value that occupies exactly the next n bytes of the
+// scanner data, e.g. a length-prefixed frame of a wire protocol. Parsing is
+// confined to the window, as if the data ended there: a value that would extend
+// beyond it fails like truncated input (e.g. with ErrUnexpectedEOF), and
+// anything but whitespace left in the window after the value fails as trailing
+// data would (see Finalize). This includes a number that continues past the
+// window, e.g. a window of 2 bytes over 123 fails with ErrUnexpectedEOF rather
+// than reading 12. A window larger than the remaining data fails with
+// ErrUnexpectedEOF before any parsing.
+//
+// On success the scanner is positioned right after the window.
+func ReadValueN(s *Scanner, n int) (any, error) {
+	if n < 0 || n > len(s.data)-s.cur {
+		return nil, ErrUnexpectedEOF
+	}
+	data := s.data
+	s.data = data[:s.cur+n]
+	defer func() { s.data = data }()
+
+	kind, _ := PeekKind(s)
+	v, err := ReadValue(s)
+	if err != nil {
+		return nil, err
+	}
+	if kind == KindNumber && s.IsEOF() && s.cur < len(data) && s.continuesNumber(data[s.cur]) {
+		return nil, ErrUnexpectedEOF
+	}
+	s.skipWhitespace()
+	if !s.IsEOF() {
+		return nil, s.unexpectedToken()
+	}
+	return v, nil
+}
+
+// continuesNumber reports whether c can be part of the number before it, see
+// ReadValueN
+func (s *Scanner) continuesNumber(c byte) bool {
+	if isNumberByte[c] {
+		return true
+	}
+	return s.flags&ScannerFlagAllowHexNumbers != 0 && (c == 'x' || c == 'X' || isHex([]byte{c}))
+}
+
+// SkipValue reads and validates any JSON value without building it, see
+// ReadValue. Strings and numbers are checked exactly as ReadValue checks them,
+// and the scanner options (limits, duplicate keys, etc.) apply as well.
+func SkipValue(s *Scanner) error {
+	_, err := readValue(s, false)
+	return err
+}
+
+// readFrame is an array or object being read by readValue
+type readFrame struct {
+	isObj bool
+	obj   map[string]any
+	arr   []any
+	key   string          // key of the member being read
+	raw   []byte          // undecoded key instead of key, see readMemberKey
+	n     int             // number of members read so far, or array index
+	seen  map[string]bool // keys seen when not building, see Scanner.seenKey
+	multi map[string]bool // accumulated keys, see Scanner.storeMember
+}
 
-	switch s.peek() {
-	case '{':
-		s.cur++
-		m := make(map[string]any)
+// readValue implements ReadValue and SkipValue, the result is only built when
+// build is true
+func readValue(s *Scanner, build bool) (any, error) {
+	// typical documents nest shallowly enough for their frames to stay off
+	// the heap
+	var frames [8]readFrame
+	stack := frames[:0]
+	for {
 		s.skipWhitespace()
-		if s.skipByte('}') {
-			return m, nil
+		if s.IsEOF() {
+			return nil, ErrUnexpectedEOF
 		}
-		for {
+		if err := s.countValue(); err != nil {
+			return nil, err
+		}
+
+		var v any
+		switch s.peek() {
+		case '{':
+			s.cur++
+			if err := s.enterContainer(); err != nil {
+				return nil, prependPath(err, framePath(stack))
+			}
+			f := readFrame{isObj: true}
+			if build {
+				f.obj = s.newObject()
+			}
 			s.skipWhitespace()
-			if s.IsEOF() {
-				return nil, ErrUnexpectedEOF
+			if s.skipByte('}') {
+				s.leaveContainer()
+				v = f.obj
+				break
 			}
-			// Key must be a string in strict JSON
-			key, err := s.parseString()
-			if err != nil {
+			stack = append(stack, f)
+			if err := readMemberKey(s, &stack[len(stack)-1], build); err != nil {
 				return nil, err
 			}
+			continue
 
+		case '[':
+			s.cur++
+			if err := s.enterContainer(); err != nil {
+				return nil, prependPath(err, framePath(stack))
+			}
 			s.skipWhitespace()
-			if s.IsEOF() {
-				return nil, ErrUnexpectedEOF
+			if s.skipByte(']') {
+				s.leaveContainer()
+				if build {
+					v = []any(nil)
+				}
+				break
 			}
-			if !s.skipByte(':') {
-				return nil, ErrUnexpectedToken
+			f := readFrame{}
+			if build {
+				f.arr = s.newArray()
 			}
+			stack = append(stack, f)
+			continue
 
-			val, err := ReadValue(s)
+		case '"':
+			if !build {
+				if _, err := s.skipString(); err != nil {
+					return nil, err
+				}
+				break
+			}
+			str, err := s.parseString()
 			if err != nil {
 				return nil, err
 			}
-			m[key] = val
+			v = str
 
-			s.skipWhitespace()
-			if s.IsEOF() {
-				return nil, ErrUnexpectedEOF
+		case 't':
+			if !s.skipSequence([]byte("true")) {
+				return nil, ErrUnexpectedToken
 			}
-			if s.skipByte('}') {
-				return m, nil
+			v = true
+
+		case 'f':
+			if !s.skipSequence([]byte("false")) {
+				return nil, ErrUnexpectedToken
 			}
-			if !s.skipByte(',') {
+			v = false
+
+		case 'n':
+			if !s.skipSequence([]byte("null")) {
 				return nil, ErrUnexpectedToken
 			}
-		}
 
-	case '[':
-		s.cur++
-		var arr []any
-		s.skipWhitespace()
-		if s.skipByte(']') {
-			return arr, nil
-		}
-		for {
-			if s.IsEOF() {
-				return nil, ErrUnexpectedEOF
+		case '+', '.':
+			if s.flags&ScannerFlagAllowJSON5Numbers == 0 {
+				return nil, s.unexpectedToken()
+			}
+			fallthrough
+		case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			if !build && s.convertNumber == nil {
+				// check the number as readNumber would, without boxing it
+				var err error
+				if s.flags&(ScannerFlagUseNumber|ScannerFlagLazyNumbers) != 0 {
+					_, err = s.scanNumber()
+				} else {
+					_, err = s.parseNumber()
+				}
+				if err != nil {
+					return nil, err
+				}
+				break
 			}
-			val, err := ReadValue(s)
+			n, err := s.readNumber()
 			if err != nil {
 				return nil, err
 			}
-			arr = append(arr, val)
+			v = n
+
+		default:
+			return nil, s.unexpectedToken()
+		}
+
+		// store the completed value in its container, closing the containers
+		// that are complete as a result
+		for {
+			if len(stack) == 0 {
+				if !build {
+					return nil, nil
+				}
+				return v, nil
+			}
+			top := &stack[len(stack)-1]
+			if build {
+				if top.isObj {
+					s.storeMember(top.obj, &top.multi, top.key, v)
+				} else {
+					top.arr = append(top.arr, v)
+				}
+			}
 
 			s.skipWhitespace()
 			if s.IsEOF() {
 				return nil, ErrUnexpectedEOF
 			}
-			if s.skipByte(']') {
-				return arr, nil
+			if top.isObj {
+				if s.skipByte('}') {
+					s.leaveContainer()
+					v = top.obj
+					stack = stack[:len(stack)-1]
+					continue
+				}
+				if !s.skipByte(',') {
+					return nil, ErrUnexpectedToken
+				}
+				if err := readMemberKey(s, top, build); err != nil {
+					return nil, err
+				}
+			} else {
+				if s.skipByte(']') {
+					s.leaveContainer()
+					v = top.arr
+					stack = stack[:len(stack)-1]
+					continue
+				}
+				if !s.skipByte(',') {
+					return nil, ErrUnexpectedToken
+				}
+				top.n++
 			}
-			if !s.skipByte(',') {
-				return nil, ErrUnexpectedToken
+			break
+		}
+	}
+}
+
+// framePath returns the JSON Pointer to the value being read in the innermost
+// frame of stack
+func framePath(stack []readFrame) string {
+	path := ""
+	for i := range stack {
+		if f := &stack[i]; f.isObj {
+			key := f.key
+			if f.raw != nil {
+				key = string(f.raw)
 			}
-			s.skipWhitespace()
+			path += "/" + escapePointerToken(key)
+		} else {
+			path += "/" + strconv.Itoa(stack[i].n)
 		}
+	}
+	return path
+}
 
-	case '"':
-		return s.parseString()
+// readMemberKey reads the key of the next object member and the colon that
+// follows it. Unless decode is set, keys that are only needed for error paths
+// are validated without allocating a string for them.
+func readMemberKey(s *Scanner, f *readFrame, decode bool) error {
+	if err := readKey(s, f, decode); err != nil {
+		return err
+	}
+	return s.ExpectDelim(':')
+}
 
-	case 't':
-		if !s.skipSequence([]byte("true")) {
-			return nil, ErrUnexpectedToken
+// readKey reads the key of the next object member, see readMemberKey
+func readKey(s *Scanner, f *readFrame, decode bool) error {
+	f.n++
+	if err := s.checkMembers(f.n); err != nil {
+		return err
+	}
+	s.skipWhitespace()
+	if s.IsEOF() {
+		return ErrUnexpectedEOF
+	}
+	// Key must be a string in strict JSON
+	if !decode && !s.rejectDupKeys && s.transformKey == nil && s.onEscapedKey == nil {
+		if s.collectStats() {
+			s.stats.Keys++
 		}
-		return true, nil
-
-	case 'f':
-		if !s.skipSequence([]byte("false")) {
-			return nil, ErrUnexpectedToken
+		raw, err := s.skipString()
+		if err != nil {
+			return err
 		}
-		return false, nil
-
-	case 'n':
-		if !s.skipSequence([]byte("null")) {
-			return nil, ErrUnexpectedToken
+		f.key, f.raw = "", raw
+	} else {
+		key, err := s.parseKey()
+		if err != nil {
+			return err
 		}
-		return nil, nil
-
-	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-		return s.parseNumber()
+		if f.obj != nil {
+			if s.rejectDupKeys {
+				if _, dup := f.obj[key]; dup {
+					return ErrDuplicateKey
+				}
+			}
+		} else if err = s.seenKey(&f.seen, key); err != nil {
+			return err
+		}
+		f.key, f.raw = key, nil
+	}
+	return nil
+}
 
-	default:
-		return nil, ErrUnexpectedToken
+// ReadValueRaw reads any JSON value like ReadValue and also returns the exact
+// source bytes of the value, without the surrounding whitespace.
+//
+// The raw slice aliases the scanner's data, it is only valid as long as that
+// data is and must be copied if it is modified or retained independently.
+func ReadValueRaw(s *Scanner) (value any, raw []byte, err error) {
+	s.skipWhitespace()
+	start := s.cur
+	value, err = ReadValue(s)
+	if err != nil {
+		return nil, nil, err
 	}
+	return value, s.data[start:s.cur:s.cur], nil
 }
 
 // ReadArrayCallback reads a JSON array and invokes the callback function for each element.
@@ -210,22 +553,29 @@ func ReadValue(s *Scanner) (any, error) {
 //	})
 func ReadArrayCallback(s *Scanner, callback func(any) error) error {
 	if !s.skipByte('[') {
-		return ErrUnexpectedToken
+		return s.unexpectedToken()
+	}
+	if err := s.countValue(); err != nil {
+		return err
+	}
+	if err := s.enterContainer(); err != nil {
+		return err
 	}
 
 	s.skipWhitespace()
 	if s.skipByte(']') {
+		s.leaveContainer()
 		return nil
 	}
 
-	for {
+	for i := 0; ; i++ {
 		s.skipWhitespace()
 		if s.IsEOF() {
 			return ErrUnexpectedEOF
 		}
 		value, err := ReadValue(s)
 		if err != nil {
-			return err
+			return prependPath(err, "/"+strconv.Itoa(i))
 		}
 
 		if err := callback(value); err != nil {
@@ -240,6 +590,7 @@ func ReadArrayCallback(s *Scanner, callback func(any) error) error {
 			continue
 		}
 		if s.skipByte(']') {
+			s.leaveContainer()
 			return nil
 		}
 		return ErrUnexpectedToken
@@ -250,6 +601,9 @@ func ReadArrayCallback(s *Scanner, callback func(any) error) error {
 func ReadArray(s *Scanner) ([]any, error) {
 	var arr []any
 	err := ReadArrayCallback(s, func(value any) error {
+		if arr == nil {
+			arr = s.newArray()
+		}
 		arr = append(arr, value)
 		return nil
 	})
@@ -258,3 +612,109 @@ func ReadArray(s *Scanner) ([]any, error) {
 	}
 	return arr, nil
 }
+
+// ReadInt64 reads a JSON number that is expected to be an integer and returns
+// it as int64. Unlike ReadValue, the value is converted directly from its
+// source text, so integers beyond 2^53 do not lose precision.
+//
+// Numbers with a fractional part or an exponent are rejected with
+// ErrNumberNotInteger, values that do not fit into int64 are rejected with
+// ErrNumericValueOutOfRange.
+func ReadInt64(s *Scanner) (int64, error) {
+	token, err := s.scanInteger()
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseInt(string(token), 10, 64)
+	if err != nil {
+		return 0, ErrNumericValueOutOfRange
+	}
+	return v, nil
+}
+
+// ReadUint64 reads a JSON number that is expected to be a non-negative integer
+// and returns it as uint64. Negative numbers (other than -0) are rejected with
+// ErrNumericValueOutOfRange, see ReadInt64 for other details.
+func ReadUint64(s *Scanner) (uint64, error) {
+	token, err := s.scanInteger()
+	if err != nil {
+		return 0, err
+	}
+	if token[0] == '-' {
+		if len(token) == 2 && token[1] == '0' {
+			return 0, nil
+		}
+		return 0, ErrNumericValueOutOfRange
+	}
+	v, err := strconv.ParseUint(string(token), 10, 64)
+	if err != nil {
+		return 0, ErrNumericValueOutOfRange
+	}
+	return v, nil
+}
+
+// Parse reads a single JSON value from data and ensures that nothing but
+// whitespace follows it. The options are passed to NewScanner.
+//
+// This is a one-call alternative to NewScanner + ReadValue + Finalize, use the
+// lower-level functions when processing data that contains more than one value.
+// Empty data fails with ErrUnexpectedEOF, unless the EmptyAsNull option is
+// given.
+func Parse(data []byte, opts ...any) (any, error) {
+	s := NewScanner(data, opts...)
+	if s.isEmptyDocument() {
+		return nil, nil
+	}
+	v, err := ReadValue(s)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.Finalize(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// ParseObject reads a single JSON object from data and ensures that nothing but
+// whitespace follows it, see Parse.
+func ParseObject(data []byte, opts ...any) (map[string]any, error) {
+	s := NewScanner(data, opts...)
+	if s.isEmptyDocument() {
+		return nil, nil
+	}
+	m, err := ReadObject(s)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.Finalize(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ParseArray reads a single JSON array from data and ensures that nothing but
+// whitespace follows it, see Parse.
+func ParseArray(data []byte, opts ...any) ([]any, error) {
+	s := NewScanner(data, opts...)
+	if s.isEmptyDocument() {
+		return nil, nil
+	}
+	arr, err := ReadArray(s)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.Finalize(); err != nil {
+		return nil, err
+	}
+	return arr, nil
+}
+
+// isEmptyDocument reports whether the data is empty, apart from whitespace,
+// and the EmptyAsNull option is given
+func (s *Scanner) isEmptyDocument() bool {
+	if !s.emptyAsNull {
+		return false
+	}
+	s.skipWhitespace()
+	return s.IsEOF()
+}
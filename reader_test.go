@@ -1,10 +1,14 @@
 package jsn
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"unsafe"
 )
 
 func TestReadValue(t *testing.T) {
@@ -188,6 +192,115 @@ func TestReadObject(t *testing.T) {
 	}
 }
 
+func TestReadObjectUntil(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantKeys []string
+		wantType any // value of the stopping "type" key, nil if not stopped
+		wantErr  error
+	}{
+		{name: "discriminator first", input: `{"type":"circle","r":1} 2`, wantType: "circle"},
+		{name: "discriminator later", input: `{"id":1,"tags":[1,{"a":2}],"type":"square","side":2,"x":{}} 2`, wantKeys: []string{"id", "tags"}, wantType: "square"},
+		{name: "discriminator last", input: `{"id":1,"type":"dot"} 2`, wantKeys: []string{"id"}, wantType: "dot"},
+		{name: "no discriminator", input: `{"id":1,"r":2} 2`, wantKeys: []string{"id", "r"}},
+		{name: "empty", input: `{} 2`},
+		{name: "invalid rest", input: `{"type":"a","b" 1} 2`, wantType: "a", wantErr: ErrUnexpectedToken},
+		{name: "truncated rest", input: `{"type":"a",`, wantType: "a", wantErr: ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner([]byte(tt.input))
+			var keys []string
+			stopped := false
+			err := ReadObjectUntil(s, func(key string) bool {
+				stopped = key == "type"
+				return stopped
+			}, func(key string, _ any) error {
+				keys = append(keys, key)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ReadObjectUntil() unexpected error = %v", err)
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("ReadObjectUntil() keys = %v, want %v", keys, tt.wantKeys)
+			}
+
+			var typ any
+			if stopped {
+				if typ, err = ReadValue(s); err != nil {
+					t.Fatalf("ReadValue() unexpected error = %v", err)
+				}
+				err = FinishObject(s)
+			}
+			if typ != tt.wantType {
+				t.Errorf("type = %v, want %v", typ, tt.wantType)
+			}
+			if err != tt.wantErr {
+				t.Fatalf("FinishObject() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			// the scanner is past the object
+			if v, err := ReadValue(s); err != nil || v != 2.0 {
+				t.Errorf("ReadValue() after the object = %v, %v, want 2", v, err)
+			}
+			if err := s.Finalize(); err != nil {
+				t.Errorf("Finalize() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestReadObjectInto(t *testing.T) {
+	tests := []struct {
+		name      string
+		fragments []string
+		opts      []any
+		want      map[string]any
+		wantErr   error
+	}{
+		{name: "merge", fragments: []string{`{"a":1}`, `{}`, `{"b":2}`}, want: map[string]any{"a": 1.0, "b": 2.0}},
+		{name: "last wins", fragments: []string{`{"a":1,"b":2}`, `{"a":3}`}, want: map[string]any{"a": 3.0, "b": 2.0}},
+		{
+			name:      "duplicates within a fragment",
+			fragments: []string{`{"a":1}`, `{"a":2,"a":3}`},
+			opts:      []any{DuplicateKeysAsArray{}},
+			want:      map[string]any{"a": []any{2.0, 3.0}},
+		},
+		{
+			name:      "rejected duplicates within a fragment",
+			fragments: []string{`{"a":1}`, `{"a":2}`, `{"b":1,"b":2}`},
+			opts:      []any{RejectDuplicateKeys{}},
+			want:      map[string]any{"a": 2.0, "b": 1.0},
+			wantErr:   ErrDuplicateKey,
+		},
+		{name: "not an object", fragments: []string{`{"a":1}`, `[]`}, want: map[string]any{"a": 1.0}, wantErr: ErrUnexpectedToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := map[string]any{}
+			var err error
+			for _, f := range tt.fragments {
+				if err = ReadObjectInto(NewScanner([]byte(f), tt.opts...), m); err != nil {
+					break
+				}
+			}
+			if err != tt.wantErr {
+				t.Errorf("ReadObjectInto() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(m, tt.want) {
+				t.Errorf("ReadObjectInto() = %v, want %v", m, tt.want)
+			}
+		})
+	}
+}
+
 func TestReadArray(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -392,6 +505,45 @@ func TestReadObjectCallbackErrors(t *testing.T) {
 	}
 }
 
+func TestReadObjectCallbackMemberError(t *testing.T) {
+	errNegative := errors.New("must be positive")
+	input := "{\n  \"name\": \"x\",\n  \"age\": -1\n}"
+	callback := func(key string, value any) error {
+		if key == "age" && value.(float64) < 0 {
+			return errNegative
+		}
+		return nil
+	}
+
+	// without the flag the error is passed through
+	err := ReadObjectCallback(NewScannerString(input), callback)
+	if err != errNegative {
+		t.Fatalf("ReadObjectCallback() error = %v, want %v", err, errNegative)
+	}
+
+	err = ReadObjectCallback(NewScannerString(input, ScannerFlagWrapCallbackErrors), callback)
+	var me *MemberError
+	if !errors.As(err, &me) {
+		t.Fatalf("ReadObjectCallback() error = %v, want *MemberError", err)
+	}
+	want := MemberError{Key: "age", Offset: 19, Line: 3, Column: 3, Err: errNegative}
+	if *me != want {
+		t.Errorf("MemberError = %+v, want %+v", *me, want)
+	}
+	if !errors.Is(err, errNegative) {
+		t.Error("MemberError does not unwrap to the callback error")
+	}
+	if got := err.Error(); got != `member "age" at offset 19: must be positive` {
+		t.Errorf("Error() = %q", got)
+	}
+
+	// scanner errors are never wrapped
+	err = ReadObjectCallback(NewScannerString(`{"a":}`, ScannerFlagWrapCallbackErrors), callback)
+	if err != ErrUnexpectedToken {
+		t.Errorf("ReadObjectCallback() error = %v, want %v", err, ErrUnexpectedToken)
+	}
+}
+
 func TestReadArrayCallbackErrors(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -1108,3 +1260,1412 @@ func ExampleReadArrayCallback() {
 	// Found user: Jane
 	// Found order: B456
 }
+
+func TestReadInt64(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    []any
+		want    int64
+		wantErr error
+	}{
+		{name: "zero", input: "0", want: 0},
+		{name: "negative zero", input: "-0", want: 0},
+		{name: "positive", input: "42", want: 42},
+		{name: "negative", input: "-42", want: -42},
+		{name: "leading whitespace", input: "  7", want: 7},
+		{name: "beyond float64 precision", input: "9007199254740993", want: 9007199254740993},
+		{name: "max int64", input: "9223372036854775807", want: 9223372036854775807},
+		{name: "min int64", input: "-9223372036854775808", want: -9223372036854775808},
+		{name: "allowed leading zeros", input: "007", opts: []any{ScannerFlagAllowLeadingZeros}, want: 7},
+		{name: "allowed negative leading zeros", input: "-007", opts: []any{ScannerFlagAllowLeadingZeros}, want: -7},
+		{name: "allowed negative zeros", input: "-00", opts: []any{ScannerFlagAllowLeadingZeros}, want: 0},
+
+		{name: "overflow", input: "9223372036854775808", wantErr: ErrNumericValueOutOfRange},
+		{name: "underflow", input: "-9223372036854775809", wantErr: ErrNumericValueOutOfRange},
+		{name: "fraction", input: "1.0", wantErr: ErrNumberNotInteger},
+		{name: "exponent", input: "1e3", wantErr: ErrNumberNotInteger},
+		{name: "leading zero", input: "01", wantErr: ErrInvalidNumber},
+		{name: "trailing letter", input: "12x", wantErr: ErrInvalidNumber},
+		{name: "string", input: `"1"`, wantErr: ErrUnexpectedToken},
+		{name: "empty input", input: "", wantErr: ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner([]byte(tt.input), tt.opts...)
+			got, err := ReadInt64(s)
+			if err == nil {
+				err = s.Finalize()
+			}
+			if err != tt.wantErr {
+				t.Errorf("ReadInt64() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("ReadInt64() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadUint64(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    []any
+		want    uint64
+		wantErr error
+	}{
+		{name: "zero", input: "0", want: 0},
+		{name: "negative zero", input: "-0", want: 0},
+		{name: "positive", input: "42", want: 42},
+		{name: "max uint64", input: "18446744073709551615", want: 18446744073709551615},
+		{name: "allowed leading zeros", input: "007", opts: []any{ScannerFlagAllowLeadingZeros}, want: 7},
+		{name: "allowed negative zeros", input: "-00", opts: []any{ScannerFlagAllowLeadingZeros}, want: 0},
+		{name: "leading zeros", input: "007", wantErr: ErrInvalidNumber},
+
+		{name: "overflow", input: "18446744073709551616", wantErr: ErrNumericValueOutOfRange},
+		{name: "negative", input: "-1", wantErr: ErrNumericValueOutOfRange},
+		{name: "allowed negative leading zeros", input: "-01", opts: []any{ScannerFlagAllowLeadingZeros}, wantErr: ErrNumericValueOutOfRange},
+		{name: "fraction", input: "0.5", wantErr: ErrNumberNotInteger},
+		{name: "exponent", input: "1E2", wantErr: ErrNumberNotInteger},
+		{name: "invalid number", input: "-", wantErr: ErrInvalidNumber},
+		{name: "null", input: "null", wantErr: ErrUnexpectedToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner([]byte(tt.input), tt.opts...)
+			got, err := ReadUint64(s)
+			if err == nil {
+				err = s.Finalize()
+			}
+			if err != tt.wantErr {
+				t.Errorf("ReadUint64() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("ReadUint64() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScannerMaxValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		limit   ScannerMaxValues
+		read    func(*Scanner) error
+		wantErr error
+	}{
+		{name: "unlimited", input: "[null,null,null]", limit: 0, wantErr: nil},
+		{name: "scalar at limit", input: "42", limit: 1, wantErr: nil},
+		{name: "array at limit", input: "[null,null,null]", limit: 4, wantErr: nil},
+		{name: "array over limit", input: "[null,null,null]", limit: 3, wantErr: ErrValueLimitExceeded},
+		{name: "object at limit", input: `{"a":1,"b":[2]}`, limit: 4, wantErr: nil},
+		{name: "object over limit", input: `{"a":1,"b":[2]}`, limit: 3, wantErr: ErrValueLimitExceeded},
+		{
+			name:  "array callback over limit",
+			input: "[1,2,3]",
+			limit: 3,
+			read: func(s *Scanner) error {
+				return ReadArrayCallback(s, func(any) error { return nil })
+			},
+			wantErr: ErrValueLimitExceeded,
+		},
+		{
+			name:  "object callback at limit",
+			input: `{"a":1,"b":2}`,
+			limit: 3,
+			read: func(s *Scanner) error {
+				return ReadObjectCallback(s, func(string, any) error { return nil })
+			},
+			wantErr: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner([]byte(tt.input), tt.limit)
+			var err error
+			if tt.read != nil {
+				err = tt.read(s)
+			} else {
+				_, err = ReadValue(s)
+			}
+			if err == nil {
+				err = s.Finalize()
+			}
+			if err != tt.wantErr {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    []any
+		want    any
+		wantErr error
+	}{
+		{name: "number", input: "42", want: float64(42)},
+		{name: "surrounding whitespace", input: " \n\"hello\" \n", want: "hello"},
+		{name: "object", input: `{"a":[1]}`, want: map[string]any{"a": []any{float64(1)}}},
+		{name: "trailing value", input: "1 2", wantErr: ErrUnexpectedToken},
+		{name: "trailing garbage", input: `{"a":1}x`, wantErr: ErrUnexpectedToken},
+		{name: "empty input", input: "", wantErr: ErrUnexpectedEOF},
+		{name: "whitespace only", input: " \n\t", wantErr: ErrUnexpectedEOF},
+		{name: "empty as null", input: "", opts: []any{EmptyAsNull{}}, want: nil},
+		{name: "whitespace as null", input: "\xef\xbb\xbf \r\n", opts: []any{EmptyAsNull{}}, want: nil},
+		{name: "comment as null", input: "// none", opts: []any{EmptyAsNull{}, ScannerFlagAllowComments}, want: nil},
+		{name: "not empty", input: " 1 ", opts: []any{EmptyAsNull{}}, want: float64(1)},
+		{name: "truncated is not empty", input: "[", opts: []any{EmptyAsNull{}}, wantErr: ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.input), tt.opts...)
+			if err != tt.wantErr {
+				t.Errorf("Parse() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseObjectArray(t *testing.T) {
+	obj, err := ParseObject([]byte(`{"key": "value"}`))
+	if err != nil || !reflect.DeepEqual(obj, map[string]any{"key": "value"}) {
+		t.Errorf("ParseObject() = %v, %v", obj, err)
+	}
+	if _, err = ParseObject([]byte(`{} {}`)); err != ErrUnexpectedToken {
+		t.Errorf("ParseObject() trailing data error = %v, want %v", err, ErrUnexpectedToken)
+	}
+	if _, err = ParseObject([]byte(`[]`)); err != ErrUnexpectedToken {
+		t.Errorf("ParseObject() array input error = %v, want %v", err, ErrUnexpectedToken)
+	}
+
+	arr, err := ParseArray([]byte(`[1, "two"]`))
+	if err != nil || !reflect.DeepEqual(arr, []any{float64(1), "two"}) {
+		t.Errorf("ParseArray() = %v, %v", arr, err)
+	}
+	if _, err = ParseArray([]byte(`[1],`)); err != ErrUnexpectedToken {
+		t.Errorf("ParseArray() trailing data error = %v, want %v", err, ErrUnexpectedToken)
+	}
+	if _, err = ParseArray([]byte{0xEF, 0xBB, 0xBF, '[', ']'}); err != nil {
+		t.Errorf("ParseArray() with BOM unexpected error = %v", err)
+	}
+
+	if obj, err = ParseObject([]byte(" "), EmptyAsNull{}); obj != nil || err != nil {
+		t.Errorf("ParseObject() empty = %v, %v, want nil map", obj, err)
+	}
+	if arr, err = ParseArray(nil, EmptyAsNull{}); arr != nil || err != nil {
+		t.Errorf("ParseArray() empty = %v, %v, want nil slice", arr, err)
+	}
+	if _, err = ParseArray(nil); err != ErrUnexpectedEOF {
+		t.Errorf("ParseArray() empty error = %v, want %v", err, ErrUnexpectedEOF)
+	}
+}
+
+func TestScannerMaxObjectMembers(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		limit   ScannerMaxObjectMembers
+		read    func(*Scanner) error
+		wantErr error
+	}{
+		{name: "unlimited", input: `{"a":1,"b":2,"c":3}`, limit: 0, wantErr: nil},
+		{name: "at limit", input: `{"a":1,"b":2}`, limit: 2, wantErr: nil},
+		{name: "over limit", input: `{"a":1,"b":2,"c":3}`, limit: 2, wantErr: ErrTooManyMembers},
+		{name: "limit applies per object", input: `{"a":{"x":1,"y":2},"b":{"x":1,"y":2}}`, limit: 2, wantErr: nil},
+		{name: "nested over limit", input: `[{"x":1,"y":2,"z":3}]`, limit: 2, wantErr: ErrTooManyMembers},
+		{name: "arrays are not limited", input: `[1,2,3,4]`, limit: 1, wantErr: nil},
+		{
+			name:  "object callback over limit",
+			input: `{"a":1,"b":2}`,
+			limit: 1,
+			read: func(s *Scanner) error {
+				return ReadObjectCallback(s, func(string, any) error { return nil })
+			},
+			wantErr: ErrTooManyMembers,
+		},
+		{
+			name:  "copy over limit",
+			input: `{"a":1,"b":2}`,
+			limit: 1,
+			read: func(s *Scanner) error {
+				return CopyValue(&strings.Builder{}, s)
+			},
+			wantErr: ErrTooManyMembers,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner([]byte(tt.input), tt.limit)
+			var err error
+			if tt.read != nil {
+				err = tt.read(s)
+			} else {
+				_, err = ReadValue(s)
+			}
+			if err == nil {
+				err = s.Finalize()
+			}
+			if err != tt.wantErr {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func FuzzReadValue(f *testing.F) {
+	for _, tt := range NSTTestSuiteData {
+		f.Add([]byte(tt.Content))
+	}
+	f.Add([]byte(strings.Repeat("[", DefaultMaxDepth+1)))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		s := NewScanner(data)
+		v, err := ReadValue(s)
+		if err == nil {
+			err = s.Finalize()
+		}
+		if err != nil {
+			return
+		}
+
+		// a successfully parsed value must re-marshal into parseable JSON
+		out, err := Marshal(v)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v for input %q", err, data)
+		}
+		if _, err = Parse([]byte(out)); err != nil {
+			t.Fatalf("Parse() error = %v for re-marshaled %q (input %q)", err, out, data)
+		}
+	})
+}
+
+func TestUnclosedContainers(t *testing.T) {
+	objects := []string{``, ` `, `{`, `{ `, `{"a"`, `{"a" `, `{"a":`, `{"a":1`, `{"a":1 `, `{"a":1,`, `{"a":1, `, `{"a":[`, `{"a":{"b":1}`}
+	arrays := []string{``, `[`, `[ `, `[1`, `[1 `, `[1,`, `[1, `, `[{`, `[{"a":1`, `[[1]`}
+
+	read := map[string]func(*Scanner) error{
+		"ReadValue": func(s *Scanner) error { _, err := ReadValue(s); return err },
+		"SkipValue": SkipValue,
+		"CopyValue": func(s *Scanner) error { return CopyValue(io.Discard, s) },
+	}
+	readObject := map[string]func(*Scanner) error{
+		"ReadObject":         func(s *Scanner) error { _, err := ReadObject(s); return err },
+		"ReadObjectCallback": func(s *Scanner) error { return ReadObjectCallback(s, func(string, any) error { return nil }) },
+	}
+	readArray := map[string]func(*Scanner) error{
+		"ReadArray": func(s *Scanner) error { _, err := ReadArray(s); return err },
+	}
+
+	check := func(inputs []string, fns ...map[string]func(*Scanner) error) {
+		for _, input := range inputs {
+			for _, m := range fns {
+				for name, fn := range m {
+					s := NewScanner([]byte(input))
+					if err := fn(s); err != ErrUnexpectedEOF || s.Offset() != len(input) {
+						t.Errorf("%s(%q) error = %v at offset %d, want %v at %d", name, input, err, s.Offset(), ErrUnexpectedEOF, len(input))
+					}
+				}
+			}
+		}
+	}
+	check(objects, read, readObject)
+	check(arrays, read, readArray)
+}
+
+func TestScannerMaxDepth(t *testing.T) {
+	nested := func(n int) string {
+		return strings.Repeat(`[{"a":`, n/2) + strings.Repeat("[", n%2) + "null" +
+			strings.Repeat("]", n%2) + strings.Repeat("}]", n/2)
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		opts    []any
+		wantErr error
+	}{
+		{name: "default at limit", input: nested(DefaultMaxDepth), wantErr: nil},
+		{name: "default over limit", input: nested(DefaultMaxDepth + 1), wantErr: ErrMaxDepthExceeded},
+		{name: "custom at limit", input: nested(3), opts: []any{ScannerMaxDepth(3)}, wantErr: nil},
+		{name: "custom over limit", input: nested(4), opts: []any{ScannerMaxDepth(3)}, wantErr: ErrMaxDepthExceeded},
+		{name: "siblings do not add up", input: `[[1],[2],[[3]]]`, opts: []any{ScannerMaxDepth(3)}, wantErr: nil},
+		{name: "disabled", input: nested(DefaultMaxDepth + 1), opts: []any{ScannerMaxDepth(0)}, wantErr: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner([]byte(tt.input), tt.opts...)
+			_, err := ReadValue(s)
+			if err == nil {
+				err = s.Finalize()
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ReadValue() error = %v, want %v", err, tt.wantErr)
+			}
+
+			s = NewScanner([]byte(tt.input), tt.opts...)
+			err = CopyValue(io.Discard, s)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CopyValue() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	s := NewScanner([]byte(`[[1]]`), ScannerMaxDepth(1))
+	if err := ReadArrayCallback(s, func(any) error { return nil }); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("ReadArrayCallback() error = %v, want %v", err, ErrMaxDepthExceeded)
+	}
+	s = NewScanner([]byte(`{"a":{}}`), ScannerMaxDepth(1))
+	if err := ReadObjectCallback(s, func(string, any) error { return nil }); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("ReadObjectCallback() error = %v, want %v", err, ErrMaxDepthExceeded)
+	}
+}
+
+func TestMaxDepthError(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		maxDepth int
+		wantPath string
+	}{
+		{name: "first element", input: `[[]]`, maxDepth: 1, wantPath: "/0"},
+		{name: "array element", input: `[1,[2,[3]]]`, maxDepth: 2, wantPath: "/1/1"},
+		{name: "object member", input: `{"a":{"b/c":{"d":{}}}}`, maxDepth: 3, wantPath: "/a/b~1c/d"},
+		{name: "mixed", input: `[{"a":1},{"b":[0,{}]}]`, maxDepth: 3, wantPath: "/1/b/1"},
+	}
+
+	check := func(t *testing.T, fn string, err error, limit int, wantPath string) {
+		t.Helper()
+		var de *MaxDepthError
+		if !errors.As(err, &de) {
+			t.Fatalf("%s() error = %v, want *MaxDepthError", fn, err)
+		}
+		if de.Limit != limit || de.Path != wantPath {
+			t.Errorf("%s() error = %+v, want limit %d at %q", fn, *de, limit, wantPath)
+		}
+		if !errors.Is(err, ErrMaxDepthExceeded) {
+			t.Errorf("%s() error does not match ErrMaxDepthExceeded", fn)
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := tt.maxDepth
+			_, err := ReadValue(NewScanner([]byte(tt.input), ScannerMaxDepth(limit)))
+			check(t, "ReadValue", err, limit, tt.wantPath)
+			err = SkipValue(NewScanner([]byte(tt.input), ScannerMaxDepth(limit)))
+			check(t, "SkipValue", err, limit, tt.wantPath)
+			err = CopyValue(io.Discard, NewScanner([]byte(tt.input), ScannerMaxDepth(limit)))
+			check(t, "CopyValue", err, limit, tt.wantPath)
+		})
+	}
+
+	s := NewScanner([]byte(`[0,[[1]]]`), ScannerMaxDepth(2))
+	err := ReadArrayCallback(s, func(any) error { return nil })
+	check(t, "ReadArrayCallback", err, 2, "/1/0")
+	s = NewScanner([]byte(`{"a":1,"b":{"c":{}}}`), ScannerMaxDepth(2))
+	err = ReadObjectCallback(s, func(string, any) error { return nil })
+	check(t, "ReadObjectCallback", err, 2, "/b/c")
+}
+
+func TestScannerInternKeys(t *testing.T) {
+	input := `[{"id":1,"name":"a"},{"id":2,"name":"b"},{"id":3,"name":"c","extra":{"id":4}}]`
+
+	for _, flags := range []ScannerFlag{0, ScannerFlagInternKeys} {
+		s := NewScanner([]byte(input), flags)
+		v, err := ReadValue(s)
+		if err != nil {
+			t.Fatalf("ReadValue() error = %v", err)
+		}
+		want, _ := Parse([]byte(input))
+		if !reflect.DeepEqual(v, want) {
+			t.Errorf("ReadValue() = %v, want %v", v, want)
+		}
+
+		// collect the backing data of every "id" and "name" key
+		ids := map[*byte]bool{}
+		names := map[*byte]bool{}
+		var walk func(v any)
+		walk = func(v any) {
+			switch v := v.(type) {
+			case []any:
+				for _, e := range v {
+					walk(e)
+				}
+			case map[string]any:
+				for k, e := range v {
+					switch k {
+					case "id":
+						ids[unsafe.StringData(k)] = true
+					case "name":
+						names[unsafe.StringData(k)] = true
+					}
+					walk(e)
+				}
+			}
+		}
+		walk(v)
+
+		wantShared := flags&ScannerFlagInternKeys != 0
+		if shared := len(ids) == 1 && len(names) == 1; shared != wantShared {
+			t.Errorf("flags %v: keys shared = %v, want %v", flags, shared, wantShared)
+		}
+	}
+}
+
+func BenchmarkReadValueKeys(b *testing.B) {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for i := 0; i < 10000; i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `{"id":%d,"name":"user%d","email":"user%d@example.com","active":true}`, i, i, i)
+	}
+	sb.WriteByte(']')
+	data := []byte(sb.String())
+
+	for _, bm := range []struct {
+		name  string
+		flags ScannerFlag
+	}{
+		{"Default", 0},
+		{"InternKeys", ScannerFlagInternKeys},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if _, err := ReadValue(NewScanner(data, bm.flags)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestReadValueExact(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    []any
+		want    any
+		wantErr error
+	}{
+		{name: "value", input: `[1] `, want: []any{1.0}},
+		{name: "leading whitespace", input: " [1]", opts: []any{ScannerFlagDoNotSkipInitialWhitespace}, wantErr: ErrUnexpectedToken},
+		{name: "leading BOM", input: "\xef\xbb\xbf1", opts: []any{ScannerFlagDoNotSkipBOM}, wantErr: ErrUnexpectedBOM},
+		{name: "tolerated BOM", input: "\xef\xbb\xbf1", opts: []any{ScannerFlagDoNotSkipBOM, ScannerFlagDoNotSkipInitialWhitespace, ScannerFlagTolerateBOM}, wantErr: ErrUnexpectedBOM},
+		{name: "empty", input: ``, wantErr: ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScannerString(tt.input, tt.opts...)
+			got, err := ReadValueExact(s)
+			if err != tt.wantErr {
+				t.Fatalf("ReadValueExact() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadValueExact() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// a protocol with significant whitespace: values separated by exactly one
+	// space, the whitespace handling is decided per call
+	s := NewScannerString(" 1 2  3", ScannerFlagDoNotSkipInitialWhitespace)
+	if !s.SkipWhitespace() || s.SkipWhitespace() {
+		t.Fatal("SkipWhitespace() did not report the leading space only")
+	}
+	var got []any
+	for {
+		v, err := ReadValueExact(s)
+		if err != nil {
+			if err != ErrUnexpectedToken || s.Offset() != 5 {
+				t.Errorf("ReadValueExact() error = %v at %d, want %v at 5", err, s.Offset(), ErrUnexpectedToken)
+			}
+			break
+		}
+		got = append(got, v)
+		if !s.skipByte(' ') {
+			break
+		}
+	}
+	if !reflect.DeepEqual(got, []any{1.0, 2.0}) {
+		t.Errorf("ReadValueExact() read %v, want [1 2]", got)
+	}
+}
+
+func TestReadValueN(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		n       int
+		want    any
+		wantErr error
+		rest    string // data after the window
+	}{
+		{name: "exact", input: `{"a":1}[2]`, n: 7, want: map[string]any{"a": 1.0}, rest: `[2]`},
+		{name: "trailing whitespace", input: "[1]  \n\"x\"", n: 6, want: []any{1.0}, rest: `"x"`},
+		{name: "number", input: `123,456`, n: 3, want: 123.0, rest: `,456`},
+		{name: "number before whitespace", input: `123 456`, n: 3, want: 123.0, rest: ` 456`},
+		{name: "number beyond window", input: `123`, n: 2, wantErr: ErrUnexpectedEOF},
+		{name: "fraction beyond window", input: `1.5`, n: 1, wantErr: ErrUnexpectedEOF},
+		{name: "exponent beyond window", input: `1e51`, n: 3, wantErr: ErrUnexpectedEOF},
+		{name: "literal before digits", input: `true5`, n: 4, want: true, rest: `5`},
+		{name: "value beyond window", input: `{"a":1}`, n: 5, wantErr: ErrUnexpectedEOF},
+		{name: "string beyond window", input: `"abc"`, n: 3, wantErr: ErrUnexpectedEOF},
+		{name: "short value", input: `[1] 2`, n: 5, wantErr: ErrUnexpectedToken},
+		{name: "empty window", input: `1`, n: 0, wantErr: ErrUnexpectedEOF},
+		{name: "window beyond data", input: `[1]`, n: 4, wantErr: ErrUnexpectedEOF},
+		{name: "negative window", input: `[1]`, n: -1, wantErr: ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScannerString(tt.input)
+			got, err := ReadValueN(s, tt.n)
+			if err != tt.wantErr {
+				t.Fatalf("ReadValueN() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadValueN() = %v, want %v", got, tt.want)
+			}
+			if rest := string(s.Remaining()); rest != tt.rest {
+				t.Errorf("Remaining() = %q, want %q", rest, tt.rest)
+			}
+		})
+	}
+}
+
+func TestReadValueRaw(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    any
+		wantRaw string
+		wantErr error
+	}{
+		{name: "number", input: ` 1.50 `, want: 1.5, wantRaw: `1.50`},
+		{name: "string", input: `"abc"`, want: "abc", wantRaw: `"abc"`},
+		{name: "literal", input: "\ttrue\n", want: true, wantRaw: `true`},
+		{name: "object", input: ` { "a" : [ 1 , 2 ] } ,`, want: map[string]any{"a": []any{1.0, 2.0}}, wantRaw: `{ "a" : [ 1 , 2 ] }`},
+		{name: "invalid", input: `[1,`, wantErr: ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner([]byte(tt.input))
+			got, raw, err := ReadValueRaw(s)
+			if err != tt.wantErr {
+				t.Fatalf("ReadValueRaw() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if raw != nil {
+					t.Errorf("ReadValueRaw() raw = %q, want nil", raw)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadValueRaw() value = %v, want %v", got, tt.want)
+			}
+			if string(raw) != tt.wantRaw {
+				t.Errorf("ReadValueRaw() raw = %q, want %q", raw, tt.wantRaw)
+			}
+		})
+	}
+
+	// consecutive values
+	s := NewScanner([]byte(`[1] {"b":2}`))
+	for _, want := range []string{`[1]`, `{"b":2}`} {
+		_, raw, err := ReadValueRaw(s)
+		if err != nil || string(raw) != want {
+			t.Errorf("ReadValueRaw() = %q, %v, want %q", raw, err, want)
+		}
+	}
+}
+
+func TestReadObjectCallbackRaw(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr error
+	}{
+		{name: "empty", input: `{ }`, want: nil},
+		{name: "members", input: `{ "a" : 1.50 , "b":{ "c" : [ 1 , "x" ] },"d":null }`,
+			want: []string{`a=1.50`, `b={ "c" : [ 1 , "x" ] }`, `d=null`}},
+		{name: "escaped key", input: `{"a\"b":"\u00e9"}`, want: []string{`a"b="\u00e9"`}},
+		{name: "invalid value", input: `{"a":1,"b":[1,}`, want: []string{`a=1`}, wantErr: ErrUnexpectedToken},
+		{name: "truncated", input: `{"a":1`, want: []string{`a=1`}, wantErr: ErrUnexpectedEOF},
+		{name: "not an object", input: `[1]`, wantErr: ErrUnexpectedToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := ReadObjectCallbackRaw(NewScanner([]byte(tt.input)), func(k string, raw []byte) error {
+				got = append(got, k+"="+string(raw))
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ReadObjectCallbackRaw() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadObjectCallbackRaw() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// pass members through, transforming one
+	s := NewScanner([]byte(`{"id": 7, "name": "x", "tags": ["a"]}`))
+	var out []byte
+	err := ReadObjectCallbackRaw(s, func(k string, raw []byte) error {
+		if k == "name" {
+			raw = []byte(`"X"`)
+		}
+		out = append(AppendString(append(out, ','), k), ':')
+		out = append(out, raw...)
+		return nil
+	})
+	out[0] = '{'
+	if got, want := string(append(out, '}')), `{"id":7,"name":"X","tags":["a"]}`; err != nil || got != want {
+		t.Errorf("ReadObjectCallbackRaw() = %s, %v, want %s", got, err, want)
+	}
+
+	// callback errors stop reading
+	testErr := errors.New("test error")
+	err = ReadObjectCallbackRaw(NewScanner([]byte(`{"a":1,"b":2}`)), func(string, []byte) error { return testErr })
+	if err != testErr {
+		t.Errorf("ReadObjectCallbackRaw() error = %v, want %v", err, testErr)
+	}
+}
+
+// cents is a fixed-point amount used to test NumberConverter
+type cents int64
+
+func TestScannerNumberConverter(t *testing.T) {
+	errNotMoney := errors.New("not a money amount")
+	toCents := func(token string) (any, error) {
+		whole, frac, _ := strings.Cut(token, ".")
+		if len(frac) > 2 || strings.ContainsAny(token, "eE") {
+			return nil, errNotMoney
+		}
+		frac += strings.Repeat("0", 2-len(frac))
+		v, err := strconv.ParseInt(whole+frac, 10, 64)
+		return cents(v), err
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		opts    []any
+		want    any
+		wantErr error
+	}{
+		{name: "scalar", input: `12.5`, want: cents(1250)},
+		{name: "nested", input: `{"a":[1,0.01,-3.10]}`, want: map[string]any{"a": []any{cents(100), cents(1), cents(-310)}}},
+		{name: "converter error", input: `[1, 1.001]`, wantErr: errNotMoney},
+		{name: "syntax checked first", input: `[1.]`, wantErr: ErrInvalidNumber},
+		{name: "leading zeros trimmed", input: `[007.50]`, opts: []any{ScannerFlagAllowLeadingZeros}, want: []any{cents(750)}},
+		{name: "precedes UseNumber", input: `1`, opts: []any{ScannerFlagUseNumber}, want: cents(100)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, conv := range []any{NumberConverter(toCents), toCents} {
+				got, err := Parse([]byte(tt.input), append(tt.opts, conv)...)
+				if err != tt.wantErr {
+					t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Parse() = %#v, want %#v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestScannerEscapedKeyHandler(t *testing.T) {
+	errSmuggled := errors.New("escaped key")
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "plain keys", input: `{"foo":1,"bar":{"baz":2}}`, want: nil},
+		{name: "nul escape", input: `{"foobar":1,"foo\u0000bar":2}`, want: []string{"foo\x00bar"}},
+		{name: "letter escape", input: `{"\u0061dmin":true}`, want: []string{"admin"}},
+		{name: "nested", input: `[{"a":{"b\/c":[{"\"":0}]}}]`, want: []string{"b/c", `"`}},
+		{name: "escaped values ignored", input: `{"k":"v\u0000","l":["\n"]}`, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			handler := EscapedKeyHandler(func(key string) error {
+				got = append(got, key)
+				return nil
+			})
+			if _, err := Parse([]byte(tt.input), handler); err != nil {
+				t.Fatalf("Parse() unexpected error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() escaped keys = %q, want %q", got, tt.want)
+			}
+
+			got = nil
+			if err := CopyValue(io.Discard, NewScanner([]byte(tt.input), handler, ScannerFlagInternKeys)); err != nil {
+				t.Fatalf("CopyValue() unexpected error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CopyValue() escaped keys = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// rejecting keys, through ReadObjectCallback and a plain function
+	s := NewScanner([]byte(`{"a":1,"\u0062":2,"c":3}`), func(key string) error { return errSmuggled })
+	var keys []string
+	err := ReadObjectCallback(s, func(k string, v any) error {
+		keys = append(keys, k)
+		return nil
+	})
+	if err != errSmuggled {
+		t.Errorf("ReadObjectCallback() error = %v, want %v", err, errSmuggled)
+	}
+	if !reflect.DeepEqual(keys, []string{"a"}) {
+		t.Errorf("ReadObjectCallback() keys = %q, want %q", keys, []string{"a"})
+	}
+}
+
+func TestScannerKeyTransform(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    []any
+		want    any
+		wantErr error
+	}{
+		{name: "lowercase", input: `{"Name":"a","NESTED":{"Key":[{"X":1}]}}`, opts: []any{KeyTransform(strings.ToLower)},
+			want: map[string]any{"name": "a", "nested": map[string]any{"key": []any{map[string]any{"x": 1.0}}}}},
+		{name: "plain function", input: `{"a_b":1}`, opts: []any{func(k string) string { return strings.ReplaceAll(k, "_", "") }},
+			want: map[string]any{"ab": 1.0}},
+		{name: "collision last wins", input: `{"Key":1,"KEY":2}`, opts: []any{KeyTransform(strings.ToLower)},
+			want: map[string]any{"key": 2.0}},
+		{name: "collision rejected", input: `{"Key":1,"KEY":2}`, opts: []any{KeyTransform(strings.ToLower), RejectDuplicateKeys{}},
+			wantErr: ErrDuplicateKey},
+		{name: "duplicate rejected", input: `[{"a":1},{"a":2,"b":3,"a":4}]`, opts: []any{RejectDuplicateKeys{}},
+			wantErr: ErrDuplicateKey},
+		{name: "no duplicates", input: `[{"a":1},{"a":2,"b":{"a":3}}]`, opts: []any{RejectDuplicateKeys{}},
+			want: []any{map[string]any{"a": 1.0}, map[string]any{"a": 2.0, "b": map[string]any{"a": 3.0}}}},
+		{name: "escaped and interned", input: `[{"A":1},{"A":2}]`, opts: []any{KeyTransform(strings.ToLower), ScannerFlagInternKeys},
+			want: []any{map[string]any{"a": 1.0}, map[string]any{"a": 2.0}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.input), tt.opts...)
+			if err != tt.wantErr {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+
+			var sb strings.Builder
+			err = CopyValue(&sb, NewScanner([]byte(tt.input), tt.opts...))
+			if err != tt.wantErr {
+				t.Fatalf("CopyValue() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// ReadObjectCallback sees the transformed keys
+	s := NewScanner([]byte(`{"A":1,"B":2,"a":3}`), KeyTransform(strings.ToLower), RejectDuplicateKeys{})
+	var keys []string
+	err := ReadObjectCallback(s, func(k string, v any) error {
+		keys = append(keys, k)
+		return nil
+	})
+	if err != ErrDuplicateKey || !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Errorf("ReadObjectCallback() = %q, %v, want %q, %v", keys, err, []string{"a", "b"}, ErrDuplicateKey)
+	}
+}
+
+func TestReadValueVeryDeep(t *testing.T) {
+	const depth = 100000
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "arrays", input: strings.Repeat("[", depth) + "1" + strings.Repeat("]", depth)},
+		{name: "objects", input: strings.Repeat(`{"k":`, depth) + "{}" + strings.Repeat("}", depth)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := Parse([]byte(tt.input), ScannerMaxDepth(0))
+			if err != nil {
+				t.Fatalf("Parse() unexpected error = %v", err)
+			}
+			n := 0
+			for {
+				switch c := v.(type) {
+				case []any:
+					v = c[0]
+				case map[string]any:
+					v = c["k"]
+				default:
+					v = nil
+				}
+				if v == nil {
+					break
+				}
+				n++
+			}
+			if n != depth {
+				t.Errorf("Parse() depth = %d, want %d", n, depth)
+			}
+
+			s := NewScanner([]byte(tt.input), ScannerMaxDepth(0))
+			if err := SkipValue(s); err != nil {
+				t.Errorf("SkipValue() unexpected error = %v", err)
+			}
+
+			s = NewScanner([]byte(tt.input[:len(tt.input)-1]), ScannerMaxDepth(0))
+			if err := SkipValue(s); err != ErrUnexpectedEOF {
+				t.Errorf("SkipValue() truncated error = %v, want %v", err, ErrUnexpectedEOF)
+			}
+		})
+	}
+}
+
+func TestSkipValue(t *testing.T) {
+	// SkipValue accepts and rejects exactly what ReadValue does
+	for _, tt := range NSTTestSuiteData {
+		t.Run(tt.Name, func(t *testing.T) {
+			for _, flags := range []ScannerFlag{0, ScannerFlagStrictRFC8259} {
+				s := NewScanner([]byte(tt.Content), flags)
+				_, wantErr := ReadValue(s)
+				wantOffset := s.Offset()
+
+				s = NewScanner([]byte(tt.Content), flags)
+				err := SkipValue(s)
+				if err != wantErr {
+					t.Errorf("SkipValue() error = %v, ReadValue() error = %v", err, wantErr)
+				}
+				if s.Offset() != wantOffset {
+					t.Errorf("SkipValue() offset = %d, ReadValue() offset = %d", s.Offset(), wantOffset)
+				}
+			}
+		})
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		opts    []any
+		wantErr error
+	}{
+		{name: "duplicate keys", input: `{"a":{"b":1,"b":2}}`, opts: []any{RejectDuplicateKeys{}}, wantErr: ErrDuplicateKey},
+		{name: "duplicate keys in siblings", input: `[{"a":1},{"a":2}]`, opts: []any{RejectDuplicateKeys{}}},
+		{name: "member limit", input: `{"a":1,"b":2}`, opts: []any{ScannerMaxObjectMembers(1)}, wantErr: ErrTooManyMembers},
+		{name: "value limit", input: `[1,2]`, opts: []any{ScannerMaxValues(2)}, wantErr: ErrValueLimitExceeded},
+		{name: "depth limit", input: `[[[]]]`, opts: []any{ScannerMaxDepth(2)}, wantErr: ErrMaxDepthExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SkipValue(NewScanner([]byte(tt.input), tt.opts...)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("SkipValue() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// keys are not decoded, but still reported in paths
+	input := `{"a":{"b\u00e9":[{"c/d":{}}]}}`
+	var mde *MaxDepthError
+	if err := SkipValue(NewScanner([]byte(input), ScannerMaxDepth(4))); !errors.As(err, &mde) || mde.Path != "/a/b\u00e9/0/c~1d" {
+		t.Errorf("SkipValue() error = %v, want *MaxDepthError at /a/b\u00e9/0/c~1d", err)
+	}
+
+	// only the scanner is allocated
+	data := objectDocument()
+	if allocs := testing.AllocsPerRun(10, func() {
+		if err := SkipValue(NewScanner(data)); err != nil {
+			t.Fatal(err)
+		}
+	}); allocs > 1 {
+		t.Errorf("SkipValue() allocs = %v, want 1", allocs)
+	}
+
+	// consecutive values
+	s := NewScanner([]byte(`{"a":[1,2]} "x" 3`))
+	for i := 0; i < 3; i++ {
+		if err := SkipValue(s); err != nil {
+			t.Fatalf("SkipValue() #%d unexpected error = %v", i, err)
+		}
+	}
+	if err := s.Finalize(); err != nil {
+		t.Errorf("Finalize() error = %v", err)
+	}
+}
+
+func TestUnexpectedBOM(t *testing.T) {
+	bom := "\ufeff"
+	tests := []struct {
+		name    string
+		input   string
+		wantErr error
+	}{
+		{name: "leading", input: bom + `{}`},
+		{name: "between values", input: `{}` + bom + `{}`, wantErr: ErrUnexpectedBOM},
+		{name: "after whitespace", input: "{}\n" + bom + `{}`, wantErr: ErrUnexpectedBOM},
+		{name: "in array", input: `[` + bom + `1]`, wantErr: ErrUnexpectedBOM},
+		{name: "member value", input: `{"a":` + bom + `1}`, wantErr: ErrUnexpectedBOM},
+		{name: "partial", input: "{}\xef\xbb", wantErr: ErrUnexpectedToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := func(fn string, read func(s *Scanner) error) {
+				s := NewScanner([]byte(tt.input))
+				err := read(s)
+				if err == nil {
+					err = s.Finalize()
+				}
+				if err != tt.wantErr {
+					t.Errorf("%s() error = %v, want %v", fn, err, tt.wantErr)
+				}
+			}
+			check("ReadValue", func(s *Scanner) error { _, err := ReadValue(s); return err })
+			check("SkipValue", SkipValue)
+			check("CopyValue", func(s *Scanner) error { return CopyValue(io.Discard, s) })
+		})
+	}
+}
+
+func TestNumberWithGarbage(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantErr    error
+		wantOffset int
+	}{
+		{name: "letter", input: `-1x`, wantErr: ErrInvalidNumber, wantOffset: 2},
+		{name: "fraction", input: `1.5abc`, wantErr: ErrInvalidNumber, wantOffset: 3},
+		{name: "exponent", input: `1e5f`, wantErr: ErrInvalidNumber, wantOffset: 3},
+		{name: "hex", input: `0x1`, wantErr: ErrInvalidNumber, wantOffset: 1},
+		{name: "in array", input: `[1x]`, wantErr: ErrInvalidNumber, wantOffset: 2},
+		{name: "member value", input: `{"a":2_0}`, wantErr: ErrInvalidNumber, wantOffset: 6},
+		{name: "string", input: `[1"a"]`, wantErr: ErrInvalidNumber, wantOffset: 2},
+		{name: "terminators", input: "[1,2 ,{\"a\":3}\t]"},
+		{name: "whitespace", input: "-1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := func(fn string, read func(s *Scanner) error) {
+				s := NewScanner([]byte(tt.input))
+				err := read(s)
+				if err == nil {
+					err = s.Finalize()
+				}
+				if err != tt.wantErr {
+					t.Errorf("%s() error = %v, want %v", fn, err, tt.wantErr)
+				}
+				if err != nil && s.Offset() != tt.wantOffset {
+					t.Errorf("%s() offset = %d, want %d", fn, s.Offset(), tt.wantOffset)
+				}
+			}
+			check("ReadValue", func(s *Scanner) error { _, err := ReadValue(s); return err })
+			check("SkipValue", SkipValue)
+			check("CopyValue", func(s *Scanner) error { return CopyValue(io.Discard, s) })
+		})
+	}
+}
+
+func TestDuplicateKeysAsArray(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    []any
+		want    any
+		wantErr error
+	}{
+		{name: "single", input: `{"a":1}`, want: map[string]any{"a": 1.0}},
+		{name: "repeated", input: `{"a":1,"b":2,"a":3}`,
+			want: map[string]any{"a": []any{1.0, 3.0}, "b": 2.0}},
+		{name: "three times", input: `{"a":1,"a":2,"a":3}`,
+			want: map[string]any{"a": []any{1.0, 2.0, 3.0}}},
+		{name: "array values", input: `{"a":[1],"a":[2]}`,
+			want: map[string]any{"a": []any{[]any{1.0}, []any{2.0}}}},
+		{name: "nested", input: `[{"a":{"b":1,"b":null}},{"a":1}]`,
+			want: []any{map[string]any{"a": map[string]any{"b": []any{1.0, nil}}}, map[string]any{"a": 1.0}}},
+		{name: "after transform", input: `{"Set-Cookie":"x","set-cookie":"y"}`, opts: []any{KeyTransform(strings.ToLower)},
+			want: map[string]any{"set-cookie": []any{"x", "y"}}},
+		{name: "rejection wins", input: `{"a":1,"a":2}`, opts: []any{RejectDuplicateKeys{}}, wantErr: ErrDuplicateKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]any{DuplicateKeysAsArray{}}, tt.opts...)
+			got, err := Parse([]byte(tt.input), opts...)
+			if err != tt.wantErr {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+
+			if _, isObj := tt.want.(map[string]any); !isObj {
+				return
+			}
+			obj, err := ReadObject(NewScanner([]byte(tt.input), opts...))
+			if err != nil {
+				t.Fatalf("ReadObject() error = %v", err)
+			}
+			if !reflect.DeepEqual(obj, tt.want) {
+				t.Errorf("ReadObject() = %v, want %v", obj, tt.want)
+			}
+		})
+	}
+}
+
+func TestScannerTolerateBOM(t *testing.T) {
+	bom := "\ufeff"
+	input := bom + `{"a":1}` + "\n" + bom + `[` + bom + `2, ` + bom + `3]` + bom + bom + ` "x"` + bom
+	s := NewScanner([]byte(input), ScannerFlagTolerateBOM)
+	var got []any
+	for {
+		s.skipWhitespace()
+		if s.IsEOF() {
+			break
+		}
+		v, err := ReadValue(s)
+		if err != nil {
+			t.Fatalf("ReadValue() error = %v", err)
+		}
+		got = append(got, v)
+	}
+	want := []any{map[string]any{"a": 1.0}, []any{2.0, 3.0}, "x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadValue() = %v, want %v", got, want)
+	}
+
+	// a BOM is not whitespace inside tokens
+	for _, input := range []string{`"a` + bom + `"`, `[1` + bom + `2]`, `tr` + bom + `ue`} {
+		v, err := Parse([]byte(input), ScannerFlagTolerateBOM)
+		if input == `"a`+bom+`"` {
+			if err != nil || v != "a"+bom {
+				t.Errorf("Parse(%q) = %q, %v, want the BOM kept in the string", input, v, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", input)
+		}
+	}
+
+	// without the flag the same input is rejected
+	if _, err := Parse([]byte(`[1,` + bom + `2]`)); err != ErrUnexpectedBOM {
+		t.Errorf("Parse() error = %v, want %v", err, ErrUnexpectedBOM)
+	}
+	if err := ValidateStream(strings.NewReader(`[1,`+bom+`2]`+bom), ScannerFlagTolerateBOM); err != nil {
+		t.Errorf("ValidateStream() error = %v", err)
+	}
+}
+
+func TestScannerStrictRFC8259(t *testing.T) {
+	// the implementation-defined suite cases that are rejected in strict mode,
+	// all others keep their outcome; the README lists them too
+	flipped := map[string]bool{
+		"number_double_huge_neg_exp":                   true,
+		"number_real_underflow":                        true,
+		"object_key_lone_2nd_surrogate":                true,
+		"string_1st_surrogate_but_2nd_missing":         true,
+		"string_1st_valid_surrogate_2nd_invalid":       true,
+		"string_UTF-8_invalid_sequence":                true,
+		"string_UTF8_surrogate_U+D800":                 true,
+		"string_incomplete_surrogate_and_escape_valid": true,
+		"string_incomplete_surrogate_pair":             true,
+		"string_incomplete_surrogates_escape_valid":    true,
+		"string_invalid_lonely_surrogate":              true,
+		"string_invalid_surrogate":                     true,
+		"string_invalid_utf-8":                         true,
+		"string_inverted_surrogates_U+1D11E":           true,
+		"string_iso_latin_1":                           true,
+		"string_lone_second_surrogate":                 true,
+		"string_lone_utf8_continuation_byte":           true,
+		"string_not_in_unicode_range":                  true,
+		"string_overlong_sequence_2_bytes":             true,
+		"string_overlong_sequence_6_bytes":             true,
+		"string_overlong_sequence_6_bytes_null":        true,
+		"string_truncated-utf-8":                       true,
+	}
+	for _, tt := range NSTTestSuiteData {
+		t.Run(tt.Name, func(t *testing.T) {
+			read := func(opts ...any) error {
+				s := NewScanner([]byte(tt.Content), opts...)
+				_, err := ReadValue(s)
+				if err == nil {
+					err = s.Finalize()
+				}
+				return err
+			}
+			lenient, strict := read(), read(ScannerFlagStrictRFC8259)
+			if flipped[tt.Name] {
+				if lenient != nil || strict == nil {
+					t.Errorf("error = %v, strict error = %v, want only strict mode to fail", lenient, strict)
+				}
+			} else if (lenient == nil) != (strict == nil) {
+				t.Errorf("error = %v, strict error = %v, want the same outcome", lenient, strict)
+			}
+		})
+	}
+}
+
+func TestScannerSurrogates(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    []any
+		want    string
+		wantErr error
+	}{
+		{name: "pair", input: `"\ud834\udd1e"`, want: "\U0001d11e"},
+		{name: "emoji pair", input: `"a\uD83D\uDE00b"`, want: "a\U0001f600b"},
+		{name: "lone high", input: `"\ud834x"`, want: "\ufffdx"},
+		{name: "lone low", input: `"\udd1e"`, want: "\ufffd"},
+		{name: "inverted", input: `"\udd1e\ud834"`, want: "\ufffd\ufffd"},
+		{name: "high then BMP escape", input: `"\ud834\u0041"`, want: "\ufffdA"},
+		{name: "two highs then low", input: `"\ud834\ud834\udd1e"`, want: "\ufffd\U0001d11e"},
+		{name: "invalid second escape", input: `"\ud834\uzzzz"`, wantErr: ErrInvalidUnicodeEscape},
+		{name: "strict pair", input: `"\ud834\udd1e"`, opts: []any{ScannerFlagStrictSurrogates}, want: "\U0001d11e"},
+		{name: "strict lone", input: `"\ud834x"`, opts: []any{ScannerFlagStrictSurrogates}, wantErr: ErrInvalidUnicodeEscape},
+		{name: "strict UTF-8", input: "\"a\xffb\"", opts: []any{ScannerFlagStrictUTF8}, wantErr: ErrInvalidString},
+		{name: "strict UTF-8 escaped", input: "\"\\n\xff\"", opts: []any{ScannerFlagStrictUTF8}, wantErr: ErrInvalidString},
+		{name: "strict UTF-8 valid", input: "\"h\u00e9\"", opts: []any{ScannerFlagStrictUTF8}, want: "h\u00e9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.input), tt.opts...)
+			if err != tt.wantErr {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("Parse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScannerCapacityHints(t *testing.T) {
+	input := `[[1,"a,]",{"k":[2,3]},[]],[],[4]]`
+	want := []any{[]any{1.0, "a,]", map[string]any{"k": []any{2.0, 3.0}}, []any(nil)}, []any(nil), []any{4.0}}
+	for _, opts := range [][]any{
+		nil,
+		{ScannerFlagPreallocateArrays},
+		{ArrayCapacityHint(8), ObjectCapacityHint(8)},
+		{ScannerFlagPreallocateArrays, ArrayCapacityHint(2)},
+	} {
+		got, err := Parse([]byte(input), opts...)
+		if err != nil {
+			t.Fatalf("Parse(%v) error = %v", opts, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse(%v) = %v, want %v", opts, got, want)
+		}
+	}
+
+	// exact preallocation, from ReadValue and ReadArray
+	got, _ := Parse([]byte(`[1, [2, 3, 4], "x\",y", {"a": [5, 6]}]`), ScannerFlagPreallocateArrays)
+	arr := got.([]any)
+	if cap(arr) != 4 || cap(arr[1].([]any)) != 3 || cap(arr[3].(map[string]any)["a"].([]any)) != 2 {
+		t.Errorf("Parse() capacities = %d, %d, %d, want 4, 3, 2", cap(arr), cap(arr[1].([]any)), cap(arr[3].(map[string]any)["a"].([]any)))
+	}
+	arr, err := ReadArray(NewScanner([]byte(`[1,2,3,4,5]`), ScannerFlagPreallocateArrays))
+	if err != nil || len(arr) != 5 || cap(arr) != 5 {
+		t.Errorf("ReadArray() = %v (cap %d), %v, want 5 elements with capacity 5", arr, cap(arr), err)
+	}
+	arr, err = ReadArray(NewScanner([]byte(`[1]`), ArrayCapacityHint(10)))
+	if err != nil || len(arr) != 1 || cap(arr) != 10 {
+		t.Errorf("ReadArray() = %v (cap %d), %v, want 1 element with capacity 10", arr, cap(arr), err)
+	}
+	if arr, err = ReadArray(NewScanner([]byte(`[]`), ArrayCapacityHint(10))); err != nil || arr != nil {
+		t.Errorf("ReadArray() = %#v, %v, want nil", arr, err)
+	}
+
+	// counting is bounded by the value limit, and does not replace validation
+	s := NewScanner([]byte(`[`+strings.Repeat("1,", 1000)+`1]`), ScannerFlagPreallocateArrays, ScannerMaxValues(10))
+	if _, err := ReadValue(s); err != ErrValueLimitExceeded {
+		t.Errorf("ReadValue() error = %v, want %v", err, ErrValueLimitExceeded)
+	}
+	if _, err := Parse([]byte(`[1,2,}`), ScannerFlagPreallocateArrays); err != ErrUnexpectedToken {
+		t.Errorf("Parse() error = %v, want %v", err, ErrUnexpectedToken)
+	}
+}
+
+func largeUniformArray() []byte {
+	var sb strings.Builder
+	sb.WriteString("[")
+	for i := 0; i < 10000; i++ {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		fmt.Fprintf(&sb, `{"id":%d,"name":"item %d","tags":["a","b"]}`, i, i)
+	}
+	sb.WriteString("]")
+	return []byte(sb.String())
+}
+
+// objectDocument returns an object-heavy payload resembling an API response:
+// an array of records with nested objects, indented with two spaces
+func objectDocument() []byte {
+	var sb strings.Builder
+	sb.WriteString("[\n")
+	for i := 0; i < 1000; i++ {
+		if i > 0 {
+			sb.WriteString(",\n")
+		}
+		fmt.Fprintf(&sb, `  {
+    "id": %d,
+    "name": "user%d",
+    "email": "user%d@example.com",
+    "active": %t,
+    "score": %d.%d,
+    "address": {"street": "%d Main St", "city": "Springfield", "zip": "%05d"},
+    "tags": ["a", "b"],
+    "meta": {"created": "2024-01-02T03:04:05Z", "version": 3, "note": null}
+  }`, i, i, i, i%2 == 0, i%100, i%7, i, i)
+	}
+	sb.WriteString("\n]")
+	return []byte(sb.String())
+}
+
+func BenchmarkReadObjects(b *testing.B) {
+	data := objectDocument()
+	for _, bm := range []struct {
+		name string
+		read func(s *Scanner) error
+	}{
+		{"ReadValue", func(s *Scanner) error {
+			_, err := ReadValue(s)
+			return err
+		}},
+		{"SkipValue", SkipValue},
+		{"ReadArrayCallback", func(s *Scanner) error {
+			return ReadArrayCallback(s, func(v any) error { return nil })
+		}},
+		{"ReadObjectCallback", func(s *Scanner) error {
+			n := 0
+			s.skipByte('[')
+			for {
+				s.skipWhitespace()
+				if err := ReadObjectCallback(s, func(k string, v any) error {
+					n++
+					return nil
+				}); err != nil {
+					return err
+				}
+				s.skipWhitespace()
+				if !s.skipByte(',') {
+					return nil
+				}
+			}
+		}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if err := bm.read(NewScanner(data)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkReadValueLargeArray(b *testing.B) {
+	data := largeUniformArray()
+	for _, bc := range []struct {
+		name string
+		opts []any
+	}{
+		{"default", nil},
+		{"preallocated", []any{ScannerFlagPreallocateArrays}},
+		{"hinted", []any{ObjectCapacityHint(3), ArrayCapacityHint(2)}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if _, err := ReadValue(NewScanner(data, bc.opts...)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestScannerLossyIntegerHandler(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  []any
+		want  []string
+	}{
+		{name: "exact", input: `[9007199254740992, -9007199254740992, 123, 0, -0]`},
+		{name: "beyond 2^53", input: `[9007199254740993, -9007199254740993, 12345678901234567890]`,
+			want: []string{"9007199254740993", "-9007199254740993", "12345678901234567890"}},
+		{name: "not integers", input: `[9007199254740993.0, 1e300, 9007199254740993e0]`},
+		{name: "nested", input: `{"id":{"n":[18014398509481984]}}`, want: []string{"18014398509481984"}},
+		{name: "leading zeros", input: `[00009007199254740992, 09007199254740993]`, opts: []any{ScannerFlagAllowLeadingZeros},
+			want: []string{"09007199254740993"}},
+		{name: "Number mode", input: `9007199254740993`, opts: []any{ScannerFlagUseNumber}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			handler := LossyIntegerHandler(func(token string) error {
+				got = append(got, token)
+				return nil
+			})
+			if _, err := Parse([]byte(tt.input), append(tt.opts, handler)...); err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reported %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	errLossy := errors.New("lossy")
+	reject := LossyIntegerHandler(func(string) error { return errLossy })
+	if _, err := Parse([]byte(`{"id":9007199254740993}`), reject); err != errLossy {
+		t.Errorf("Parse() error = %v, want %v", err, errLossy)
+	}
+}
@@ -1,18 +1,37 @@
 package jsn
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"runtime"
 	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+	"unsafe"
 )
 
 var (
 	ErrUnexpectedToken        = errors.New("unexpected token")
-	ErrUnexpectedEOF          = errors.New("unexpected EOF")
+	ErrUnexpectedEOF          = errors.New("unexpected EOF") // data ends within a value, Scanner.Offset is then the length of the data
 	ErrInvalidNumber          = errors.New("invalid number")
 	ErrInvalidString          = errors.New("invalid string")
 	ErrInvalidUnicodeEscape   = errors.New("invalid unicode escape")
 	ErrNumericValueOutOfRange = errors.New("numeric value out of range")
+	ErrNumberNotInteger       = errors.New("number is not an integer")
+	ErrValueLimitExceeded     = errors.New("value limit exceeded")
+	ErrNumericUnderflow       = errors.New("numeric value underflow")
+	ErrTooManyMembers         = errors.New("too many object members")
+	ErrMaxDepthExceeded       = errors.New("maximum nesting depth exceeded")
+	ErrDuplicateKey           = errors.New("duplicate object key")
+	ErrInputTooLarge          = errors.New("input too large")
+	ErrUnexpectedBOM          = errors.New("unexpected byte order mark")
+	ErrJSUnsafeString         = errors.New("unescaped line or paragraph separator in string")
 )
 
 type ScannerFlag int
@@ -20,23 +39,315 @@ type ScannerFlag int
 const (
 	ScannerFlagDoNotSkipBOM ScannerFlag = 1 << iota
 	ScannerFlagDoNotSkipInitialWhitespace
+
+	// ScannerFlagStrictUnderflow rejects non-zero numbers that are too small to
+	// be represented as float64 (e.g. 123e-10000000) with ErrNumericUnderflow.
+	// By default such numbers silently become 0.
+	ScannerFlagStrictUnderflow
+
+	// ScannerFlagAllowLeadingZeros accepts numbers with leading zeros (e.g. 012
+	// or -01) and parses them as the equivalent decimal value. This is a
+	// deviation from the JSON spec, intended for zero-padding producers.
+	ScannerFlagAllowLeadingZeros
+
+	// ScannerFlagInternKeys makes the scanner reuse a single string for each
+	// distinct object key it reads, which saves allocations and memory when
+	// parsing many objects that share the same keys. Up to maxInternedKeys
+	// distinct keys are kept.
+	ScannerFlagInternKeys
+
+	// ScannerFlagUseNumber makes ReadValue return numbers as Number, holding
+	// their source text, instead of float64. The syntax is validated, but no
+	// range checks are applied.
+	ScannerFlagUseNumber
+
+	// ScannerFlagCollectStats makes the scanner collect the statistics
+	// reported by Stats
+	ScannerFlagCollectStats
+
+	// ScannerFlagTolerateBOM treats a UTF-8 byte order mark as whitespace
+	// wherever whitespace is allowed, e.g. between documents produced by
+	// concatenating BOM-prefixed files. This is a deviation from the JSON
+	// spec, which does not allow a BOM past the start of the text; without the
+	// flag such a BOM fails with ErrUnexpectedBOM.
+	ScannerFlagTolerateBOM
+
+	// ScannerFlagStrictSurrogates rejects \u escapes of UTF-16 surrogates that
+	// are not part of a high-low pair with ErrInvalidUnicodeEscape. By default
+	// such lone surrogates decode to U+FFFD. Valid pairs are always decoded to
+	// the code point they represent.
+	ScannerFlagStrictSurrogates
+
+	// ScannerFlagStrictUTF8 rejects strings that contain invalid UTF-8 with
+	// ErrInvalidString. By default the bytes are passed through as is.
+	ScannerFlagStrictUTF8
+
+	// ScannerFlagPreallocateArrays makes ReadValue and ReadArray count the
+	// elements of each array with a quick structural scan before reading it, so
+	// that the resulting slice is allocated once with the exact length instead
+	// of being grown. This pays off for large arrays, but costs an extra pass
+	// over the data of each array, which compounds with nesting.
+	ScannerFlagPreallocateArrays
+
+	// ScannerFlagWrapCallbackErrors makes ReadObjectCallback wrap errors
+	// returned by its callback in a *MemberError, which records the key and
+	// the position where the member starts
+	ScannerFlagWrapCallbackErrors
+
+	// ScannerFlagRequireFinalize is a debugging aid that logs a message with
+	// the standard logger when the scanner is garbage collected without
+	// Finalize or Close having been called, which usually means that trailing
+	// data was never checked. It relies on a runtime finalizer, which is only
+	// set up when the flag is given, so it costs nothing otherwise.
+	ScannerFlagRequireFinalize
+
+	// ScannerFlagAllowComments accepts // line comments and /* */ block
+	// comments wherever whitespace is allowed, as in JSONC configuration
+	// files. This is a deviation from the JSON spec. Comments are discarded,
+	// unless a CommentHandler is given. An unterminated block comment fails
+	// with ErrUnexpectedToken. DecodeStream does not support comments.
+	ScannerFlagAllowComments
+
+	// ScannerFlagLazyNumbers checks the syntax of numbers, leading zeros and
+	// dangling dots included, without converting them to float64. SkipValue
+	// and ValidateStream then do no conversion work at all, which speeds up
+	// structural validation of number-heavy documents, and ReadValue returns
+	// numbers as Number, as with ScannerFlagUseNumber. As no value is
+	// computed, numbers out of the float64 range are not rejected, and
+	// ScannerFlagStrictUnderflow and LossyIntegerHandler do not apply.
+	ScannerFlagLazyNumbers
+
+	// ScannerFlagAllowHexNumbers accepts JSON5 hexadecimal integers such as
+	// 0x1F or -0xff, a deviation from the JSON spec. They read like the
+	// equivalent decimal integer: as float64 from ReadValue, exactly from
+	// ReadInt64 and ReadUint64, and as a Number holding the decimal text with
+	// ScannerFlagUseNumber. Fractions and exponents are not allowed.
+	ScannerFlagAllowHexNumbers
+
+	// ScannerFlagAllowJSON5Numbers accepts the JSON5 forms of decimal numbers
+	// that JSON rejects: a leading plus sign (+1), and a decimal point without
+	// digits before or after it (.5, -.5, 5., 5.e3). This is a deviation from
+	// the JSON spec. The numbers read as if written in JSON, e.g. 0.5 and 5.0,
+	// which is also the text of a Number with ScannerFlagUseNumber.
+	ScannerFlagAllowJSON5Numbers
+
+	// ScannerFlagRejectJSUnsafe rejects strings, keys included, that contain
+	// an unescaped U+2028 LINE SEPARATOR or U+2029 PARAGRAPH SEPARATOR with
+	// ErrJSUnsafeString, leaving the offset at the character. Both are valid
+	// in JSON strings but end the line in older JavaScript, so that the
+	// strings cannot be embedded in scripts as is. Escaped forms (\u2028) are
+	// accepted.
+	ScannerFlagRejectJSUnsafe
 )
 
+// ScannerFlagStrictRFC8259 enables all the strict flags, for the strictest
+// interpretation of RFC 8259: numbers that underflow are rejected (overflowing
+// numbers always are), as are lone surrogates and invalid UTF-8 in strings.
+// Only the four whitespace characters of the spec are ever accepted. A BOM at
+// the start of the data is still skipped (see ScannerFlagDoNotSkipBOM), and
+// lenient flags given together with this one are still honored.
+const ScannerFlagStrictRFC8259 = ScannerFlagStrictUnderflow | ScannerFlagStrictSurrogates | ScannerFlagStrictUTF8
+
+// maxInternedKeys bounds the number of keys remembered with
+// ScannerFlagInternKeys, so that input with many unique keys does not grow the
+// table without limit
+const maxInternedKeys = 4096
+
+// ScannerMaxValues limits the total number of values (scalars and containers)
+// the scanner will parse, reading past the limit fails with
+// ErrValueLimitExceeded. This bounds the work spent on untrusted input that
+// expands into a huge number of tiny values. Zero means unlimited.
+type ScannerMaxValues int
+
+// ScannerMaxObjectMembers limits the number of members a single object may
+// have, reading past the limit fails with ErrTooManyMembers. This protects
+// against hash-collision and memory exhaustion attacks by bounding the size of
+// any one map[string]any. Zero means unlimited.
+type ScannerMaxObjectMembers int
+
+// ObjectCapacityHint is a scanner option that sets the initial capacity of the
+// maps built by ReadValue and ReadObject, to avoid rehashing when reading
+// documents with a known shape. It applies to every object, nested ones
+// included.
+type ObjectCapacityHint int
+
+// ArrayCapacityHint is a scanner option that sets the initial capacity of the
+// slices built by ReadValue and ReadArray, to avoid reallocation when reading
+// documents with a known shape. It applies to every non-empty array, nested
+// ones included, see also ScannerFlagPreallocateArrays.
+type ArrayCapacityHint int
+
+// ScannerMaxDepth limits the nesting depth of arrays and objects, reading past
+// the limit fails with a *MaxDepthError. A zero or negative value disables
+// the limit, the default is DefaultMaxDepth.
+//
+// ReadValue and SkipValue track nesting on the heap, so for them the limit
+// only bounds the work and memory spent on deep input. CopyValue is recursive
+// and relies on the limit to keep maliciously deep input from exhausting the
+// goroutine stack, as do Marshal and Decode on the resulting trees.
+type ScannerMaxDepth int
+
+// NumberConverter is a scanner option that converts every number read by
+// ReadValue from its source text, the returned value is used in the tree in
+// place of float64 (e.g. a fixed-point decimal type). The token has valid JSON
+// number syntax, errors returned by the converter are passed through as is.
+// A plain func(string) (any, error) is accepted as this option as well.
+type NumberConverter func(token string) (any, error)
+
+// EscapedKeyHandler is a scanner option that is called with every object key
+// that contained escape sequences (e.g. "foo\u0000bar" or "\u0041"), after the
+// escapes are decoded. Keys spelled differently in the source may decode to the
+// same or to confusable strings, this lets security tooling detect or reject
+// them. An error returned by the handler aborts reading and is passed through
+// as is. A plain func(string) error is accepted as this option as well.
+type EscapedKeyHandler func(key string) error
+
+// LossyIntegerHandler is a scanner option that is called with the source text
+// of every integer (a number without fraction or exponent) whose magnitude
+// exceeds 2^53, read by ReadValue into a float64 and therefore likely to lose
+// precision. This helps to discover where ScannerFlagUseNumber or ReadInt64 are
+// needed. An error returned by the handler aborts reading and is passed
+// through as is. Numbers read as Number or by a NumberConverter are not
+// reported. Unlike the other handlers, a plain func is not accepted for this
+// option, as it would be indistinguishable from EscapedKeyHandler.
+type LossyIntegerHandler func(token string) error
+
+// CommentHandler is a scanner option that is called with every comment skipped
+// with ScannerFlagAllowComments, instead of silently discarding it, e.g. for
+// formatters that preserve comments. The text includes the comment delimiters
+// (but not the line break that ends a line comment), line is the 1-based line
+// where the comment starts. A plain func(string, int) is accepted as this
+// option as well.
+type CommentHandler func(text string, line int)
+
+// KeyTransform is an option for NewScanner and Decode that is applied to every
+// object key before it is stored in a map, passed to a callback or matched
+// against struct fields, e.g. strings.ToLower for case-insensitive matching.
+// Keys that become equal after the transformation are handled like duplicate
+// keys, see RejectDuplicateKeys. A plain func(string) string is accepted as
+// this option as well.
+type KeyTransform func(key string) string
+
+// RejectDuplicateKeys is an option for NewScanner and Decode that makes objects
+// with repeated keys (after KeyTransform, if any) fail with ErrDuplicateKey.
+//
+// By default the last occurrence of a key wins when reading. Decode sees keys
+// collide only through KeyTransform, in which case the member whose original
+// key sorts last wins.
+type RejectDuplicateKeys struct{}
+
+// DuplicateKeysAsArray is an option for NewScanner that makes ReadValue and
+// ReadObject accumulate the values of a repeated key, as in header-like
+// structures, instead of keeping the last one. The first repetition replaces
+// the value with a []any holding both occurrences in document order, further
+// repetitions are appended to it.
+//
+// Consumers must expect either type under such keys: a key that occurs once
+// holds its value as is, which may itself be a []any. RejectDuplicateKeys takes
+// precedence when both are specified. ReadObjectCallback is not affected, its
+// callback receives every occurrence.
+type DuplicateKeysAsArray struct{}
+
+// EmptyAsNull is an option for Parse, ParseObject and ParseArray that makes
+// data consisting only of whitespace (or nothing at all, after a BOM) read as
+// null instead of failing with ErrUnexpectedEOF, e.g. to treat a missing
+// request body as null. Other scanner functions ignore it.
+type EmptyAsNull struct{}
+
+// ReaderMaxLength limits the number of bytes NewScannerFromReader and
+// ValidateStream read from their io.Reader (after gzip decompression), longer
+// input fails with ErrInputTooLarge as soon as the limit is crossed. This
+// bounds the memory spent on untrusted request bodies. Zero means unlimited.
+type ReaderMaxLength int
+
+// ScanStats holds the statistics collected by a scanner created with
+// ScannerFlagCollectStats, see Scanner.Stats
+type ScanStats struct {
+	MaxDepth    int // deepest nesting of arrays and objects reached
+	Values      int // number of values read (scalars and containers)
+	Keys        int // number of object keys read
+	StringBytes int // total length of decoded strings, including keys
+}
+
+// DefaultMaxDepth is the nesting depth limit used unless ScannerMaxDepth is
+// specified
+const DefaultMaxDepth = 10000
+
 // Scanner is a simple parser for JSON data
 type Scanner struct {
 	data  []byte
 	cur   int
 	flags ScannerFlag
+
+	maxValues  int // limit on the number of values, zero means unlimited
+	valueCount int // number of values parsed so far
+	maxMembers int // limit on the number of members per object, zero means unlimited
+	maxDepth   int // limit on the nesting depth, zero means unlimited
+	depth      int // current nesting depth
+	objCap     int // initial map capacity, see ObjectCapacityHint
+	arrCap     int // initial slice capacity, see ArrayCapacityHint
+
+	keys map[string]string // interned object keys, see ScannerFlagInternKeys
+
+	convertNumber NumberConverter     // optional number conversion, see NumberConverter
+	onEscapedKey  EscapedKeyHandler   // optional escaped key handler, see EscapedKeyHandler
+	onLossyInt    LossyIntegerHandler // optional lossy integer handler, see LossyIntegerHandler
+	transformKey  KeyTransform        // optional key transformation, see KeyTransform
+	rejectDupKeys bool                // fail on duplicate keys, see RejectDuplicateKeys
+	dupKeysAsArr  bool                // accumulate duplicate keys, see DuplicateKeysAsArray
+	emptyAsNull   bool                // read empty data as null, see EmptyAsNull
+	onComment     CommentHandler      // optional comment handler, see CommentHandler
+	commentOff    int                 // offset of the last reported comment
+	commentLine   int                 // number of line breaks before commentOff
+
+	stats ScanStats // collected with ScannerFlagCollectStats
 }
 
 // NewScanner creates a new scanner and skips the BOM and optional whitespace at
 // the start of the data
 func NewScanner(data []byte, opts ...any) *Scanner {
-	s := &Scanner{data: data}
+	s := &Scanner{data: data, maxDepth: DefaultMaxDepth}
 	for _, opt := range opts {
 		switch v := opt.(type) {
 		case ScannerFlag:
 			s.flags |= v
+		case ScannerMaxValues:
+			s.maxValues = int(v)
+		case ScannerMaxObjectMembers:
+			s.maxMembers = int(v)
+		case ObjectCapacityHint:
+			s.objCap = int(v)
+		case ArrayCapacityHint:
+			s.arrCap = int(v)
+		case ScannerMaxDepth:
+			s.maxDepth = int(v)
+			if s.maxDepth < 0 {
+				s.maxDepth = 0
+			}
+		case NumberConverter:
+			s.convertNumber = v
+		case func(string) (any, error):
+			s.convertNumber = v
+		case EscapedKeyHandler:
+			s.onEscapedKey = v
+		case func(string) error:
+			s.onEscapedKey = v
+		case LossyIntegerHandler:
+			s.onLossyInt = v
+		case KeyTransform:
+			s.transformKey = v
+		case func(string) string:
+			s.transformKey = v
+		case RejectDuplicateKeys:
+			s.rejectDupKeys = true
+		case DuplicateKeysAsArray:
+			s.dupKeysAsArr = true
+		case EmptyAsNull:
+			s.emptyAsNull = true
+		case CommentHandler:
+			s.onComment = v
+		case func(string, int):
+			s.onComment = v
 		default:
 			panic(fmt.Sprintf("jsn: unsupported scanner option type: %T", v))
 		}
@@ -47,21 +358,166 @@ func NewScanner(data []byte, opts ...any) *Scanner {
 	if s.flags&ScannerFlagDoNotSkipInitialWhitespace == 0 {
 		s.skipWhitespace()
 	}
+	if s.flags&ScannerFlagRequireFinalize != 0 {
+		runtime.SetFinalizer(s, func(s *Scanner) {
+			log.Printf("jsn: scanner garbage collected without Finalize or Close, at offset %d of %d", s.cur, len(s.data))
+		})
+	}
 	return s
 }
 
+// NewScannerString creates a new scanner over a string without copying it, see
+// NewScanner.
+//
+// The scanner reads the string memory directly through a read-only view. This
+// is safe because the scanner never modifies its data and all strings it
+// returns are copies, but any byte slices that alias the scanner data must not
+// be modified either.
+func NewScannerString(str string, opts ...any) *Scanner {
+	var data []byte
+	if len(str) > 0 {
+		data = unsafe.Slice(unsafe.StringData(str), len(str))
+	}
+	return NewScanner(data, opts...)
+}
+
+// Offset returns the current byte offset of the scanner within its data. When a
+// read fails, the offset points at the location where the error was detected,
+// e.g. at an invalid control character inside a string.
+func (s *Scanner) Offset() int {
+	return s.cur
+}
+
+// MaxDepthError is returned when arrays and objects nest deeper than allowed
+// by ScannerMaxDepth. It wraps ErrMaxDepthExceeded, so errors.Is matches it.
+type MaxDepthError struct {
+	Limit int    // The configured limit
+	Path  string // JSON Pointer to the array or object beyond the limit
+}
+
+func (e *MaxDepthError) Error() string {
+	return fmt.Sprintf("maximum nesting depth of %d exceeded at %q", e.Limit, e.Path)
+}
+
+func (e *MaxDepthError) Unwrap() error {
+	return ErrMaxDepthExceeded
+}
+
+// prependPath prefixes the path of a *MaxDepthError with the path of the
+// container it was found in, other errors are returned unchanged
+func prependPath(err error, prefix string) error {
+	if e, ok := err.(*MaxDepthError); ok {
+		e.Path = prefix + e.Path
+	}
+	return err
+}
+
+// MemberError is returned by ReadObjectCallback for errors returned by the
+// callback when the scanner is created with ScannerFlagWrapCallbackErrors.
+type MemberError struct {
+	Key    string // The key of the member passed to the callback
+	Offset int    // Byte offset of the member's key within the scanner data
+	Line   int    // 1-based line of the member's key
+	Column int    // 1-based column of the member's key, counted in bytes
+	Err    error  // The error returned by the callback
+}
+
+func (e *MemberError) Error() string {
+	return fmt.Sprintf("member %q at offset %d: %v", e.Key, e.Offset, e.Err)
+}
+
+func (e *MemberError) Unwrap() error {
+	return e.Err
+}
+
+// position returns the 1-based line and column of the given byte offset
+func (s *Scanner) position(offset int) (line, column int) {
+	head := s.data[:offset]
+	line = 1 + bytes.Count(head, []byte{'\n'})
+	column = offset - bytes.LastIndexByte(head, '\n')
+	return line, column
+}
+
+// Remaining returns the data that has not been consumed yet, e.g. a binary
+// payload that follows a JSON header. Reading a value stops right after it, so
+// any whitespace or delimiter that separates the payload is part of the
+// remainder. The result aliases the scanner's data, it must not be modified
+// while the scanner is still in use.
+func (s *Scanner) Remaining() []byte {
+	return s.data[s.cur:]
+}
+
+// NewScannerFromReader reads all data from r and creates a new scanner over it,
+// see NewScanner for the options. ReaderMaxLength is accepted in addition to
+// the scanner options.
+//
+// Gzip-compressed input is detected by its magic bytes (1f 8b) and
+// decompressed transparently. Other compression formats such as zlib or raw
+// deflate are not detected; wrap r with the corresponding decompressor instead.
+func NewScannerFromReader(r io.Reader, opts ...any) (*Scanner, error) {
+	maxLength, opts := readerOptions(opts)
+	rc, err := uncompressed(r)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	r = rc
+	if maxLength > 0 {
+		r = io.LimitReader(r, int64(maxLength)+1)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if maxLength > 0 && len(data) > maxLength {
+		return nil, ErrInputTooLarge
+	}
+	return NewScanner(data, opts...), nil
+}
+
+// readerOptions separates ReaderMaxLength from the options of NewScanner
+func readerOptions(opts []any) (maxLength int, rest []any) {
+	for i, opt := range opts {
+		if v, ok := opt.(ReaderMaxLength); ok {
+			// the remaining options are passed to NewScanner
+			return int(v), append(append([]any(nil), opts[:i]...), opts[i+1:]...)
+		}
+	}
+	return 0, opts
+}
+
+// uncompressed returns a reader for the data of r, decompressing it if it is
+// gzip-compressed
+func uncompressed(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return io.NopCloser(br), nil
+}
+
 // IsEOF returns true if the scanner has reached the end of input
 func (s *Scanner) IsEOF() bool {
 	return s.cur >= len(s.data)
 }
 
-// SkipBOM skips the UTF-8 Byte Order Mark (BOM) if present at the start of the data
+// WasEmpty reports whether the scanner was created over zero-length data. The
+// reading functions fail with ErrUnexpectedEOF both for empty data and for data
+// holding nothing but whitespace, WasEmpty tells them apart, e.g. so that an
+// HTTP handler can report a missing request body specifically:
+//
+//	v, err := jsn.ReadValue(s)
+//	if err == jsn.ErrUnexpectedEOF && s.WasEmpty() {
+//	    // no body was sent
+//	}
+func (s *Scanner) WasEmpty() bool {
+	return len(s.data) == 0
+}
+
+// SkipBOM skips the UTF-8 Byte Order Mark (BOM) if present at the current
+// position, which is the start of the data unless something was read already
 func (s *Scanner) SkipBOM() bool {
-	// UTF-8 BOM is bytes: 0xEF, 0xBB, 0xBF
-	if len(s.data) >= 3 &&
-		s.data[0] == 0xEF &&
-		s.data[1] == 0xBB &&
-		s.data[2] == 0xBF {
+	if s.atBOM() {
 		s.cur += 3
 		return true
 	}
@@ -70,22 +526,190 @@ func (s *Scanner) SkipBOM() bool {
 
 // Finalize ensures that the scanner has consumed all input
 func (s *Scanner) Finalize() error {
+	s.done()
 	s.skipWhitespace()
 	if !s.IsEOF() {
-		return ErrUnexpectedToken
+		return s.unexpectedToken()
 	}
 	return nil
 }
 
-func (s *Scanner) next() byte {
-	if s.cur >= len(s.data) {
-		return 0
+// Close marks the scanner as done without checking for trailing data, e.g.
+// when a payload that follows the JSON is handled separately (see Remaining).
+// It only matters with ScannerFlagRequireFinalize and always returns nil.
+func (s *Scanner) Close() error {
+	s.done()
+	return nil
+}
+
+// done removes the finalizer set up for ScannerFlagRequireFinalize
+func (s *Scanner) done() {
+	if s.flags&ScannerFlagRequireFinalize != 0 {
+		runtime.SetFinalizer(s, nil)
 	}
-	c := s.data[s.cur]
-	s.cur++
-	return c
 }
 
+// SkipWhitespace skips whitespace at the current position (BOMs included with
+// ScannerFlagTolerateBOM) and reports whether there was any. Together with
+// SkipBOM and ReadValueExact, it lets parsers that manage whitespace
+// themselves decide at read time what the construction flags
+// ScannerFlagDoNotSkipBOM and ScannerFlagDoNotSkipInitialWhitespace decide
+// for the start of the data.
+func (s *Scanner) SkipWhitespace() bool {
+	start := s.cur
+	s.skipWhitespace()
+	return s.cur != start
+}
+
+// ExpectDelim skips whitespace and consumes the delimiter b (e.g. ':' or ','),
+// it fails with ErrUnexpectedEOF at the end of input and with
+// ErrUnexpectedToken (ErrUnexpectedBOM for a BOM) if another byte follows.
+// This is the delimiter handling used by the reading functions, exported for
+// hand-written parsers.
+func (s *Scanner) ExpectDelim(b byte) error {
+	s.skipWhitespace()
+	if !s.skipByte(b) {
+		return s.unexpectedToken()
+	}
+	return nil
+}
+
+// TryDelim skips whitespace and consumes the delimiter b if it follows,
+// reporting whether it did
+func (s *Scanner) TryDelim(b byte) bool {
+	s.skipWhitespace()
+	return s.skipByte(b)
+}
+
+// AtValueStart skips whitespace and reports whether the next byte can start a
+// value, without consuming it, so that hand-written parsers can check their
+// position before calling ReadValue. It is PeekKind without the kind, the
+// value itself is not validated.
+func (s *Scanner) AtValueStart() bool {
+	_, err := PeekKind(s)
+	return err == nil
+}
+
+// Stats returns the statistics collected so far, they are only collected when
+// the scanner is created with ScannerFlagCollectStats
+func (s *Scanner) Stats() ScanStats {
+	return s.stats
+}
+
+// collectStats reports whether ScannerFlagCollectStats is set
+func (s *Scanner) collectStats() bool {
+	return s.flags&ScannerFlagCollectStats != 0
+}
+
+// unexpectedToken returns the error for an unexpected byte at the current
+// position. A UTF-8 byte order mark past the start of the data (e.g. between
+// concatenated documents) is reported as ErrUnexpectedBOM, so that it is not
+// mistaken for garbage, see ScannerFlagTolerateBOM. Running out of data where
+// a token is expected is reported as ErrUnexpectedEOF.
+func (s *Scanner) unexpectedToken() error {
+	if s.IsEOF() {
+		return ErrUnexpectedEOF
+	}
+	if s.atBOM() {
+		return ErrUnexpectedBOM
+	}
+	return ErrUnexpectedToken
+}
+
+// countValue accounts for a value that is about to be parsed
+func (s *Scanner) countValue() error {
+	s.valueCount++
+	if s.collectStats() {
+		s.stats.Values++
+	}
+	if s.maxValues > 0 && s.valueCount > s.maxValues {
+		return ErrValueLimitExceeded
+	}
+	return nil
+}
+
+// enterContainer accounts for an array or object that is about to be parsed,
+// the matching closing bracket must be followed by leaveContainer
+func (s *Scanner) enterContainer() error {
+	s.depth++
+	if s.depth > s.stats.MaxDepth && s.collectStats() {
+		s.stats.MaxDepth = s.depth
+	}
+	if s.maxDepth > 0 && s.depth > s.maxDepth {
+		return &MaxDepthError{Limit: s.maxDepth}
+	}
+	return nil
+}
+
+func (s *Scanner) leaveContainer() {
+	s.depth--
+}
+
+// newObject allocates a map for an object being read
+func (s *Scanner) newObject() map[string]any {
+	if s.objCap > 0 {
+		return make(map[string]any, s.objCap)
+	}
+	return make(map[string]any)
+}
+
+// newArray allocates a slice for a non-empty array being read, the cursor is
+// at its first element or the separator that follows it
+func (s *Scanner) newArray() []any {
+	n := s.arrCap
+	if s.flags&ScannerFlagPreallocateArrays != 0 {
+		if c := s.countElements(); c > n {
+			n = c
+		}
+	}
+	if n <= 0 {
+		return nil
+	}
+	return make([]any, 0, n)
+}
+
+// countElements counts the elements of the array being read by counting the
+// separators that follow the cursor at the array's level, without validating
+// anything. The count is bounded by ScannerMaxValues, if set.
+func (s *Scanner) countElements() int {
+	n, depth := 1, 0
+	for i := s.cur; i < len(s.data); i++ {
+		switch s.data[i] {
+		case '"':
+			for i++; i < len(s.data) && s.data[i] != '"'; i++ {
+				if s.data[i] == '\\' {
+					i++
+				}
+			}
+		case '[', '{':
+			depth++
+		case ']', '}':
+			if depth == 0 {
+				return n
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				n++
+				if s.maxValues > 0 && n >= s.maxValues {
+					return n
+				}
+			}
+		}
+	}
+	return n
+}
+
+// checkMembers verifies the number of members read so far in an object
+func (s *Scanner) checkMembers(n int) error {
+	if s.maxMembers > 0 && n > s.maxMembers {
+		return ErrTooManyMembers
+	}
+	return nil
+}
+
+// peek returns the current byte without consuming it. It returns 0 at the end
+// of input, callers that need to tell EOF from a NUL byte must check IsEOF.
 func (s *Scanner) peek() byte {
 	if s.cur >= len(s.data) {
 		return 0
@@ -104,16 +728,80 @@ func (s *Scanner) skipByte(b byte) bool {
 	return false
 }
 
+// isWhitespace flags the bytes that are whitespace in strict JSON: space, tab,
+// CR and LF
+var isWhitespace = [256]bool{' ': true, '\t': true, '\n': true, '\r': true}
+
 func (s *Scanner) skipWhitespace() {
-	for s.cur < len(s.data) {
-		c := s.data[s.cur]
-		// In strict JSON, only space, tab, CR, and LF are allowed as whitespace
-		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
-			s.cur++
+	// the loop works on locals, which keeps the cursor in a register
+	data, cur := s.data, s.cur
+	for cur < len(data) {
+		c := data[cur]
+		if isWhitespace[c] {
+			cur++
 			continue
 		}
-		return
+		if c == 0xEF && s.flags&ScannerFlagTolerateBOM != 0 {
+			s.cur = cur
+			if s.atBOM() {
+				cur += 3
+				continue
+			}
+		}
+		if c == '/' && s.flags&ScannerFlagAllowComments != 0 {
+			s.cur = cur
+			if s.skipComment() {
+				cur = s.cur
+				continue
+			}
+		}
+		break
+	}
+	s.cur = cur
+}
+
+// skipComment skips the comment at the current position, if any, and reports
+// it to the CommentHandler. An unterminated block comment is not skipped.
+func (s *Scanner) skipComment() bool {
+	rest := s.data[s.cur:]
+	if len(rest) < 2 {
+		return false
+	}
+	n, text := 0, []byte(nil)
+	switch rest[1] {
+	case '/':
+		n = bytes.IndexByte(rest, '\n')
+		if n < 0 {
+			n = len(rest)
+		}
+		text = bytes.TrimSuffix(rest[:n], []byte{'\r'})
+	case '*':
+		end := bytes.Index(rest[2:], []byte("*/"))
+		if end < 0 {
+			return false
+		}
+		n = end + 4
+		text = rest[:n]
+	default:
+		return false
+	}
+	if s.onComment != nil {
+		// lines are counted on from the previous comment, which keeps
+		// reporting linear in the size of the data
+		if s.cur < s.commentOff {
+			s.commentOff, s.commentLine = 0, 0
+		}
+		s.commentLine += bytes.Count(s.data[s.commentOff:s.cur], []byte{'\n'})
+		s.commentOff = s.cur
+		s.onComment(string(text), s.commentLine+1)
 	}
+	s.cur += n
+	return true
+}
+
+// atBOM reports whether a UTF-8 byte order mark starts at the current position
+func (s *Scanner) atBOM() bool {
+	return len(s.data)-s.cur >= 3 && s.data[s.cur] == 0xEF && s.data[s.cur+1] == 0xBB && s.data[s.cur+2] == 0xBF
 }
 
 func (s *Scanner) isDecimalDigit() bool {
@@ -141,9 +829,151 @@ func (s *Scanner) skipSequence(seq []byte) bool {
 	return true
 }
 
+// parseKey parses an object key and applies KeyTransform. With
+// ScannerFlagInternKeys, keys that were seen before are returned from the
+// intern table, keys without escapes are looked up directly in the source data
+// to avoid allocating.
+func (s *Scanner) parseKey() (string, error) {
+	if s.collectStats() {
+		s.stats.Keys++
+	}
+	intern := s.flags&ScannerFlagInternKeys != 0
+	if !intern && s.onEscapedKey == nil && s.transformKey == nil {
+		return s.parseString()
+	}
+	if intern && s.peek() == '"' {
+		start := s.cur + 1
+		for end := start; end < len(s.data); end++ {
+			c := s.data[end]
+			if c == '"' {
+				if k, ok := s.keys[string(s.data[start:end])]; ok {
+					if s.collectStats() {
+						s.stats.StringBytes += end - start
+					}
+					s.cur = end + 1
+					return k, nil
+				}
+				break
+			}
+			if c <= 0x1F || c == '\\' {
+				break
+			}
+		}
+	}
+	k, escaped, err := s.parseStringEscaped()
+	if err != nil {
+		return "", err
+	}
+	if escaped && s.onEscapedKey != nil {
+		if err = s.onEscapedKey(k); err != nil {
+			return "", err
+		}
+	}
+	if !intern {
+		if s.transformKey != nil {
+			k = s.transformKey(k)
+		}
+		return k, nil
+	}
+	// the table maps decoded keys to their transformed form
+	if ik, ok := s.keys[k]; ok {
+		return ik, nil
+	}
+	ik := k
+	if s.transformKey != nil {
+		ik = s.transformKey(k)
+	}
+	if len(s.keys) < maxInternedKeys {
+		if s.keys == nil {
+			s.keys = make(map[string]string)
+		}
+		s.keys[k] = ik
+	}
+	return ik, nil
+}
+
+// storeMember stores an object member read into obj. With DuplicateKeysAsArray,
+// repeated keys accumulate their values in a []any, multi records the keys that
+// already hold such an accumulation and is allocated on first use.
+func (s *Scanner) storeMember(obj map[string]any, multi *map[string]bool, key string, v any) {
+	if s.dupKeysAsArr {
+		if prev, dup := obj[key]; dup {
+			if *multi == nil {
+				*multi = make(map[string]bool)
+			}
+			if (*multi)[key] {
+				obj[key] = append(prev.([]any), v)
+			} else {
+				obj[key] = []any{prev, v}
+				(*multi)[key] = true
+			}
+			return
+		}
+	}
+	obj[key] = v
+}
+
+// seenKey records a key of the object being read in seen, which is allocated
+// on first use. With RejectDuplicateKeys, keys that were already recorded fail
+// with ErrDuplicateKey.
+func (s *Scanner) seenKey(seen *map[string]bool, key string) error {
+	if !s.rejectDupKeys {
+		return nil
+	}
+	if *seen == nil {
+		*seen = make(map[string]bool)
+	}
+	if (*seen)[key] {
+		return ErrDuplicateKey
+	}
+	(*seen)[key] = true
+	return nil
+}
+
 func (s *Scanner) parseString() (string, error) {
+	str, _, err := s.parseStringEscaped()
+	return str, err
+}
+
+// skipString validates the string at the current position like parseString.
+// A string without escapes is returned as a slice of the data, so that nothing
+// is allocated, other strings are decoded.
+func (s *Scanner) skipString() ([]byte, error) {
+	if s.peek() == '"' {
+		start := s.cur + 1
+		for end := start; end < len(s.data); end++ {
+			c := s.data[end]
+			if c == '"' {
+				if s.flags&ScannerFlagStrictUTF8 != 0 && !utf8.Valid(s.data[start:end]) {
+					break
+				}
+				if s.flags&ScannerFlagRejectJSUnsafe != 0 && jsUnsafeIndex(s.data[start:end]) >= 0 {
+					break
+				}
+				if s.collectStats() {
+					s.stats.StringBytes += end - start
+				}
+				s.cur = end + 1
+				return s.data[start:end:end], nil
+			}
+			if c <= 0x1F || c == '\\' {
+				break
+			}
+		}
+	}
+	// escapes and errors, which leave the cursor at the offending byte
+	str, err := s.parseString()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(str), nil
+}
+
+// parseStringEscaped parses a string and also reports whether it contained
+// escape sequences
+func (s *Scanner) parseStringEscaped() (string, bool, error) {
 	if s.peek() != '"' {
-		return "", ErrUnexpectedToken
+		return "", false, ErrUnexpectedToken
 	}
 	s.cur++
 
@@ -154,44 +984,59 @@ func (s *Scanner) parseString() (string, error) {
 	for s.cur < len(s.data) {
 		c := s.data[s.cur]
 		if c <= 0x1F {
-			return "", ErrInvalidString
+			return "", false, ErrInvalidString
 		}
 		if c == '\\' {
 			escaped = true
 			break
 		}
 		if c == '"' {
+			if s.flags&ScannerFlagStrictUTF8 != 0 && !utf8.Valid(s.data[start:s.cur]) {
+				return "", false, ErrInvalidString
+			}
+			if s.flags&ScannerFlagRejectJSUnsafe != 0 {
+				if i := jsUnsafeIndex(s.data[start:s.cur]); i >= 0 {
+					s.cur = start + i
+					return "", false, ErrJSUnsafeString
+				}
+			}
 			// notice that this always creates a new string and copies the data,
 			// while this is not the fastest approach, it also has benefits  avoids holding references to the original data.
 			result := string(s.data[start:s.cur])
 			s.cur++
-			return result, nil
+			if s.collectStats() {
+				s.stats.StringBytes += len(result)
+			}
+			return result, false, nil
 		}
 		s.cur++
 	}
 
-	// If we get here without finding a closing quote
+	// the data ends inside the string
 	if !escaped {
-		return "", ErrInvalidString
+		return "", false, ErrUnexpectedEOF
 	}
 
 	// Slow path for escaped strings
 	s.cur = start
 	var buf []byte
 	for {
-		c := s.next()
-		if c == 0 {
-			return "", ErrInvalidString
+		// an unterminated string is truncated input, a raw control character
+		// (including NUL) is invalid
+		if s.IsEOF() {
+			return "", false, ErrUnexpectedEOF
 		}
+		c := s.data[s.cur]
 		if c <= 0x1F {
-			return "", ErrInvalidString
+			return "", false, ErrInvalidString
 		}
+		s.cur++
 		if c == '"' {
 			break
 		}
 		if c == '\\' {
 			if s.cur >= len(s.data) {
-				return "", ErrInvalidString
+				return "", false, ErrUnexpectedEOF
 			}
 			c = s.peek()
 			switch c {
@@ -211,65 +1056,139 @@ func (s *Scanner) parseString() (string, error) {
 				case 't':
 					buf = append(buf, '\t')
 				case 'u':
-					r, err := s.parseUnicode()
+					r, err := s.parseUnicodeEscape()
 					if err != nil {
-						return "", err
+						return "", false, err
 					}
-					buf = append(buf, string(r)...)
+					buf = utf8.AppendRune(buf, r)
 				}
 			default:
-				return "", ErrInvalidString
+				return "", false, ErrInvalidString
 			}
 		} else {
+			if c == 0xE2 && s.flags&ScannerFlagRejectJSUnsafe != 0 && s.cur+1 < len(s.data) &&
+				s.data[s.cur] == 0x80 && s.data[s.cur+1]|1 == 0xA9 {
+				s.cur--
+				return "", false, ErrJSUnsafeString
+			}
 			buf = append(buf, c)
 		}
 	}
-	return string(buf), nil
+	if s.flags&ScannerFlagStrictUTF8 != 0 && !utf8.Valid(buf) {
+		return "", false, ErrInvalidString
+	}
+	if s.collectStats() {
+		s.stats.StringBytes += len(buf)
+	}
+	return string(buf), true, nil
+}
+
+// jsUnsafeIndex returns the index of the first U+2028 or U+2029 in the UTF-8
+// text b, or -1, see ScannerFlagRejectJSUnsafe
+func jsUnsafeIndex(b []byte) int {
+	for i := 0; ; i++ {
+		j := bytes.IndexByte(b[i:], 0xE2)
+		if j < 0 {
+			return -1
+		}
+		i += j
+		if i+2 < len(b) && b[i+1] == 0x80 && (b[i+2] == 0xA8 || b[i+2] == 0xA9) {
+			return i
+		}
+	}
+}
+
+// parseUnicodeEscape parses the hex digits of a \u escape, combining a high
+// surrogate with the \u escaped low surrogate that follows it. Lone surrogates
+// become U+FFFD, or fail with ScannerFlagStrictSurrogates.
+func (s *Scanner) parseUnicodeEscape() (rune, error) {
+	r, err := s.parseUnicode()
+	if err != nil || !utf16.IsSurrogate(r) {
+		return r, err
+	}
+	if r < 0xDC00 && len(s.data)-s.cur >= 6 && s.data[s.cur] == '\\' && s.data[s.cur+1] == 'u' {
+		save := s.cur
+		s.cur += 2
+		r2, err := s.parseUnicode()
+		if err != nil {
+			return 0, err
+		}
+		if c := utf16.DecodeRune(r, r2); c != utf8.RuneError {
+			return c, nil
+		}
+		// not a low surrogate, it is parsed as an escape of its own
+		s.cur = save
+	}
+	if s.flags&ScannerFlagStrictSurrogates != 0 {
+		return 0, ErrInvalidUnicodeEscape
+	}
+	return utf8.RuneError, nil
 }
 
 func (s *Scanner) parseUnicode() (rune, error) {
 	if len(s.data) < s.cur+4 {
+		if isHex(s.data[s.cur:]) {
+			// the data ends inside the escape
+			s.cur = len(s.data)
+			return 0, ErrUnexpectedEOF
+		}
 		return 0, ErrInvalidUnicodeEscape
 	}
 
 	hex := string(s.data[s.cur : s.cur+4])
-	s.cur += 4
-
 	v, err := strconv.ParseUint(hex, 16, 16)
 	if err != nil {
 		return 0, ErrInvalidUnicodeEscape
 	}
+	s.cur += 4
 
 	return rune(v), nil
 }
 
-func (s *Scanner) parseNumber() (float64, error) {
+// scanNumber validates the syntax of a JSON number at the current position and
+// returns its raw token, which aliases the scanner's data. Hexadecimal numbers
+// are returned as a decimal token instead, see ScannerFlagAllowHexNumbers.
+func (s *Scanner) scanNumber() ([]byte, error) {
 	start := s.cur
+	json5 := s.flags&ScannerFlagAllowJSON5Numbers != 0
 
-	// Optional minus
-	s.skipByte('-')
+	// Optional sign
+	if !json5 || !s.skipByte('+') {
+		s.skipByte('-')
+	}
+
+	if s.flags&ScannerFlagAllowHexNumbers != 0 && s.cur+1 < len(s.data) &&
+		s.data[s.cur] == '0' && (s.data[s.cur+1] == 'x' || s.data[s.cur+1] == 'X') {
+		return s.scanHexNumber(start)
+	}
 
 	// Integer part
+	intDigits := true
 	if s.skipByte('0') {
 		if s.isDecimalDigit() {
-			return 0, ErrInvalidNumber
+			if s.flags&ScannerFlagAllowLeadingZeros == 0 {
+				return nil, ErrInvalidNumber
+			}
+			s.skipDecimalDigits()
 		}
+	} else if json5 && s.peek() == '.' {
+		intDigits = false
 	} else {
 		if s.cur >= len(s.data) || s.data[s.cur] < '1' || s.data[s.cur] > '9' {
-			return 0, ErrInvalidNumber
+			return nil, ErrInvalidNumber
 		}
 		s.cur++
 		s.skipDecimalDigits()
 	}
 
-	// Fractional part
+	// Fractional part, JSON5 needs digits on one side of the point only
 	if s.skipByte('.') {
-		if !s.skipDecimalDigits() {
-			return 0, ErrInvalidNumber
+		if !s.skipDecimalDigits() && !(json5 && intDigits) {
+			return nil, ErrInvalidNumber
 		}
 		// After a valid decimal part, another dot is an error
 		if s.skipByte('.') {
-			return 0, ErrInvalidNumber
+			return nil, ErrInvalidNumber
 		}
 	}
 
@@ -279,22 +1198,199 @@ func (s *Scanner) parseNumber() (float64, error) {
 			s.skipByte('-')
 		}
 		if !s.skipDecimalDigits() {
-			return 0, ErrInvalidNumber
+			return nil, ErrInvalidNumber
 		}
 		// After a valid exponent, another exponent is an error
 		if s.skipByte('e') || s.skipByte('E') {
-			return 0, ErrInvalidNumber
+			return nil, ErrInvalidNumber
 		}
 	}
 
-	num := string(s.data[start:s.cur])
-	val, err := strconv.ParseFloat(num, 64)
+	// Anything but a value terminator continues the token, e.g. -1x is an
+	// invalid number with the offset at the x, not a number and a stray x
+	if !s.atNumberEnd() {
+		return nil, ErrInvalidNumber
+	}
+
+	if json5 {
+		return json5NumberToken(s.data[start:s.cur]), nil
+	}
+	return s.data[start:s.cur], nil
+}
+
+// json5NumberToken rewrites a decimal number in JSON5 form as a JSON number,
+// dropping a leading plus and adding the zeros missing around the decimal
+// point. Tokens that are valid JSON are returned as is.
+func json5NumberToken(token []byte) []byte {
+	if token[0] == '+' {
+		token = token[1:]
+	}
+	dot := bytes.IndexByte(token, '.')
+	if dot < 0 {
+		return token
+	}
+	lead := dot == 0 || token[dot-1] == '-'
+	trail := dot+1 == len(token) || token[dot+1] < '0' || token[dot+1] > '9'
+	if !lead && !trail {
+		return token
+	}
+	out := make([]byte, 0, len(token)+1)
+	out = append(out, token[:dot]...)
+	if lead {
+		out = append(out, '0')
+	}
+	out = append(out, '.')
+	if trail {
+		out = append(out, '0')
+	}
+	return append(out, token[dot+1:]...)
+}
+
+// scanHexNumber scans the digits of a hexadecimal number whose sign starts at
+// start and returns the number as a decimal token
+func (s *Scanner) scanHexNumber(start int) ([]byte, error) {
+	s.cur += 2 // 0x
+	digits := s.cur
+	for s.cur < len(s.data) && isHex(s.data[s.cur:s.cur+1]) {
+		s.cur++
+	}
+	if s.cur == digits || !s.atNumberEnd() {
+		return nil, ErrInvalidNumber
+	}
+	var v big.Int
+	v.SetString(string(s.data[digits:s.cur]), 16)
+	token := v.Append(nil, 10)
+	if s.data[start] == '-' {
+		token = append([]byte{'-'}, token...)
+	}
+	return token, nil
+}
+
+// atNumberEnd reports whether the cursor is at a byte that can follow a
+// number: whitespace, a delimiter, a comment or the end of data. A BOM is
+// accepted as well, it is reported by the caller (see ScannerFlagTolerateBOM).
+func (s *Scanner) atNumberEnd() bool {
+	if s.cur >= len(s.data) {
+		return true
+	}
+	switch c := s.data[s.cur]; c {
+	case ',', ']', '}':
+		return true
+	case '/':
+		return s.flags&ScannerFlagAllowComments != 0
+	default:
+		return isWhitespace[c] || s.atBOM()
+	}
+}
+
+func (s *Scanner) parseNumber() (float64, error) {
+	token, err := s.scanNumber()
+	if err != nil {
+		return 0, err
+	}
+
+	val, err := strconv.ParseFloat(string(token), 64)
 	if err != nil {
 		if numError := err.(*strconv.NumError); numError.Err == strconv.ErrRange {
 			return 0, ErrNumericValueOutOfRange
 		}
 		return 0, ErrInvalidNumber
 	}
+	if val == 0 && s.flags&ScannerFlagStrictUnderflow != 0 && hasNonZeroMantissa(token) {
+		return 0, ErrNumericUnderflow
+	}
+	if s.onLossyInt != nil && isLossyInteger(token) {
+		if err = s.onLossyInt(string(token)); err != nil {
+			return 0, err
+		}
+	}
 
 	return val, nil
 }
+
+// isLossyInteger reports whether a number token is an integer with a magnitude
+// above 2^53, beyond which float64 cannot represent all integers
+func isLossyInteger(token []byte) bool {
+	const maxExact = "9007199254740992" // 2^53
+	if len(token) > 0 && token[0] == '-' {
+		token = token[1:]
+	}
+	for len(token) > 1 && token[0] == '0' {
+		token = token[1:] // leading zeros, see ScannerFlagAllowLeadingZeros
+	}
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	if len(token) != len(maxExact) {
+		return len(token) > len(maxExact)
+	}
+	return string(token) > maxExact
+}
+
+// readNumber scans a number and returns it as float64, as Number with
+// ScannerFlagUseNumber or ScannerFlagLazyNumbers, or as converted by the
+// NumberConverter option
+func (s *Scanner) readNumber() (any, error) {
+	if s.convertNumber == nil && s.flags&(ScannerFlagUseNumber|ScannerFlagLazyNumbers) == 0 {
+		return s.parseNumber()
+	}
+	token, err := s.scanNumber()
+	if err != nil {
+		return nil, err
+	}
+	if s.flags&ScannerFlagAllowLeadingZeros != 0 {
+		token = trimLeadingZeros(token)
+	}
+	if s.convertNumber != nil {
+		return s.convertNumber(string(token))
+	}
+	return Number(token), nil
+}
+
+// hasNonZeroMantissa reports whether a number token has a non-zero digit
+// before its exponent
+func hasNonZeroMantissa(token []byte) bool {
+	for _, c := range token {
+		if c == 'e' || c == 'E' {
+			break
+		}
+		if c >= '1' && c <= '9' {
+			return true
+		}
+	}
+	return false
+}
+
+// scanInteger scans a JSON number that is expected to be an integer and
+// returns its token; numbers with a fraction or an exponent are rejected
+func (s *Scanner) scanInteger() ([]byte, error) {
+	s.skipWhitespace()
+	switch s.peek() {
+	case '+', '.':
+		if s.flags&ScannerFlagAllowJSON5Numbers == 0 {
+			return nil, s.unexpectedToken()
+		}
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+	default:
+		if s.IsEOF() {
+			return nil, ErrUnexpectedEOF
+		}
+		return nil, s.unexpectedToken()
+	}
+
+	token, err := s.scanNumber()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range token {
+		if c == '.' || c == 'e' || c == 'E' {
+			return nil, ErrNumberNotInteger
+		}
+	}
+	if s.flags&ScannerFlagAllowLeadingZeros != 0 {
+		token = trimLeadingZeros(token)
+	}
+	return token, nil
+}
@@ -1,8 +1,20 @@
 package jsn
 
 import (
+	"bytes"
+	"compress/gzip"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestScanner_Basic(t *testing.T) {
@@ -12,8 +24,8 @@ func TestScanner_Basic(t *testing.T) {
 		t.Errorf("Expected 'h', got %c", s.peek())
 	}
 
-	if s.next() != 'h' {
-		t.Errorf("Expected 'h', got %c", s.next())
+	if !s.skipByte('h') {
+		t.Errorf("Expected to skip 'h'")
 	}
 
 	if s.cur != 1 {
@@ -62,11 +74,11 @@ func TestScanner_ParseString(t *testing.T) {
 		{name: "text with escaped null", input: `"hello\u0000world"`, want: "hello\u0000world"},
 
 		// Error cases
-		{name: "unterminated string", input: `"hello`, wantErr: ErrInvalidString},
+		{name: "unterminated string", input: `"hello`, wantErr: ErrUnexpectedEOF},
 		{name: "invalid escape", input: `"\k"`, wantErr: ErrInvalidString},
 		{name: "incomplete unicode", input: `"\u123"`, wantErr: ErrInvalidUnicodeEscape},
 		{name: "invalid unicode", input: `"\uGGGG"`, wantErr: ErrInvalidUnicodeEscape},
-		{name: "bare backslash", input: `"\"`, wantErr: ErrInvalidString},
+		{name: "bare backslash", input: `"\"`, wantErr: ErrUnexpectedEOF},
 		{name: "null after escape", input: "\"\\x00\"", wantErr: ErrInvalidString},
 
 		// Control characters (should be invalid unless escaped)
@@ -162,7 +174,7 @@ func TestScanner_Value(t *testing.T) {
 		{name: "incomplete false", input: "fals", wantErr: ErrUnexpectedToken},
 		{name: "incomplete null", input: "nul", wantErr: ErrUnexpectedToken},
 		{name: "invalid number", input: "01", wantErr: ErrInvalidNumber},
-		{name: "unterminated string", input: `"hello`, wantErr: ErrInvalidString},
+		{name: "unterminated string", input: `"hello`, wantErr: ErrUnexpectedEOF},
 		{name: "unterminated array", input: "[1, 2", wantErr: ErrUnexpectedEOF},
 		{name: "unterminated object", input: `{"key": "value"`, wantErr: ErrUnexpectedEOF},
 	}
@@ -182,32 +194,87 @@ func TestScanner_Value(t *testing.T) {
 	}
 }
 
+func TestScannerWasEmpty(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{input: "", want: true},
+		{input: "   ", want: false},
+		{input: "\xef\xbb\xbf", want: false},
+		{input: "{}", want: false},
+	}
+
+	for _, tt := range tests {
+		s := NewScannerString(tt.input)
+		SkipValue(s)
+		if got := s.WasEmpty(); got != tt.want {
+			t.Errorf("WasEmpty() for %q = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
 func TestScanner_SkipBOM(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    []byte
+		skip     int
 		wantSkip bool
+		wantCur  int
 	}{
 		{
 			name:     "with BOM",
 			input:    []byte{0xEF, 0xBB, 0xBF, 'h', 'e', 'l', 'l', 'o'},
 			wantSkip: true,
+			wantCur:  3,
 		},
 		{
 			name:     "without BOM",
 			input:    []byte("hello"),
 			wantSkip: false,
 		},
+		{
+			name:     "after the cursor moved",
+			input:    []byte{0xEF, 0xBB, 0xBF, 'h', 'e', 'l', 'l', 'o'},
+			skip:     4,
+			wantSkip: false,
+			wantCur:  4,
+		},
+		{
+			name:     "BOM at the cursor",
+			input:    []byte{'1', ' ', 0xEF, 0xBB, 0xBF, '2'},
+			skip:     2,
+			wantSkip: true,
+			wantCur:  5,
+		},
+		{
+			name:     "truncated BOM",
+			input:    []byte{0xEF, 0xBB},
+			wantSkip: false,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := NewScanner(tt.input)
+			s := NewScanner(tt.input, ScannerFlagDoNotSkipBOM, ScannerFlagDoNotSkipInitialWhitespace)
+			s.cur = tt.skip
 			if got := s.SkipBOM(); got != tt.wantSkip {
 				t.Errorf("SkipBOM() = %v, want %v", got, tt.wantSkip)
 			}
+			if s.cur != tt.wantCur {
+				t.Errorf("SkipBOM() moved the cursor to %d, want %d", s.cur, tt.wantCur)
+			}
 		})
 	}
+
+	// the BOM skipped on construction is not skipped again
+	s := NewScanner([]byte("\xef\xbb\xbf[1]"))
+	if s.SkipBOM() {
+		t.Errorf("SkipBOM() after construction = true, want false")
+	}
+	if v, err := ReadValue(s); err != nil || len(v.([]any)) != 1 {
+		t.Errorf("ReadValue() after SkipBOM() = %v, %v, want [1]", v, err)
+	}
 }
 
 func TestScanner_Flags(t *testing.T) {
@@ -287,7 +354,7 @@ func TestScannerEdgeCases(t *testing.T) {
 		{
 			name:    "invalid unterminated string",
 			input:   `"hello`,
-			wantErr: ErrInvalidString,
+			wantErr: ErrUnexpectedEOF,
 		},
 		{
 			name:    "invalid escape sequence",
@@ -479,16 +546,6 @@ func TestScannerErrorCases(t *testing.T) {
 		testFn  func(*Scanner) error
 		wantErr error
 	}{
-		{
-			name:  "empty input with next",
-			input: "",
-			testFn: func(s *Scanner) error {
-				if s.next() != 0 {
-					return fmt.Errorf("expected 0 for empty input")
-				}
-				return nil
-			},
-		},
 		{
 			name:  "empty input with peek",
 			input: "",
@@ -524,7 +581,7 @@ func TestScannerErrorCases(t *testing.T) {
 				_, err := s.parseString()
 				return err
 			},
-			wantErr: ErrInvalidString,
+			wantErr: ErrUnexpectedEOF,
 		},
 		{
 			name:  "parse string with invalid escape sequence",
@@ -614,3 +671,827 @@ func TestScannerErrorCases(t *testing.T) {
 		})
 	}
 }
+
+func TestScannerFlagStrictUnderflow(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		flags   []any
+		want    float64
+		wantErr error
+	}{
+		{name: "underflow by default", input: "123e-10000000", want: 0},
+		{name: "tiny exponent by default", input: "1e-999", want: 0},
+		{name: "underflow strict", input: "123e-10000000", flags: []any{ScannerFlagStrictUnderflow}, wantErr: ErrNumericUnderflow},
+		{name: "negative underflow strict", input: "-1.5e-400", flags: []any{ScannerFlagStrictUnderflow}, wantErr: ErrNumericUnderflow},
+		{name: "subnormal strict", input: "5e-324", flags: []any{ScannerFlagStrictUnderflow}, want: 5e-324},
+		{name: "zero strict", input: "0", flags: []any{ScannerFlagStrictUnderflow}, want: 0},
+		{name: "zero with exponent strict", input: "0.000e-999", flags: []any{ScannerFlagStrictUnderflow}, want: 0},
+		{name: "negative zero strict", input: "-0.0", flags: []any{ScannerFlagStrictUnderflow}, want: 0},
+		{name: "overflow strict", input: "1e999", flags: []any{ScannerFlagStrictUnderflow}, wantErr: ErrNumericValueOutOfRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner([]byte(tt.input), tt.flags...)
+			got, err := s.parseNumber()
+			if err != tt.wantErr {
+				t.Errorf("parseNumber() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("parseNumber() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScannerFlagAllowLeadingZeros(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    float64
+		wantErr error
+	}{
+		{name: "single leading zero", input: "012", want: 12},
+		{name: "negative leading zero", input: "-01", want: -1},
+		{name: "multiple leading zeros", input: "00123", want: 123},
+		{name: "all zeros", input: "000", want: 0},
+		{name: "with fraction", input: "007.5", want: 7.5},
+		{name: "with exponent", input: "01e2", want: 100},
+		{name: "plain zero", input: "0", want: 0},
+		{name: "trailing dot still invalid", input: "01.", wantErr: ErrInvalidNumber},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner([]byte(tt.input), ScannerFlagAllowLeadingZeros)
+			got, err := s.parseNumber()
+			if err == nil {
+				err = s.Finalize()
+			}
+			if err != tt.wantErr {
+				t.Errorf("parseNumber() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("parseNumber() = %v, want %v", got, tt.want)
+			}
+
+			// without the flag, leading zeros must keep failing
+			if len(tt.input) > 1 && tt.input[0] == '0' || len(tt.input) > 2 && tt.input[:2] == "-0" {
+				s = NewScanner([]byte(tt.input))
+				if _, err = s.parseNumber(); err != ErrInvalidNumber {
+					t.Errorf("parseNumber() without flag error = %v, want %v", err, ErrInvalidNumber)
+				}
+			}
+		})
+	}
+
+	s := NewScanner([]byte("0123"), ScannerFlagAllowLeadingZeros)
+	if got, err := ReadInt64(s); err != nil || got != 123 {
+		t.Errorf("ReadInt64() = %v, %v, want 123", got, err)
+	}
+}
+
+func TestScannerFlagLazyNumbers(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		flags   []any
+		want    any
+		wantErr error
+	}{
+		{name: "numbers", input: `[0, -1.5, 2e10, 1E+2]`, want: []any{Number("0"), Number("-1.5"), Number("2e10"), Number("1E+2")}},
+		{name: "out of range", input: `1e999`, want: Number("1e999")},
+		{name: "underflow", input: `1e-999`, flags: []any{ScannerFlagStrictUnderflow}, want: Number("1e-999")},
+		{name: "leading zero", input: `[01]`, wantErr: ErrInvalidNumber},
+		{name: "allowed leading zeros", input: `[007]`, flags: []any{ScannerFlagAllowLeadingZeros}, want: []any{Number("7")}},
+		{name: "trailing dot", input: `[1.]`, wantErr: ErrInvalidNumber},
+		{name: "missing exponent", input: `1e`, wantErr: ErrInvalidNumber},
+		{name: "garbage", input: `{"a":1x}`, wantErr: ErrInvalidNumber},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]any{ScannerFlagLazyNumbers}, tt.flags...)
+			got, err := ReadValue(NewScanner([]byte(tt.input), opts...))
+			if err != tt.wantErr {
+				t.Errorf("ReadValue() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadValue() = %#v, want %#v", got, tt.want)
+			}
+			if err = SkipValue(NewScanner([]byte(tt.input), opts...)); err != tt.wantErr {
+				t.Errorf("SkipValue() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScannerFlagAllowHexNumbers(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		flags   []any
+		want    any
+		wantErr error
+	}{
+		{name: "digits", input: `[0x1, 0x42, 0XfF, -0xA]`, want: []any{1.0, 66.0, 255.0, -10.0}},
+		{name: "zero", input: `0x0`, want: 0.0},
+		{name: "large", input: `0x10000000000000000`, want: 18446744073709551616.0},
+		{name: "in object", input: `{"a":0x10}`, want: map[string]any{"a": 16.0}},
+		{name: "as Number", input: `[0x1F, -0xffffffffffffffffff]`, flags: []any{ScannerFlagUseNumber},
+			want: []any{Number("31"), Number("-4722366482869645213695")}},
+		{name: "no digits", input: `0x`, wantErr: ErrInvalidNumber},
+		{name: "invalid digit", input: `0x1g`, wantErr: ErrInvalidNumber},
+		{name: "fraction", input: `0x1.5`, wantErr: ErrInvalidNumber},
+		{name: "leading zero", input: `00x1`, wantErr: ErrInvalidNumber},
+		{name: "exponent", input: `0x1p3`, wantErr: ErrInvalidNumber},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]any{ScannerFlagAllowHexNumbers}, tt.flags...)
+			got, err := Parse([]byte(tt.input), opts...)
+			if err != tt.wantErr {
+				t.Errorf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %#v, want %#v", got, tt.want)
+			}
+			if _, err = Parse([]byte(tt.input), tt.flags...); err == nil {
+				t.Errorf("Parse() without flag accepted %s", tt.input)
+			}
+		})
+	}
+
+	s := NewScanner([]byte(`0x7fffffffffffffff -0x8000000000000000 0xffffffffffffffff`), ScannerFlagAllowHexNumbers)
+	if v, err := ReadInt64(s); v != math.MaxInt64 || err != nil {
+		t.Errorf("ReadInt64() = %v, %v, want %v", v, err, int64(math.MaxInt64))
+	}
+	if v, err := ReadInt64(s); v != math.MinInt64 || err != nil {
+		t.Errorf("ReadInt64() = %v, %v, want %v", v, err, int64(math.MinInt64))
+	}
+	if v, err := ReadUint64(s); v != math.MaxUint64 || err != nil {
+		t.Errorf("ReadUint64() = %v, %v, want %v", v, err, uint64(math.MaxUint64))
+	}
+
+	var b strings.Builder
+	if err := CopyValue(&b, NewScanner([]byte(`[0xff]`), ScannerFlagAllowHexNumbers)); err != nil || b.String() != `[255]` {
+		t.Errorf("CopyValue() = %s, %v, want [255]", b.String(), err)
+	}
+}
+
+func TestScannerFlagAllowJSON5Numbers(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string // the Number read
+		wantErr error
+	}{
+		{name: "leading plus", input: `+1`, want: "1"},
+		{name: "leading plus fraction", input: `+1.5e2`, want: "1.5e2"},
+		{name: "leading point", input: `.123`, want: "0.123"},
+		{name: "negative leading point", input: `-.5`, want: "-0.5"},
+		{name: "plus leading point", input: `+.5e-1`, want: "0.5e-1"},
+		{name: "trailing point", input: `1.`, want: "1.0"},
+		{name: "trailing point exponent", input: `2.e3`, want: "2.0e3"},
+		{name: "plain", input: `-0.25`, want: "-0.25"},
+		{name: "point only", input: `.`, wantErr: ErrInvalidNumber},
+		{name: "signed point", input: `-.`, wantErr: ErrInvalidNumber},
+		{name: "point exponent", input: `.e1`, wantErr: ErrInvalidNumber},
+		{name: "plus only", input: `+`, wantErr: ErrInvalidNumber},
+		{name: "two signs", input: `+-1`, wantErr: ErrInvalidNumber},
+		{name: "two points", input: `1..2`, wantErr: ErrInvalidNumber},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte("["+tt.input+"]"), ScannerFlagAllowJSON5Numbers, ScannerFlagUseNumber)
+			if err != tt.wantErr {
+				t.Errorf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, []any{Number(tt.want)}) {
+				t.Errorf("Parse() = %#v, want [%s]", got, tt.want)
+			}
+			if tt.input != "-0.25" {
+				if _, err = Parse([]byte("[" + tt.input + "]")); err == nil {
+					t.Errorf("Parse() without flag accepted %s", tt.input)
+				}
+			}
+		})
+	}
+
+	got, err := Parse([]byte(`{"a": [+1, .5, 5., +0x10]}`), ScannerFlagAllowJSON5Numbers, ScannerFlagAllowHexNumbers)
+	if want := map[string]any{"a": []any{1.0, 0.5, 5.0, 16.0}}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %v, %v, want %v", got, err, want)
+	}
+	s := NewScanner([]byte(`+7 7.`), ScannerFlagAllowJSON5Numbers)
+	if v, err := ReadInt64(s); v != 7 || err != nil {
+		t.Errorf("ReadInt64() = %v, %v, want 7", v, err)
+	}
+	if _, err := ReadInt64(s); err != ErrNumberNotInteger {
+		t.Errorf("ReadInt64() error = %v, want %v", err, ErrNumberNotInteger)
+	}
+	var b strings.Builder
+	if err := CopyValue(&b, NewScanner([]byte(`[+1,.5]`), ScannerFlagAllowJSON5Numbers)); err != nil || b.String() != `[1,0.5]` {
+		t.Errorf("CopyValue() = %s, %v, want [1,0.5]", b.String(), err)
+	}
+}
+
+func TestScannerFlagRejectJSUnsafe(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantErr    error
+		wantOffset int
+	}{
+		{name: "line separator", input: "[\"a\xe2\x80\xa8\"]", wantErr: ErrJSUnsafeString, wantOffset: 3},
+		{name: "paragraph separator", input: "[\"\xe2\x80\xa9\"]", wantErr: ErrJSUnsafeString, wantOffset: 2},
+		{name: "after escape", input: "[\"\\n\xe2\x80\xa8\"]", wantErr: ErrJSUnsafeString, wantOffset: 4},
+		{name: "in key", input: "{\"\xe2\x80\xa8\":1}", wantErr: ErrJSUnsafeString, wantOffset: 2},
+		{name: "escaped", input: `["\u2028\u2029"]`},
+		{name: "other E2 characters", input: "[\"\xe2\x80\xa6\xe2\x82\xac\xe2\"]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.input)); err != nil {
+				t.Fatalf("Parse() without flag error = %v", err)
+			}
+			s := NewScanner([]byte(tt.input), ScannerFlagRejectJSUnsafe)
+			_, err := ReadValue(s)
+			if err != tt.wantErr {
+				t.Fatalf("ReadValue() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && s.Offset() != tt.wantOffset {
+				t.Errorf("ReadValue() offset = %d, want %d", s.Offset(), tt.wantOffset)
+			}
+			if err = SkipValue(NewScanner([]byte(tt.input), ScannerFlagRejectJSUnsafe)); err != tt.wantErr {
+				t.Errorf("SkipValue() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	s := NewScanner([]byte("\"\xe2\x80\xa8\""), ScannerFlagRejectJSUnsafe)
+	if _, _, err := s.ReadRawToken(); err != ErrJSUnsafeString || s.Offset() != 1 {
+		t.Errorf("ReadRawToken() error = %v at %d, want %v at 1", err, s.Offset(), ErrJSUnsafeString)
+	}
+
+	// many other E2 characters after an escape are checked in linear time
+	text := strings.Repeat("\xe2\x80\xa6\xe2\x82\xac\xe2\x80\x93", 100000)
+	s = NewScanner([]byte(`"\n`+text+`"`), ScannerFlagRejectJSUnsafe)
+	if v, err := ReadValue(s); err != nil || v != "\n"+text {
+		t.Errorf("ReadValue() error = %v, want the string", err)
+	}
+	s = NewScanner([]byte(`"\n`+text+"\xe2\x80\xa9\""), ScannerFlagRejectJSUnsafe)
+	if _, err := ReadValue(s); err != ErrJSUnsafeString || s.Offset() != 3+len(text) {
+		t.Errorf("ReadValue() error = %v at %d, want %v at %d", err, s.Offset(), ErrJSUnsafeString, 3+len(text))
+	}
+}
+
+func TestScannerFlagAllowComments(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    any
+		wantErr error
+	}{
+		{name: "line comments", input: "// head\n{\"a\": 1, // one\n\"b\": 2}\n// tail", want: map[string]any{"a": 1.0, "b": 2.0}},
+		{name: "block comments", input: "/* a */[1/* b */,/**/2 /* c\n d */]", want: []any{1.0, 2.0}},
+		{name: "comment at end of data", input: "1 //", want: 1.0},
+		{name: "slashes in strings", input: `"// /* x */"`, want: "// /* x */"},
+		{name: "unterminated block comment", input: "[1 /* x ]", wantErr: ErrUnexpectedToken},
+		{name: "lone slash", input: "[1 / 2]", wantErr: ErrUnexpectedToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner([]byte(tt.input), ScannerFlagAllowComments)
+			got, err := ReadValue(s)
+			if err == nil {
+				err = s.Finalize()
+			}
+			if err != tt.wantErr {
+				t.Fatalf("ReadValue() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadValue() = %v, want %v", got, tt.want)
+			}
+
+			// without the flag, comments are invalid
+			if tt.wantErr == nil && tt.name != "slashes in strings" {
+				s = NewScanner([]byte(tt.input))
+				if _, err = ReadValue(s); err == nil {
+					err = s.Finalize()
+				}
+				if err == nil {
+					t.Errorf("ReadValue() without flag accepted %q", tt.input)
+				}
+			}
+		})
+	}
+}
+
+func TestCommentHandler(t *testing.T) {
+	type comment struct {
+		text string
+		line int
+	}
+	input := "// head\r\n{\n  \"a\": 1, /* one\n two */ \"b\": 2 // b\n}\n/**/"
+	want := []comment{{"// head", 1}, {"/* one\n two */", 3}, {"// b", 4}, {"/**/", 6}}
+
+	var got []comment
+	s := NewScanner([]byte(input), ScannerFlagAllowComments, func(text string, line int) {
+		got = append(got, comment{text, line})
+	})
+	if _, err := ReadValue(s); err != nil {
+		t.Fatalf("ReadValue() unexpected error = %v", err)
+	}
+	if err := s.Finalize(); err != nil {
+		t.Fatalf("Finalize() unexpected error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("comments = %v, want %v", got, want)
+	}
+
+	// comments are reported to the handler only with the flag
+	got = nil
+	s = NewScanner([]byte("1 // x"), CommentHandler(func(text string, line int) {
+		got = append(got, comment{text, line})
+	}))
+	if _, err := ReadValue(s); err != nil || s.Finalize() == nil || got != nil {
+		t.Errorf("comment handler without flag: comments = %v, error = %v", got, err)
+	}
+}
+
+func TestScanner_ParseStringErrorOffset(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantErr    error
+		wantOffset int
+	}{
+		{name: "control char fast path", input: "\"ab\x01cd\"", wantErr: ErrInvalidString, wantOffset: 3},
+		{name: "control char slow path", input: "\"a\\nb\x1fcd\"", wantErr: ErrInvalidString, wantOffset: 5},
+		{name: "embedded NUL fast path", input: "\"a\x00a\"", wantErr: ErrInvalidString, wantOffset: 2},
+		{name: "embedded NUL slow path", input: "\"\\ta\x00a\"", wantErr: ErrInvalidString, wantOffset: 4},
+		{name: "unterminated fast path", input: "\"abc", wantErr: ErrUnexpectedEOF, wantOffset: 4},
+		{name: "unterminated slow path", input: "\"a\\nb", wantErr: ErrUnexpectedEOF, wantOffset: 5},
+		{name: "backslash at end", input: "\"a\\", wantErr: ErrUnexpectedEOF, wantOffset: 3},
+		{name: "invalid escape", input: "\"a\\kb\"", wantErr: ErrInvalidString, wantOffset: 3},
+		{name: "invalid unicode escape", input: "\"a\\u12G4\"", wantErr: ErrInvalidUnicodeEscape, wantOffset: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner([]byte(tt.input))
+			_, err := s.parseString()
+			if err != tt.wantErr {
+				t.Errorf("parseString() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if got := s.Offset(); got != tt.wantOffset {
+				t.Errorf("Offset() = %d, want %d", got, tt.wantOffset)
+			}
+		})
+	}
+}
+
+func TestScanner_EmbeddedNulVersusTruncation(t *testing.T) {
+	// an embedded NUL is an invalid string, a string that runs into the end
+	// of input is truncated input
+	nul := NewScanner([]byte("[\"a\x00a\"]"))
+	if _, err := ReadValue(nul); err != ErrInvalidString || nul.Offset() != 3 {
+		t.Errorf("embedded NUL: error = %v at %d, want %v at 3", err, nul.Offset(), ErrInvalidString)
+	}
+
+	for _, input := range []string{`["a`, `"a\u0041`, `"a\`, `"a\u00`, `{"a`, `{"a\n`} {
+		for _, flags := range []ScannerFlag{0, ScannerFlagUseNumber} {
+			s := NewScanner([]byte(input), flags)
+			if _, err := ReadValue(s); err != ErrUnexpectedEOF || !s.IsEOF() {
+				t.Errorf("ReadValue(%q) error = %v at %d, want %v at EOF", input, err, s.Offset(), ErrUnexpectedEOF)
+			}
+			s = NewScanner([]byte(input), flags)
+			if err := SkipValue(s); err != ErrUnexpectedEOF || !s.IsEOF() {
+				t.Errorf("SkipValue(%q) error = %v at %d, want %v at EOF", input, err, s.Offset(), ErrUnexpectedEOF)
+			}
+		}
+		s := NewScanner([]byte(input[strings.LastIndexAny(input, "[{"+":")+1:]))
+		for {
+			kind, _, err := s.ReadRawToken()
+			if err != nil || kind == TokenEOF {
+				if err != ErrUnexpectedEOF {
+					t.Errorf("ReadRawToken(%q) error = %v, want %v", input, err, ErrUnexpectedEOF)
+				}
+				break
+			}
+		}
+	}
+
+	// an escape that is invalid before the data ends is still invalid
+	if _, err := Parse([]byte(`"\uzz`)); err != ErrInvalidUnicodeEscape {
+		t.Errorf("Parse() error = %v, want %v", err, ErrInvalidUnicodeEscape)
+	}
+}
+
+func TestNewScannerFromReader(t *testing.T) {
+	const doc = `{"name": "John", "tags": ["a", "b"]}`
+	want := map[string]any{"name": "John", "tags": []any{"a", "b"}}
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write([]byte(doc)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{name: "plain", input: []byte(doc)},
+		{name: "plain with BOM", input: append([]byte{0xEF, 0xBB, 0xBF}, doc...)},
+		{name: "gzip", input: compressed.Bytes()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewScannerFromReader(bytes.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("NewScannerFromReader() unexpected error = %v", err)
+			}
+			got, err := ReadValue(s)
+			if err == nil {
+				err = s.Finalize()
+			}
+			if err != nil {
+				t.Fatalf("ReadValue() unexpected error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ReadValue() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestNewScannerFromReaderErrors(t *testing.T) {
+	if _, err := NewScannerFromReader(strings.NewReader("\x1f\x8b\x00")); err == nil {
+		t.Error("expected error for corrupt gzip header, got nil")
+	}
+
+	readErr := errors.New("read error")
+	if _, err := NewScannerFromReader(&failingReader{err: readErr}); err != readErr {
+		t.Errorf("got error %v, want %v", err, readErr)
+	}
+
+	s, err := NewScannerFromReader(strings.NewReader(""))
+	if err != nil || !s.IsEOF() {
+		t.Errorf("empty input: scanner at EOF = %v, error = %v", s != nil && s.IsEOF(), err)
+	}
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestNewScannerString(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    []any
+		want    any
+		wantErr error
+	}{
+		{name: "object", input: ` {"a":[1,"x\n",true]} `, want: map[string]any{"a": []any{1.0, "x\n", true}}},
+		{name: "bom", input: "\xEF\xBB\xBF\"s\"", want: "s"},
+		{name: "options", input: `[1,2,3]`, opts: []any{ScannerMaxValues(2)}, wantErr: ErrValueLimitExceeded},
+		{name: "empty", input: ``, wantErr: ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScannerString(tt.input, tt.opts...)
+			got, err := ReadValue(s)
+			if err != tt.wantErr {
+				t.Fatalf("ReadValue() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	input := strings.Repeat(`{"key":"value"},`, 100) + `null`
+	allocs := testing.AllocsPerRun(10, func() {
+		NewScannerString(input)
+	})
+	if allocs > 1 {
+		t.Errorf("NewScannerString() allocs = %v, want at most 1", allocs)
+	}
+}
+
+func TestScanner_ExpectDelim(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		delim      byte
+		wantErr    error
+		wantOffset int
+	}{
+		{name: "immediate", input: `:1`, delim: ':', wantOffset: 1},
+		{name: "after whitespace", input: " \t\n,1", delim: ',', wantOffset: 4},
+		{name: "other byte", input: `  ]`, delim: ',', wantErr: ErrUnexpectedToken, wantOffset: 2},
+		{name: "eof", input: `  `, delim: '}', wantErr: ErrUnexpectedEOF, wantOffset: 2},
+		{name: "bom", input: " \xef\xbb\xbf:", delim: ':', wantErr: ErrUnexpectedBOM, wantOffset: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner([]byte(tt.input), ScannerFlagDoNotSkipInitialWhitespace)
+			if err := s.ExpectDelim(tt.delim); err != tt.wantErr {
+				t.Errorf("ExpectDelim() error = %v, want %v", err, tt.wantErr)
+			}
+			if s.Offset() != tt.wantOffset {
+				t.Errorf("Offset() = %d, want %d", s.Offset(), tt.wantOffset)
+			}
+
+			s = NewScanner([]byte(tt.input), ScannerFlagDoNotSkipInitialWhitespace)
+			if got := s.TryDelim(tt.delim); got != (tt.wantErr == nil) {
+				t.Errorf("TryDelim() = %v, want %v", got, tt.wantErr == nil)
+			}
+			if s.Offset() != tt.wantOffset {
+				t.Errorf("TryDelim() offset = %d, want %d", s.Offset(), tt.wantOffset)
+			}
+		})
+	}
+
+	// a hand-written reader for a [key, value] pair
+	s := NewScanner([]byte(` [ "answer" , 42 ] `))
+	var key string
+	var value float64
+	err := s.ExpectDelim('[')
+	if err == nil {
+		var v any
+		v, err = ReadValue(s)
+		key, _ = v.(string)
+	}
+	if err == nil {
+		err = s.ExpectDelim(',')
+	}
+	if err == nil {
+		var v any
+		v, err = ReadValue(s)
+		value, _ = v.(float64)
+	}
+	if err == nil {
+		err = s.ExpectDelim(']')
+	}
+	if err == nil {
+		err = s.Finalize()
+	}
+	if err != nil || key != "answer" || value != 42 {
+		t.Errorf("pair = %q, %v, error %v", key, value, err)
+	}
+}
+
+func TestScanner_AtValueStart(t *testing.T) {
+	tests := []struct {
+		input      string
+		opts       []any
+		want       bool
+		wantOffset int
+	}{
+		{input: `{}`, want: true},
+		{input: " \n[1]", want: true, wantOffset: 2},
+		{input: `"s"`, want: true},
+		{input: `-1`, want: true},
+		{input: `7`, want: true},
+		{input: `true`, want: true},
+		{input: `false`, want: true},
+		{input: `null`, want: true},
+		{input: `.5`, opts: []any{ScannerFlagAllowJSON5Numbers}, want: true},
+		{input: `.5`},
+		{input: `+1`},
+		{input: `  ,1`, wantOffset: 2},
+		{input: `}`},
+		{input: `x`},
+		{input: `  `, wantOffset: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			s := NewScanner([]byte(tt.input), append(tt.opts, ScannerFlagDoNotSkipInitialWhitespace)...)
+			if got := s.AtValueStart(); got != tt.want {
+				t.Errorf("AtValueStart() = %v, want %v", got, tt.want)
+			}
+			if s.Offset() != tt.wantOffset {
+				t.Errorf("AtValueStart() offset = %d, want %d", s.Offset(), tt.wantOffset)
+			}
+		})
+	}
+}
+
+func TestScanner_Stats(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  []any
+		want  ScanStats
+	}{
+		{name: "scalar", input: `42`, want: ScanStats{Values: 1}},
+		{name: "string", input: `"héllo"`, want: ScanStats{Values: 1, StringBytes: 6}},
+		{name: "escaped string", input: `"a\nbA"`, want: ScanStats{Values: 1, StringBytes: 4}},
+		{name: "nested", input: `{"a":[1,{"bc":[[]]}],"d":"ef"}`, want: ScanStats{MaxDepth: 5, Values: 7, Keys: 3, StringBytes: 6}},
+		{name: "interned keys", input: `[{"id":1},{"id":2}]`, opts: []any{ScannerFlagInternKeys}, want: ScanStats{MaxDepth: 2, Values: 5, Keys: 2, StringBytes: 4}},
+		{name: "siblings", input: `[[1],[2],[3]]`, want: ScanStats{MaxDepth: 2, Values: 7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner([]byte(tt.input), append(tt.opts, ScannerFlagCollectStats)...)
+			if _, err := ReadValue(s); err != nil {
+				t.Fatalf("ReadValue() unexpected error = %v", err)
+			}
+			if got := s.Stats(); got != tt.want {
+				t.Errorf("Stats() = %+v, want %+v", got, tt.want)
+			}
+
+			s = NewScanner([]byte(tt.input), append(tt.opts, ScannerFlagCollectStats)...)
+			if err := CopyValue(io.Discard, s); err != nil {
+				t.Fatalf("CopyValue() unexpected error = %v", err)
+			}
+			if got := s.Stats(); got != tt.want {
+				t.Errorf("CopyValue() Stats() = %+v, want %+v", got, tt.want)
+			}
+
+			s = NewScanner([]byte(tt.input), tt.opts...)
+			if _, err := ReadValue(s); err != nil {
+				t.Fatalf("ReadValue() unexpected error = %v", err)
+			}
+			if got := s.Stats(); got != (ScanStats{}) {
+				t.Errorf("Stats() without the flag = %+v, want zero", got)
+			}
+		})
+	}
+}
+
+func TestNewScannerFromReaderMaxLength(t *testing.T) {
+	// a small gzip stream that expands into a large document
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte("[" + strings.Repeat(" ", 1<<20) + "]"))
+	zw.Close()
+
+	if _, err := NewScannerFromReader(bytes.NewReader(compressed.Bytes()), ReaderMaxLength(1<<20)); err != ErrInputTooLarge {
+		t.Errorf("NewScannerFromReader() error = %v, want %v", err, ErrInputTooLarge)
+	}
+
+	// other options are passed on to the scanner
+	s, err := NewScannerFromReader(strings.NewReader(`[1,2]`), ScannerMaxValues(2), ReaderMaxLength(5))
+	if err != nil {
+		t.Fatalf("NewScannerFromReader() unexpected error = %v", err)
+	}
+	if _, err := ReadValue(s); err != ErrValueLimitExceeded {
+		t.Errorf("ReadValue() error = %v, want %v", err, ErrValueLimitExceeded)
+	}
+}
+
+// logCapture receives the messages written to the standard logger
+type logCapture chan string
+
+func (c logCapture) Write(p []byte) (int, error) {
+	c <- string(p)
+	return len(p), nil
+}
+
+func TestScannerRequireFinalize(t *testing.T) {
+	logs := make(logCapture, 10)
+	log.SetOutput(logs)
+	defer log.SetOutput(os.Stderr)
+
+	// collect scanners that were dropped in the given way and return the
+	// messages logged for them
+	collect := func(drop func(s *Scanner)) []string {
+		drop(NewScannerString(`{"a":1} x`, ScannerFlagRequireFinalize))
+		var msgs []string
+		for i := 0; i < 5; i++ {
+			runtime.GC()
+			select {
+			case msg := <-logs:
+				msgs = append(msgs, msg)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		return msgs
+	}
+
+	msgs := collect(func(s *Scanner) { SkipValue(s) })
+	if len(msgs) != 1 || !strings.Contains(msgs[0], "without Finalize or Close, at offset 7 of 9") {
+		t.Errorf("unfinalized scanner logged %q", msgs)
+	}
+	if msgs := collect(func(s *Scanner) { SkipValue(s); s.Finalize() }); len(msgs) != 0 {
+		t.Errorf("finalized scanner logged %q", msgs)
+	}
+	if msgs := collect(func(s *Scanner) { s.Close() }); len(msgs) != 0 {
+		t.Errorf("closed scanner logged %q", msgs)
+	}
+}
+
+func TestScannerRemaining(t *testing.T) {
+	data := []byte("{\"len\":4}\n\x00\x01\xff\xfe")
+	s := NewScanner(data)
+	header, err := ReadObject(s)
+	if err != nil {
+		t.Fatalf("ReadObject() error = %v", err)
+	}
+	if !reflect.DeepEqual(header, map[string]any{"len": 4.0}) {
+		t.Errorf("ReadObject() = %v", header)
+	}
+	rest := s.Remaining()
+	if string(rest) != "\n\x00\x01\xff\xfe" {
+		t.Errorf("Remaining() = %q, want %q", rest, "\n\x00\x01\xff\xfe")
+	}
+	if &rest[0] != &data[len(data)-5] {
+		t.Errorf("Remaining() does not alias the data")
+	}
+
+	s = NewScanner([]byte(` 1 `))
+	if _, err := ReadValue(s); err != nil || string(s.Remaining()) != " " {
+		t.Errorf("Remaining() = %q, %v, want %q", s.Remaining(), err, " ")
+	}
+	if err := s.Finalize(); err != nil || len(s.Remaining()) != 0 {
+		t.Errorf("Remaining() after Finalize() = %q, %v, want empty", s.Remaining(), err)
+	}
+}
+
+// indentedDocument returns a document with deep indentation, where most of
+// the bytes are whitespace
+func indentedDocument() []byte {
+	var sb strings.Builder
+	var write func(depth int)
+	write = func(depth int) {
+		indent := "\n" + strings.Repeat("\t", depth)
+		sb.WriteString("{")
+		for i := 0; i < 4; i++ {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			sb.WriteString(indent + "\t\"k" + strconv.Itoa(i) + "\" :   ")
+			if depth < 5 {
+				write(depth + 1)
+			} else {
+				sb.WriteString("[ 1 ,\r\n  2 ,    3 ]")
+			}
+		}
+		sb.WriteString(indent + "}")
+	}
+	write(0)
+	return []byte(sb.String())
+}
+
+func BenchmarkSkipValueIndented(b *testing.B) {
+	data := indentedDocument()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		if err := SkipValue(NewScanner(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSkipValueNumbers(b *testing.B) {
+	var sb strings.Builder
+	sb.WriteString("[")
+	for i := 0; i < 1000; i++ {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(strconv.FormatFloat(float64(i)*1.0001e-3, 'g', -1, 64))
+	}
+	sb.WriteString("]")
+	data := []byte(sb.String())
+	for _, bm := range []struct {
+		name  string
+		flags ScannerFlag
+	}{
+		{name: "converted"},
+		{name: "lazy", flags: ScannerFlagLazyNumbers},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if err := SkipValue(NewScanner(data, bm.flags)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
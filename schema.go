@@ -0,0 +1,161 @@
+package jsn
+
+import (
+	"math"
+	"reflect"
+	"sort"
+)
+
+// SchemaDraft is the $schema URI of the schemas produced by InferSchema
+const SchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// InferSchema returns a minimal JSON Schema (draft 2020-12) describing the
+// structure of a tree produced by ReadValue, as a starting point for authoring
+// a schema from a sample payload.
+//
+// Scalars are described by their type, numbers without a fractional part as
+// "integer". Objects list their members under "properties", all of them
+// "required". The elements of an array are merged into a single "items"
+// schema: members present in only some of the objects are not required, and
+// values of different types yield a list of types, e.g. ["null","string"].
+// Formats, enums and other constraints are not inferred.
+//
+// The schema is itself a tree of map[string]any and []any, which Marshal
+// turns into JSON. Values of types that are not part of the tree
+// representation fail with *UnsupportedTypeError.
+func InferSchema(v any) (any, error) {
+	var n schemaNode
+	if err := n.add(v); err != nil {
+		return nil, err
+	}
+	schema := n.tree()
+	schema["$schema"] = SchemaDraft
+	return schema, nil
+}
+
+// schema types, in the order they are listed in a schema
+const (
+	schemaArray = 1 << iota
+	schemaBoolean
+	schemaInteger
+	schemaNull
+	schemaNumber
+	schemaObject
+	schemaString
+)
+
+var schemaTypeNames = [...]string{"array", "boolean", "integer", "null", "number", "object", "string"}
+
+// schemaNode accumulates the schema of the values added to it
+type schemaNode struct {
+	types    int                    // set of schema types seen
+	props    map[string]*schemaNode // members of the objects seen
+	required map[string]bool        // members present in every object seen
+	items    *schemaNode            // elements of the arrays seen
+}
+
+// add merges the schema of v into the node
+func (n *schemaNode) add(v any) error {
+	switch v := v.(type) {
+	case nil:
+		n.types |= schemaNull
+	case bool:
+		n.types |= schemaBoolean
+	case string:
+		n.types |= schemaString
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			n.types |= schemaInteger
+		} else {
+			n.types |= schemaNumber
+		}
+	case Number:
+		if v.IsInt() {
+			n.types |= schemaInteger
+		} else {
+			n.types |= schemaNumber
+		}
+	case []any:
+		n.types |= schemaArray
+		for _, e := range v {
+			if n.items == nil {
+				n.items = &schemaNode{}
+			}
+			if err := n.items.add(e); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		if n.types&schemaObject == 0 {
+			n.required = make(map[string]bool, len(v))
+			for k := range v {
+				n.required[k] = true
+			}
+		} else {
+			for k := range n.required {
+				if _, ok := v[k]; !ok {
+					delete(n.required, k)
+				}
+			}
+		}
+		n.types |= schemaObject
+		for k, e := range v {
+			if n.props == nil {
+				n.props = make(map[string]*schemaNode, len(v))
+			}
+			p := n.props[k]
+			if p == nil {
+				p = &schemaNode{}
+				n.props[k] = p
+			}
+			if err := p.add(e); err != nil {
+				return err
+			}
+		}
+	default:
+		return &UnsupportedTypeError{reflect.TypeOf(v)}
+	}
+	return nil
+}
+
+// tree returns the schema of the node as a tree
+func (n *schemaNode) tree() map[string]any {
+	types := n.types
+	if types&schemaNumber != 0 {
+		types &^= schemaInteger // integers are numbers
+	}
+	var names []any
+	for i, name := range schemaTypeNames {
+		if types&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+
+	schema := map[string]any{"type": names[0]}
+	if len(names) > 1 {
+		schema["type"] = names
+	}
+	if n.props != nil {
+		props := make(map[string]any, len(n.props))
+		for k, p := range n.props {
+			props[k] = p.tree()
+		}
+		schema["properties"] = props
+	}
+	if len(n.required) > 0 {
+		keys := make([]string, 0, len(n.required))
+		for k := range n.required {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		required := make([]any, len(keys))
+		for i, k := range keys {
+			required[i] = k
+		}
+		schema["required"] = required
+	}
+	if n.items != nil {
+		schema["items"] = n.items.tree()
+	}
+	return schema
+}
@@ -0,0 +1,67 @@
+package jsn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInferSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    []any
+		want    string
+		wantErr bool
+	}{
+		{name: "scalars", input: `[null, true, "s", 1, 1.5]`,
+			want: `{"items":{"type":["boolean","null","number","string"]},"type":"array"}`},
+		{name: "integers", input: `[1, -2, 3e2]`, want: `{"items":{"type":"integer"},"type":"array"}`},
+		{name: "numbers as Number", input: `[1, 1.0]`, opts: []any{ScannerFlagUseNumber},
+			want: `{"items":{"type":"number"},"type":"array"}`},
+		{name: "empty containers", input: `{"a":[],"b":{}}`,
+			want: `{"properties":{"a":{"type":"array"},"b":{"type":"object"}},"required":["a","b"],"type":"object"}`},
+		{name: "nested object", input: `{"id":1,"tags":["x"],"owner":{"name":"a"}}`,
+			want: `{"properties":{"id":{"type":"integer"},"owner":{"properties":{"name":{"type":"string"}},` +
+				`"required":["name"],"type":"object"},"tags":{"items":{"type":"string"},"type":"array"}},` +
+				`"required":["id","owner","tags"],"type":"object"}`},
+		{name: "merged elements", input: `[{"a":1,"b":"x"},{"a":2.5,"c":null},{"a":3,"b":null}]`,
+			want: `{"items":{"properties":{"a":{"type":"number"},"b":{"type":["null","string"]},"c":{"type":"null"}},` +
+				`"required":["a"],"type":"object"},"type":"array"}`},
+		{name: "mixed containers", input: `[[1],{"a":true},null]`,
+			want: `{"items":{"items":{"type":"integer"},"properties":{"a":{"type":"boolean"}},"required":["a"],` +
+				`"type":["array","null","object"]},"type":"array"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := ReadValue(NewScanner([]byte(tt.input), tt.opts...))
+			if err != nil {
+				t.Fatalf("ReadValue() unexpected error = %v", err)
+			}
+			schema, err := InferSchema(v)
+			if err != nil {
+				t.Fatalf("InferSchema() unexpected error = %v", err)
+			}
+			if m := schema.(map[string]any); m["$schema"] != SchemaDraft {
+				t.Errorf("InferSchema() $schema = %v, want %v", m["$schema"], SchemaDraft)
+			} else {
+				delete(m, "$schema")
+			}
+			got, err := Marshal(schema)
+			if err != nil {
+				t.Fatalf("Marshal() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("InferSchema() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInferSchemaUnsupported(t *testing.T) {
+	_, err := InferSchema(map[string]any{"a": []any{1, 2}})
+	var ute *UnsupportedTypeError
+	if !errors.As(err, &ute) || ute.Type.String() != "int" {
+		t.Errorf("InferSchema() error = %v, want *UnsupportedTypeError for int", err)
+	}
+}
@@ -0,0 +1,787 @@
+package jsn
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// StreamElements makes DecodeStream read a single top-level array and send its
+// elements one by one, instead of reading a sequence of top-level values
+type StreamElements struct{}
+
+// DecodeStream reads a sequence of JSON values from r, such as newline
+// delimited JSON, and sends each value, as returned by ReadValue, on the
+// values channel. With the StreamElements option the input must be a single
+// array instead, whose elements are sent.
+//
+// The values channel is closed at the end of input or at the first error. The
+// error, if any, is sent on the errors channel before that, which is closed
+// as well, so that a consumer reads all values and then checks the error:
+//
+//	values, errs := jsn.DecodeStream(r)
+//	for v := range values {
+//	    process(v)
+//	}
+//	if err := <-errs; err != nil {
+//	    return err
+//	}
+//
+// Input is read incrementally, only the value being decoded is kept in
+// memory, so the size of the input is unbounded. ReaderMaxLength limits the
+// size of a single value instead, longer values fail with ErrInputTooLarge.
+// The other options are those of NewScanner and apply to each value
+// separately.
+//
+// The channel is unbuffered: the reading goroutine blocks until each value is
+// received, which applies backpressure, and reads ahead at most one value. To
+// stop early, close r if possible (the next read then fails) and keep
+// receiving until the values channel is closed, otherwise the goroutine is
+// never released.
+func DecodeStream(r io.Reader, opts ...any) (<-chan any, <-chan error) {
+	sr := streamReader{r: r}
+	elements := false
+	var scanOpts []any
+	for _, opt := range opts {
+		switch v := opt.(type) {
+		case ReaderMaxLength:
+			sr.maxLength = int(v)
+		case StreamElements:
+			elements = true
+		default:
+			scanOpts = append(scanOpts, opt)
+		}
+	}
+	NewScanner(nil, scanOpts...).Close() // unsupported options panic in the caller
+
+	values := make(chan any)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(values)
+		defer close(errs)
+		send := func(chunk []byte) error {
+			s := NewScanner(chunk, scanOpts...)
+			defer s.Close()
+			v, err := ReadValue(s)
+			if err == nil {
+				err = s.Finalize()
+			}
+			if err != nil {
+				return err
+			}
+			values <- v
+			return nil
+		}
+		var err error
+		if elements {
+			err = sr.readElements(send)
+		} else {
+			err = sr.readValues(send)
+		}
+		if err != nil {
+			errs <- err
+		}
+	}()
+	return values, errs
+}
+
+// SyntaxError is returned by PrettyPrint for invalid input, it records where
+// in the stream the error was detected
+type SyntaxError struct {
+	Offset int   // byte offset from the start of the stream
+	Err    error // the cause, e.g. ErrUnexpectedToken
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%v at offset %d", e.Err, e.Offset)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// PrettyPrint reads a sequence of JSON values from r and writes them to w
+// indented by indent per nesting level, each value followed by a newline, like
+// `jq .` does. An empty indent produces compact output.
+//
+// Strings and numbers are copied verbatim, escapes included, and no tree is
+// built: input is validated and printed incrementally as it is read, so memory
+// use is bounded by the read buffer and the longest string or number rather
+// than by the size of the input or of a value. Invalid input fails with a
+// *SyntaxError holding the offset of the error in the stream as soon as the
+// error is read, the output written up to that point is kept.
+func PrettyPrint(w io.Writer, r io.Reader, indent string) (err error) {
+	out := w
+	if !isBuffered(w) {
+		bw := bufio.NewWriter(w)
+		out = bw
+		defer func() {
+			if ferr := bw.Flush(); err == nil {
+				err = ferr
+			}
+		}()
+	}
+	s := NewScanner(nil)
+	defer s.Close()
+	p := prettyPrinter{w: out, indent: indent}
+	v := streamValidator{sr: streamReader{r: r}, s: s, p: &p}
+	err = v.prettyPrint()
+	if err != nil && err != v.readErr && err != p.err {
+		err = &SyntaxError{Offset: v.sr.dropped + s.cur, Err: err}
+	}
+	return err
+}
+
+// prettyPrinter writes the tokens of values indented, see PrettyPrint
+type prettyPrinter struct {
+	w      io.Writer
+	indent string
+	depth  int
+	open   bool   // a container was just opened, it stays on one line if empty
+	buf    []byte // the output of the current token
+	err    error  // the first write error
+}
+
+// token writes the text of the next token of a value
+func (p *prettyPrinter) token(text []byte) {
+	b := p.buf[:0]
+	c := text[0]
+	switch {
+	case p.open && (c == '}' || c == ']'):
+		// an empty container
+	case c == '}' || c == ']':
+		p.depth--
+		b = p.newline(b)
+	case p.open:
+		p.depth++
+		b = p.newline(b)
+	}
+	p.open = c == '{' || c == '['
+	b = append(b, text...)
+	switch {
+	case c == ',':
+		b = p.newline(b)
+	case c == ':' && p.indent != "":
+		b = append(b, ' ')
+	}
+	p.write(b)
+}
+
+// end ends a top-level value with a newline
+func (p *prettyPrinter) end() {
+	p.write(append(p.buf[:0], '\n'))
+}
+
+// newline appends a line break and the indentation of the current depth to b,
+// unless the output is compact
+func (p *prettyPrinter) newline(b []byte) []byte {
+	if p.indent == "" {
+		return b
+	}
+	b = append(b, '\n')
+	for i := 0; i < p.depth; i++ {
+		b = append(b, p.indent...)
+	}
+	return b
+}
+
+// write writes b unless a write failed already, b is kept for reuse
+func (p *prettyPrinter) write(b []byte) {
+	p.buf = b
+	if p.err == nil {
+		_, p.err = p.w.Write(b)
+	}
+}
+
+// ValidateStream reads a single JSON value from r and validates it without
+// building it, like SkipValue followed by Finalize. The options are those of
+// NewScannerFromReader, ReaderMaxLength and ScannerMaxDepth in particular make
+// this a gatekeeper for untrusted input such as HTTP request bodies. With
+// ScannerFlagLazyNumbers, numbers are only checked for their syntax, which is
+// faster.
+//
+// Input is validated incrementally as it is read: memory use is bounded by
+// the read buffer and the longest string or number rather than by the size of
+// the input (RejectDuplicateKeys also keeps the keys of the open objects), and
+// reading stops at the first error, without consuming the rest of r.
+func ValidateStream(r io.Reader, opts ...any) error {
+	maxLength, opts := readerOptions(opts)
+	rc, err := uncompressed(r)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	s := NewScanner(nil, opts...)
+	defer s.Close()
+	v := streamValidator{sr: streamReader{r: rc}, s: s, maxLength: maxLength}
+	return v.validate()
+}
+
+// streamValidator validates a value while reading it, and prints it if it
+// has a printer. The scanner only ever sees the buffer of the stream reader
+// and steps are taken once the token they read is complete in it, see ready
+type streamValidator struct {
+	sr        streamReader
+	s         *Scanner
+	maxLength int            // limit on the size of the input, see ReaderMaxLength
+	keys      [][]byte       // copies of the raw keys of the open objects, per depth
+	p         *prettyPrinter // receives the tokens read, see PrettyPrint
+	readErr   error          // the error of the reader, see fill
+
+	// progress of the check for a complete token, which is resumed when more
+	// data is read
+	scanned int
+	escaped bool
+}
+
+// validate implements ValidateStream
+func (v *streamValidator) validate() error {
+	if err := v.skipBOM(); err != nil {
+		return err
+	}
+	if err := v.value(); err != nil {
+		return err
+	}
+	if err := v.ready(expectDelim); err != nil {
+		return err
+	}
+	return v.s.Finalize()
+}
+
+// prettyPrint implements PrettyPrint, each value is printed as it is read
+func (v *streamValidator) prettyPrint() error {
+	s := v.s
+	if err := v.skipBOM(); err != nil {
+		return err
+	}
+	for {
+		if err := v.ready(expectValue); err != nil {
+			return err
+		}
+		if s.IsEOF() {
+			return v.p.err
+		}
+		c := s.peek()
+		if err := v.value(); err != nil {
+			return err
+		}
+		// numbers and literals are delimited from the value that follows
+		if c != '{' && c != '[' && c != '"' {
+			for s.IsEOF() && !v.sr.eof {
+				if err := v.fill(); err != nil {
+					return err
+				}
+			}
+			if !s.IsEOF() && !isWhitespace[s.peek()] {
+				return s.unexpectedToken()
+			}
+		}
+		v.p.end()
+	}
+}
+
+// skipBOM skips a BOM at the start of the input, as NewScanner does
+func (v *streamValidator) skipBOM() error {
+	// enough data to tell a BOM
+	for len(v.sr.buf) < 3 && !v.sr.eof && bytes.HasPrefix([]byte("\xef\xbb\xbf"), v.sr.buf) {
+		if err := v.fill(); err != nil {
+			return err
+		}
+	}
+	if v.s.flags&ScannerFlagDoNotSkipBOM == 0 {
+		v.s.SkipBOM()
+	}
+	return nil
+}
+
+// value reads a value, following readValue, and passes its tokens to the
+// printer if there is one
+func (v *streamValidator) value() error {
+	s := v.s
+	var stack []readFrame
+	for {
+		if err := v.ready(expectValue); err != nil {
+			return err
+		}
+		switch s.peek() {
+		case '{', '[':
+			if err := s.countValue(); err != nil {
+				return err
+			}
+			f := readFrame{isObj: s.peek() == '{'}
+			end := byte(']')
+			if f.isObj {
+				end = '}'
+			}
+			s.cur++
+			if err := s.enterContainer(); err != nil {
+				return prependPath(err, framePath(stack))
+			}
+			v.emit(s.data[s.cur-1 : s.cur])
+			next := expectValue
+			if f.isObj {
+				next = expectKey
+			}
+			if err := v.ready(next); err != nil {
+				return err
+			}
+			if s.skipByte(end) {
+				v.emit(s.data[s.cur-1 : s.cur])
+				s.leaveContainer()
+				break
+			}
+			stack = append(stack, f)
+			if f.isObj {
+				if err := v.readMemberKey(stack, len(stack)-1); err != nil {
+					return err
+				}
+			}
+			continue
+		default:
+			// a scalar, which is complete in the buffer
+			start := s.cur
+			if err := SkipValue(s); err != nil {
+				return err
+			}
+			v.emit(s.data[start:s.cur])
+		}
+
+		// close the containers that are complete
+		for {
+			if len(stack) == 0 {
+				return nil
+			}
+			if err := v.ready(expectDelim); err != nil {
+				return err
+			}
+			top := &stack[len(stack)-1]
+			if s.IsEOF() {
+				return ErrUnexpectedEOF
+			}
+			if top.isObj && s.skipByte('}') || !top.isObj && s.skipByte(']') {
+				v.emit(s.data[s.cur-1 : s.cur])
+				s.leaveContainer()
+				stack = stack[:len(stack)-1]
+				continue
+			}
+			if !s.skipByte(',') {
+				return ErrUnexpectedToken
+			}
+			v.emit(s.data[s.cur-1 : s.cur])
+			if !top.isObj {
+				top.n++
+			} else if err := v.readMemberKey(stack, len(stack)-1); err != nil {
+				return err
+			}
+			break
+		}
+	}
+}
+
+// readMemberKey reads the next member key and its colon, as readMemberKey
+// does for readValue, for the frame at index i of stack
+func (v *streamValidator) readMemberKey(stack []readFrame, i int) error {
+	f := &stack[i]
+	if err := v.ready(expectKey); err != nil {
+		return err
+	}
+	start := v.s.cur
+	if err := readKey(v.s, f, false); err != nil {
+		return err
+	}
+	v.emit(v.s.data[start:v.s.cur])
+	if f.raw != nil {
+		// the buffer is overwritten as more data is read
+		for len(v.keys) <= i {
+			v.keys = append(v.keys, nil)
+		}
+		v.keys[i] = append(v.keys[i][:0], f.raw...)
+		f.raw = v.keys[i]
+	}
+	if err := v.ready(expectDelim); err != nil {
+		return err
+	}
+	if err := v.s.ExpectDelim(':'); err != nil {
+		return err
+	}
+	v.emit(v.s.data[v.s.cur-1 : v.s.cur])
+	return nil
+}
+
+// emit passes the text of a token to the printer, if any
+func (v *streamValidator) emit(text []byte) {
+	if v.p != nil {
+		v.p.token(text)
+	}
+}
+
+// the tokens that are valid at a position, see ready
+const (
+	expectDelim = iota // delimiters
+	expectKey          // strings and delimiters
+	expectValue        // any token
+)
+
+// ready skips whitespace and reads more data until the token that follows is
+// complete in the buffer, or the input ends. Only the tokens that are valid
+// at the position, as given by expect, are read in full, the first byte of
+// other tokens is enough to fail.
+func (v *streamValidator) ready(expect int) error {
+	s := v.s
+	if v.p != nil && v.p.err != nil {
+		return v.p.err // no point in reading on
+	}
+	v.scanned, v.escaped = 0, false
+	for {
+		if s.flags&ScannerFlagAllowComments != 0 && !v.sr.eof {
+			// a line comment that runs to the end of the buffer may continue,
+			// it is only skipped once it is complete
+			start, onComment := s.cur, s.onComment
+			s.onComment = nil
+			s.skipWhitespace()
+			s.onComment = onComment
+			atEnd := s.cur == len(s.data)
+			s.cur = start
+			if atEnd {
+				if err := v.fill(); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		s.skipWhitespace()
+		if v.sr.eof || v.tokenComplete(expect) {
+			return nil
+		}
+		if err := v.fill(); err != nil {
+			return err
+		}
+	}
+}
+
+// tokenComplete reports whether the token at the cursor is complete in the
+// buffer, or is invalid already, so that reading it does not depend on data
+// that is yet to be read
+func (v *streamValidator) tokenComplete(expect int) bool {
+	s := v.s
+	rest := s.data[s.cur:]
+	if len(rest) == 0 {
+		return false
+	}
+	switch c := rest[0]; {
+	case c == 0xEF:
+		return len(rest) >= 3 // a BOM or garbage
+	case c == '/' && s.flags&ScannerFlagAllowComments != 0:
+		// a block comment that is not terminated yet
+		return len(rest) >= 2 && rest[1] != '*'
+	case expect == expectDelim || expect == expectKey && c != '"':
+		return true
+	case c == '"':
+		if v.scanned == 0 {
+			v.scanned = 1
+		}
+		for ; v.scanned < len(rest); v.scanned++ {
+			c := rest[v.scanned]
+			switch {
+			case v.escaped:
+				v.escaped = false
+				end := v.scanned + 5
+				if end > len(rest) {
+					end = len(rest)
+				}
+				if c == 'u' && !isHex(rest[v.scanned+1:end]) || bytes.IndexByte([]byte(`"\\/bfnrtu`), c) < 0 {
+					return true // an invalid escape
+				}
+			case c == '\\':
+				v.escaped = true
+			case c == '"' || c <= 0x1F:
+				return true
+			}
+		}
+		return false
+	case c == 't' || c == 'f' || c == 'n':
+		literal := literalFor(c)
+		return len(rest) >= len(literal) || !bytes.HasPrefix(literal, rest)
+	case c == '-' || c == '+' || c == '.' || '0' <= c && c <= '9':
+		// numbers end at the first byte that cannot be part of one
+		hex := s.flags&ScannerFlagAllowHexNumbers != 0
+		for ; v.scanned < len(rest); v.scanned++ {
+			if c := rest[v.scanned]; !isNumberByte[c] && !(hex && (c == 'x' || c == 'X' || isHex([]byte{c}))) {
+				return true
+			}
+		}
+		return false
+	}
+	return true // delimiters and invalid bytes
+}
+
+// literalFor returns the literal starting with c, one of t, f and n
+func literalFor(c byte) []byte {
+	switch c {
+	case 't':
+		return []byte("true")
+	case 'f':
+		return []byte("false")
+	}
+	return []byte("null")
+}
+
+// isNumberByte flags the bytes that can be part of a decimal number
+var isNumberByte = [256]bool{'0': true, '1': true, '2': true, '3': true, '4': true, '5': true, '6': true,
+	'7': true, '8': true, '9': true, '+': true, '-': true, '.': true, 'e': true, 'E': true}
+
+// fill reads more data, keeping the data from the cursor on
+func (v *streamValidator) fill() error {
+	sr, s := &v.sr, v.s
+	sr.pos = s.cur
+	// comment lines are counted from an offset into the data, see skipComment
+	if s.commentOff < sr.pos {
+		s.commentLine += bytes.Count(s.data[s.commentOff:sr.pos], []byte{'\n'})
+		s.commentOff = 0
+	} else {
+		s.commentOff -= sr.pos
+	}
+	if err := sr.fill(); err != nil {
+		v.readErr = err
+		return err
+	}
+	s.data, s.cur = sr.buf, sr.pos
+	if v.maxLength > 0 && sr.dropped+len(sr.buf) > v.maxLength {
+		return ErrInputTooLarge
+	}
+	return nil
+}
+
+// streamReader splits the data of a reader into the text of JSON values
+// without parsing them, so that each value can be parsed as soon as it is
+// complete
+type streamReader struct {
+	r         io.Reader
+	buf       []byte
+	pos       int  // start of the unconsumed data in buf
+	dropped   int  // number of bytes consumed and dropped from buf
+	eof       bool // r is exhausted
+	maxLength int  // size limit of a single value, see ReaderMaxLength
+}
+
+// readValues passes each top-level value to send
+func (sr *streamReader) readValues(send func([]byte) error) error {
+	if err := sr.skipBOM(); err != nil {
+		return err
+	}
+	for {
+		if err := sr.skipWhitespace(); err != nil {
+			return err
+		}
+		if sr.atEOF() {
+			return nil
+		}
+		chunk, err := sr.next()
+		if err != nil {
+			return err
+		}
+		if err = send(chunk); err != nil {
+			return err
+		}
+	}
+}
+
+// readElements passes each element of a top-level array to send
+func (sr *streamReader) readElements(send func([]byte) error) error {
+	if err := sr.skipBOM(); err != nil {
+		return err
+	}
+	if err := sr.expect('['); err != nil {
+		return err
+	}
+	if err := sr.skipWhitespace(); err != nil {
+		return err
+	}
+	if !sr.skipByte(']') {
+		for {
+			chunk, err := sr.next()
+			if err != nil {
+				return err
+			}
+			if err = send(chunk); err != nil {
+				return err
+			}
+			if err = sr.skipWhitespace(); err != nil {
+				return err
+			}
+			if sr.skipByte(']') {
+				break
+			}
+			if err = sr.expect(','); err != nil {
+				return err
+			}
+			if err = sr.skipWhitespace(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := sr.skipWhitespace(); err != nil {
+		return err
+	}
+	if !sr.atEOF() {
+		return ErrUnexpectedToken
+	}
+	return nil
+}
+
+// next consumes and returns the text of the value at the current position,
+// which follows any whitespace. The result is valid until the next read.
+func (sr *streamReader) next() ([]byte, error) {
+	n, err := sr.extent()
+	if err != nil {
+		return nil, err
+	}
+	if sr.maxLength > 0 && n > sr.maxLength {
+		return nil, ErrInputTooLarge
+	}
+	chunk := sr.buf[sr.pos : sr.pos+n]
+	sr.pos += n
+	return chunk, nil
+}
+
+// extent returns the length of the value at the current position, reading
+// more data as needed. The value is delimited structurally, by matching
+// brackets outside of strings, and validated when it is parsed: a stray
+// delimiter is returned as a value of its own, a truncated value extends to
+// the end of input.
+func (sr *streamReader) extent() (int, error) {
+	depth := 0
+	inString, escaped := false, false
+	i := 0 // relative to pos, which moves when more data is read
+	for {
+		data := sr.buf[sr.pos:]
+		for ; i < len(data); i++ {
+			c := data[i]
+			switch {
+			case inString:
+				switch {
+				case escaped:
+					escaped = false
+				case c == '\\':
+					escaped = true
+				case c == '"':
+					inString = false
+					if depth == 0 {
+						return i + 1, nil
+					}
+				}
+			case c == '"':
+				inString = true
+			case c == '{' || c == '[':
+				depth++
+			case c == '}' || c == ']':
+				if depth == 0 {
+					return scalarEnd(i), nil
+				}
+				depth--
+				if depth == 0 {
+					return i + 1, nil
+				}
+			case depth == 0 && (c == ',' || isWhitespace[c]):
+				return scalarEnd(i), nil
+			}
+		}
+		if sr.maxLength > 0 && i > sr.maxLength {
+			return 0, ErrInputTooLarge
+		}
+		if sr.eof {
+			return i, nil
+		}
+		if err := sr.fill(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// scalarEnd returns the length of a scalar value that is followed by a
+// delimiter at offset i. With nothing before it, the delimiter itself is the
+// value, which fails to parse.
+func scalarEnd(i int) int {
+	if i == 0 {
+		return 1
+	}
+	return i
+}
+
+// fill reads more data into the buffer, dropping the consumed data
+func (sr *streamReader) fill() error {
+	sr.dropped += sr.pos
+	n := copy(sr.buf, sr.buf[sr.pos:])
+	sr.buf = sr.buf[:n]
+	sr.pos = 0
+	if cap(sr.buf)-n < 4096 {
+		buf := make([]byte, n, 2*cap(sr.buf)+4096)
+		copy(buf, sr.buf)
+		sr.buf = buf
+	}
+	m, err := sr.r.Read(sr.buf[n:cap(sr.buf)])
+	sr.buf = sr.buf[:n+m]
+	if err == io.EOF {
+		sr.eof = true
+		return nil
+	}
+	return err
+}
+
+// skipWhitespace skips whitespace, reading more data as needed
+func (sr *streamReader) skipWhitespace() error {
+	for {
+		for sr.pos < len(sr.buf) && isWhitespace[sr.buf[sr.pos]] {
+			sr.pos++
+		}
+		if sr.pos < len(sr.buf) || sr.eof {
+			return nil
+		}
+		if err := sr.fill(); err != nil {
+			return err
+		}
+	}
+}
+
+// skipBOM skips a UTF-8 byte order mark at the start of the input
+func (sr *streamReader) skipBOM() error {
+	for len(sr.buf)-sr.pos < 3 && !sr.eof {
+		if err := sr.fill(); err != nil {
+			return err
+		}
+	}
+	s := Scanner{data: sr.buf[sr.pos:]}
+	if s.SkipBOM() {
+		sr.pos += 3
+	}
+	return nil
+}
+
+// atEOF reports whether all input has been consumed
+func (sr *streamReader) atEOF() bool {
+	return sr.pos >= len(sr.buf) && sr.eof
+}
+
+// skipByte consumes b if it is the next byte in the buffer
+func (sr *streamReader) skipByte(b byte) bool {
+	if sr.pos < len(sr.buf) && sr.buf[sr.pos] == b {
+		sr.pos++
+		return true
+	}
+	return false
+}
+
+// expect skips whitespace and consumes the delimiter b
+func (sr *streamReader) expect(b byte) error {
+	if err := sr.skipWhitespace(); err != nil {
+		return err
+	}
+	if sr.atEOF() {
+		return ErrUnexpectedEOF
+	}
+	if !sr.skipByte(b) {
+		return ErrUnexpectedToken
+	}
+	return nil
+}
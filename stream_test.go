@@ -0,0 +1,417 @@
+package jsn
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// collectStream drains the channels of DecodeStream
+func collectStream(values <-chan any, errs <-chan error) ([]any, error) {
+	var got []any
+	for v := range values {
+		got = append(got, v)
+	}
+	return got, <-errs
+}
+
+func TestDecodeStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    []any
+		want    []any
+		wantErr error
+	}{
+		{name: "empty", input: "", want: nil},
+		{name: "whitespace", input: " \n ", want: nil},
+		{name: "ndjson", input: "{\"a\":1}\n[2,\"]\"]\n\"x\\\"\"\n", want: []any{map[string]any{"a": 1.0}, []any{2.0, "]"}, "x\""}},
+		{name: "concatenated", input: `{}[]"s"1 true null`, want: []any{map[string]any{}, []any(nil), "s", 1.0, true, nil}},
+		{name: "large value", input: `["` + strings.Repeat("a", 10000) + `"] 1`, want: []any{[]any{strings.Repeat("a", 10000)}, 1.0}},
+		{name: "bom", input: "\xef\xbb\xbf1 2", want: []any{1.0, 2.0}},
+		{name: "invalid value", input: "1\n{\"a\" 1}\n3", want: []any{1.0}, wantErr: ErrUnexpectedToken},
+		{name: "number with garbage", input: `1 2x`, want: []any{1.0}, wantErr: ErrInvalidNumber},
+		{name: "stray delimiter", input: `1 ] 2`, want: []any{1.0}, wantErr: ErrUnexpectedToken},
+		{name: "truncated", input: `1 [2, 3`, want: []any{1.0}, wantErr: ErrUnexpectedEOF},
+		{name: "scanner options", input: `1 1.5`, opts: []any{ScannerFlagUseNumber}, want: []any{Number("1"), Number("1.5")}},
+		{name: "value too large", input: `[1] [1,2,3]`, opts: []any{ReaderMaxLength(5)}, want: []any{[]any{1.0}}, wantErr: ErrInputTooLarge},
+
+		{name: "elements", input: ` [ {"a":[1]} , "x",3 ] `, opts: []any{StreamElements{}}, want: []any{map[string]any{"a": []any{1.0}}, "x", 3.0}},
+		{name: "no elements", input: `[ ]`, opts: []any{StreamElements{}}, want: nil},
+		{name: "not an array", input: `{}`, opts: []any{StreamElements{}}, wantErr: ErrUnexpectedToken},
+		{name: "trailing comma", input: `[1,]`, opts: []any{StreamElements{}}, want: []any{1.0}, wantErr: ErrUnexpectedToken},
+		{name: "missing comma", input: `[1 2]`, opts: []any{StreamElements{}}, want: []any{1.0}, wantErr: ErrUnexpectedToken},
+		{name: "unterminated array", input: `[1,2`, opts: []any{StreamElements{}}, want: []any{1.0, 2.0}, wantErr: ErrUnexpectedEOF},
+		{name: "data after array", input: `[1] 2`, opts: []any{StreamElements{}}, want: []any{1.0}, wantErr: ErrUnexpectedToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// reading one byte at a time splits every value across reads
+			for _, r := range []io.Reader{
+				strings.NewReader(tt.input),
+				iotest.OneByteReader(strings.NewReader(tt.input)),
+			} {
+				got, err := collectStream(DecodeStream(r, tt.opts...))
+				if err != tt.wantErr {
+					t.Errorf("DecodeStream() error = %v, want %v", err, tt.wantErr)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("DecodeStream() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeStreamReaderError(t *testing.T) {
+	testErr := errors.New("test error")
+	r := iotest.TimeoutReader(strings.NewReader(strings.Repeat("1 ", 3000)))
+	got, err := collectStream(DecodeStream(r))
+	if err != iotest.ErrTimeout {
+		t.Errorf("DecodeStream() error = %v, want %v", err, iotest.ErrTimeout)
+	}
+	if len(got) == 0 {
+		t.Error("DecodeStream() sent no values before the error")
+	}
+
+	got, err = collectStream(DecodeStream(iotest.ErrReader(testErr)))
+	if err != testErr || got != nil {
+		t.Errorf("DecodeStream() = %v, %v, want no values and %v", got, err, testErr)
+	}
+}
+
+func TestDecodeStreamOptions(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("DecodeStream() did not panic for an unsupported option")
+		}
+	}()
+	DecodeStream(strings.NewReader(""), "unsupported")
+}
+
+func TestDecodeStreamRequireFinalize(t *testing.T) {
+	logs := make(logCapture, 10)
+	log.SetOutput(logs)
+	defer log.SetOutput(os.Stderr)
+
+	for _, input := range []string{`1 [2]`, `1 {"a" 2}`} {
+		collectStream(DecodeStream(strings.NewReader(input), ScannerFlagRequireFinalize))
+	}
+	for i := 0; i < 5; i++ {
+		runtime.GC()
+		select {
+		case msg := <-logs:
+			t.Errorf("DecodeStream() logged %q", msg)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestPrettyPrint(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		indent  string
+		want    string
+		wantErr error
+		wantOff int
+	}{
+		{name: "empty", input: " \n ", indent: "  ", want: ""},
+		{name: "scalars", input: `1 "a" true null`, indent: "  ", want: "1\n\"a\"\ntrue\nnull\n"},
+		{name: "object", input: `{"a":1,"b":[true,{"c":null}]}`, indent: "  ",
+			want: "{\n  \"a\": 1,\n  \"b\": [\n    true,\n    {\n      \"c\": null\n    }\n  ]\n}\n"},
+		{name: "empty containers", input: `{ "a" : [ ] , "b" : { } }`, indent: "\t",
+			want: "{\n\t\"a\": [],\n\t\"b\": {}\n}\n"},
+		{name: "verbatim", input: `["\u00e9\n", 1.50e+3]`, indent: " ",
+			want: "[\n \"\\u00e9\\n\",\n 1.50e+3\n]\n"},
+		{name: "compact", input: "{ \"a\" : [ 1 , 2 ] }\n[ ]", indent: "", want: "{\"a\":[1,2]}\n[]\n"},
+		{name: "bom", input: "\xef\xbb\xbf[1]", indent: " ", want: "[\n 1\n]\n"},
+		{name: "strings delimit themselves", input: `"a"[1]"b"2`, indent: " ", want: "\"a\"\n[\n 1\n]\n\"b\"\n2\n"},
+		{name: "invalid", input: "[1]\n{\"a\" 1}", indent: " ", want: "[\n 1\n]\n{\n \"a\"",
+			wantErr: ErrUnexpectedToken, wantOff: 9},
+		{name: "invalid after bom", input: "\xef\xbb\xbf1 2x", indent: " ", want: "1\n",
+			wantErr: ErrInvalidNumber, wantOff: 6},
+		{name: "truncated", input: `[1, 2`, indent: " ", want: "[\n 1,\n 2", wantErr: ErrUnexpectedEOF, wantOff: 5},
+		{name: "adjacent literals", input: `true1`, indent: " ", want: "true", wantErr: ErrUnexpectedToken, wantOff: 4},
+		{name: "stray delimiter", input: `1,2`, indent: " ", want: "1", wantErr: ErrUnexpectedToken, wantOff: 1},
+		{name: "too deep", input: strings.Repeat("[", DefaultMaxDepth+1), indent: "", want: strings.Repeat("[", DefaultMaxDepth),
+			wantErr: ErrMaxDepthExceeded, wantOff: DefaultMaxDepth + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, r := range []io.Reader{
+				strings.NewReader(tt.input),
+				iotest.OneByteReader(strings.NewReader(tt.input)),
+			} {
+				var b strings.Builder
+				err := PrettyPrint(&b, r, tt.indent)
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("PrettyPrint() error = %v, want %v", err, tt.wantErr)
+				}
+				var se *SyntaxError
+				if tt.wantErr != nil && (!errors.As(err, &se) || se.Offset != tt.wantOff) {
+					t.Errorf("PrettyPrint() error = %v, want *SyntaxError at offset %d", err, tt.wantOff)
+				}
+				if b.String() != tt.want {
+					t.Errorf("PrettyPrint() = %q, want %q", b.String(), tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPrettyPrintLargeInput(t *testing.T) {
+	// many values spanning buffer refills
+	input := strings.Repeat(`{"k":[1,"x"]} `, 2000)
+	var b strings.Builder
+	if err := PrettyPrint(&b, iotest.HalfReader(strings.NewReader(input)), ""); err != nil {
+		t.Fatalf("PrettyPrint() unexpected error = %v", err)
+	}
+	if want := strings.Repeat("{\"k\":[1,\"x\"]}\n", 2000); b.String() != want {
+		t.Errorf("PrettyPrint() output differs, got %d bytes, want %d", b.Len(), len(want))
+	}
+
+	input += `{"k":]}`
+	err := PrettyPrint(io.Discard, strings.NewReader(input), "  ")
+	var se *SyntaxError
+	if !errors.As(err, &se) || se.Offset != len(input)-2 {
+		t.Errorf("PrettyPrint() error = %v, want *SyntaxError at offset %d", err, len(input)-2)
+	}
+}
+
+func TestPrettyPrintIncremental(t *testing.T) {
+	// valid values print as json.Indent does, however the input is split
+	// across reads
+	for _, tt := range NSTTestSuiteData {
+		var want bytes.Buffer
+		if err := json.Indent(&want, []byte(strings.TrimRight(tt.Content, " \t\r\n")), "", "  "); err != nil || SkipValue(NewScannerString(tt.Content)) != nil {
+			continue
+		}
+		want.WriteByte('\n')
+		for _, r := range []io.Reader{strings.NewReader(tt.Content), iotest.OneByteReader(strings.NewReader(tt.Content))} {
+			var b strings.Builder
+			if err := PrettyPrint(&b, r, "  "); err != nil || b.String() != want.String() {
+				t.Errorf("PrettyPrint(%s) = %q, %v, want %q", tt.Name, b.String(), err, want.String())
+			}
+		}
+	}
+}
+
+func TestPrettyPrintEarlyError(t *testing.T) {
+	// the reader fails after the first bad byte, which must be reported
+	// without reading further, once the tokens before it are written
+	testErr := errors.New("test error")
+	tests := []struct {
+		input   string
+		want    string
+		wantErr error
+	}{
+		{input: `[1,2}`, want: "[1,2", wantErr: ErrUnexpectedToken},
+		{input: `{"a" 1`, want: `{"a"`, wantErr: ErrUnexpectedToken},
+		{input: `[1x`, want: "[", wantErr: ErrInvalidNumber},
+		{input: `["\q`, want: "[", wantErr: ErrInvalidString},
+		{input: `1 [2,`, want: "1\n[2,", wantErr: testErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			for _, r := range []io.Reader{strings.NewReader(tt.input), iotest.OneByteReader(strings.NewReader(tt.input))} {
+				var b strings.Builder
+				err := PrettyPrint(&b, io.MultiReader(r, iotest.ErrReader(testErr)), "")
+				var se *SyntaxError
+				if !errors.Is(err, tt.wantErr) || (tt.wantErr == testErr) == errors.As(err, &se) {
+					t.Errorf("PrettyPrint() error = %v, want %v", err, tt.wantErr)
+				}
+				if b.String() != tt.want {
+					t.Errorf("PrettyPrint() = %q, want %q", b.String(), tt.want)
+				}
+			}
+		})
+	}
+
+	// write errors stop reading
+	testErr = errors.New("write error")
+	r := &countingReader{r: strings.NewReader("[" + strings.Repeat("1,", 1<<20) + "1]")}
+	if err := PrettyPrint(&errorWriter{err: testErr}, r, " "); err != testErr {
+		t.Errorf("PrettyPrint() error = %v, want %v", err, testErr)
+	}
+	if r.n > 64<<10 {
+		t.Errorf("PrettyPrint() read %d bytes after a write error", r.n)
+	}
+}
+
+func TestPrettyPrintBoundedMemory(t *testing.T) {
+	// a single large value, and long tokens, which the buffer grows for
+	const n = 100000
+	input := "[" + strings.Repeat(`{"key":"value","n":[1.5,true,null]},`, n) + `"` + strings.Repeat("x", 50000) + `"]`
+	p := prettyPrinter{w: io.Discard, indent: "  "}
+	v := streamValidator{sr: streamReader{r: strings.NewReader(input)}, s: NewScanner(nil), p: &p}
+	if err := v.prettyPrint(); err != nil {
+		t.Fatalf("prettyPrint() unexpected error = %v", err)
+	}
+	if c := cap(v.sr.buf); c > 256<<10 {
+		t.Errorf("prettyPrint() buffer grew to %d bytes for %d bytes of input", c, len(input))
+	}
+}
+
+// countingReader records how many bytes were read from it
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	return n, err
+}
+
+func TestValidateStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    []any
+		wantErr error
+	}{
+		{name: "valid", input: ` {"a":[1,2,{"b":null}]} `},
+		{name: "invalid", input: `{"a":[1,2}`, wantErr: ErrUnexpectedToken},
+		{name: "truncated", input: `{"a":`, wantErr: ErrUnexpectedEOF},
+		{name: "trailing data", input: `1 2`, wantErr: ErrUnexpectedToken},
+		{name: "within length", input: `[1,2,3]`, opts: []any{ReaderMaxLength(7)}},
+		{name: "too long", input: `[1,2,3]`, opts: []any{ReaderMaxLength(6)}, wantErr: ErrInputTooLarge},
+		{name: "too deep", input: `[[[1]]]`, opts: []any{ScannerMaxDepth(2)}, wantErr: ErrMaxDepthExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateStream(strings.NewReader(tt.input), tt.opts...); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateStream() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// reading stops once the limit is crossed
+	r := &countingReader{r: strings.NewReader("[" + strings.Repeat("1,", 1<<20) + "1]")}
+	if err := ValidateStream(r, ReaderMaxLength(1000)); err != ErrInputTooLarge {
+		t.Errorf("ValidateStream() error = %v, want %v", err, ErrInputTooLarge)
+	}
+	if r.n > 64<<10 {
+		t.Errorf("ValidateStream() read %d bytes past a limit of 1000", r.n)
+	}
+}
+
+func TestValidateStreamIncremental(t *testing.T) {
+	// the outcome is that of SkipValue and Finalize over the whole input,
+	// however the input is split across reads
+	var inputs []string
+	for _, tt := range NSTTestSuiteData {
+		inputs = append(inputs, tt.Content)
+	}
+	inputs = append(inputs,
+		` // leading
+		{"a" /* before colon */ : [1, 2 // two
+		] /* unterminated`,
+		"/* a */ [1, // one\n 2] // trailing",
+		"[1,\xef\xbb\xbf2]\xef\xbb\xbf",
+		`[0x1F, +1, .5, 5.]`,
+		`{"a":1,"a":2}`,
+		`{"a":{"b":[[{"c":null}]]}}`,
+		`{"outer":{"inner":{"deep":[[1]]}}}`,
+	)
+	flagSets := []ScannerFlag{0, ScannerFlagAllowComments, ScannerFlagTolerateBOM, ScannerFlagStrictRFC8259,
+		ScannerFlagAllowHexNumbers | ScannerFlagAllowJSON5Numbers, ScannerFlagLazyNumbers, ScannerFlagRejectJSUnsafe}
+	for _, input := range inputs {
+		for _, flags := range flagSets {
+			for _, opts := range [][]any{{flags}, {flags, ScannerMaxDepth(3)}, {flags, RejectDuplicateKeys{}, ScannerMaxValues(20)}} {
+				s := NewScanner([]byte(input), opts...)
+				want := SkipValue(s)
+				if want == nil {
+					want = s.Finalize()
+				}
+				for _, r := range []io.Reader{strings.NewReader(input), iotest.OneByteReader(strings.NewReader(input))} {
+					got := ValidateStream(r, opts...)
+					if (got == nil) != (want == nil) || got != nil && got.Error() != want.Error() {
+						t.Errorf("ValidateStream(%q, %v) error = %v, want %v", input, opts, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestValidateStreamComments(t *testing.T) {
+	input := "// one\n[1, /* two\n three */ 2 // four\n]\n// five"
+	var want, got []string
+	record := func(dst *[]string) CommentHandler {
+		return func(text string, line int) { *dst = append(*dst, fmt.Sprintf("%d:%s", line, text)) }
+	}
+	s := NewScanner([]byte(input), ScannerFlagAllowComments, record(&want))
+	if err := SkipValue(s); err != nil {
+		t.Fatalf("SkipValue() unexpected error = %v", err)
+	}
+	if err := s.Finalize(); err != nil {
+		t.Fatalf("Finalize() unexpected error = %v", err)
+	}
+	err := ValidateStream(iotest.OneByteReader(strings.NewReader(input)), ScannerFlagAllowComments, record(&got))
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateStream() comments = %q, %v, want %q", got, err, want)
+	}
+}
+
+func TestValidateStreamEarlyError(t *testing.T) {
+	// the reader fails after the first bad byte, which must be reported
+	// without reading further
+	testErr := errors.New("test error")
+	tests := []struct {
+		input   string
+		wantErr error
+	}{
+		{input: `{"a":[1,2}`, wantErr: ErrUnexpectedToken},
+		{input: `{"a" 1`, wantErr: ErrUnexpectedToken},
+		{input: `[1 2`, wantErr: ErrUnexpectedToken},
+		{input: `[1]x`, wantErr: ErrUnexpectedToken},
+		{input: `{"a":tx`, wantErr: ErrUnexpectedToken},
+		{input: `[1x`, wantErr: ErrInvalidNumber},
+		{input: "[\"a\x01", wantErr: ErrInvalidString},
+		{input: `["\q`, wantErr: ErrInvalidString},
+		{input: `{1`, wantErr: ErrUnexpectedToken},
+		{input: `[[[`, wantErr: ErrMaxDepthExceeded},
+		{input: `[1,2`, wantErr: testErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			for _, r := range []io.Reader{strings.NewReader(tt.input), iotest.OneByteReader(strings.NewReader(tt.input))} {
+				err := ValidateStream(io.MultiReader(r, iotest.ErrReader(testErr)), ScannerMaxDepth(2))
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ValidateStream() error = %v, want %v", err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateStreamBoundedMemory(t *testing.T) {
+	// many small values, and long tokens, which the buffer grows for
+	const n = 100000
+	input := "[" + strings.Repeat(`{"key":"value","n":[1.5,true,null]},`, n) + `"` + strings.Repeat("x", 50000) + `"]`
+	v := streamValidator{sr: streamReader{r: strings.NewReader(input)}, s: NewScanner(nil)}
+	if err := v.validate(); err != nil {
+		t.Fatalf("validate() unexpected error = %v", err)
+	}
+	if c := cap(v.sr.buf); c > 256<<10 {
+		t.Errorf("validate() buffer grew to %d bytes for %d bytes of input", c, len(input))
+	}
+}
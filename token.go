@@ -0,0 +1,166 @@
+package jsn
+
+import "unicode/utf8"
+
+// TokenKind identifies the kind of a token returned by Scanner.ReadRawToken
+type TokenKind int
+
+const (
+	TokenInvalid     TokenKind = iota // returned along with errors
+	TokenEOF                          // end of input, no text
+	TokenString                       // a string, including the quotes
+	TokenNumber                       // a number
+	TokenTrue                         // true
+	TokenFalse                        // false
+	TokenNull                         // null
+	TokenBeginObject                  // {
+	TokenEndObject                    // }
+	TokenBeginArray                   // [
+	TokenEndArray                     // ]
+	TokenColon                        // :
+	TokenComma                        // ,
+)
+
+var tokenKindNames = [...]string{
+	TokenInvalid:     "invalid",
+	TokenEOF:         "EOF",
+	TokenString:      "string",
+	TokenNumber:      "number",
+	TokenTrue:        "true",
+	TokenFalse:       "false",
+	TokenNull:        "null",
+	TokenBeginObject: "{",
+	TokenEndObject:   "}",
+	TokenBeginArray:  "[",
+	TokenEndArray:    "]",
+	TokenColon:       ":",
+	TokenComma:       ",",
+}
+
+func (k TokenKind) String() string {
+	if k >= 0 && int(k) < len(tokenKindNames) {
+		return tokenKindNames[k]
+	}
+	return "invalid"
+}
+
+// ReadRawToken skips whitespace and reads the next token, returning its kind
+// and its source text: a string with its quotes and escapes, the text of a
+// number, a literal, or a single delimiter. At the end of input it returns
+// TokenEOF with no text and no error.
+//
+// Tokens are validated as ReadValue validates them, the strict flags included,
+// but not decoded, and the text aliases the scanner's data, so reading a token
+// does not allocate. The grammar is not checked: tokens are returned in any
+// order, e.g. for highlighters and transforms that preserve the source.
+func (s *Scanner) ReadRawToken() (TokenKind, []byte, error) {
+	s.skipWhitespace()
+	if s.IsEOF() {
+		return TokenEOF, nil, nil
+	}
+	start := s.cur
+	var kind TokenKind
+	switch s.peek() {
+	case '"':
+		if err := s.scanString(); err != nil {
+			return TokenInvalid, nil, err
+		}
+		kind = TokenString
+	case '+', '.':
+		if s.flags&ScannerFlagAllowJSON5Numbers == 0 {
+			return TokenInvalid, nil, s.unexpectedToken()
+		}
+		fallthrough
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		if _, err := s.scanNumber(); err != nil {
+			return TokenInvalid, nil, err
+		}
+		kind = TokenNumber
+	case 't', 'f', 'n':
+		switch {
+		case s.skipSequence([]byte("true")):
+			kind = TokenTrue
+		case s.skipSequence([]byte("false")):
+			kind = TokenFalse
+		case s.skipSequence([]byte("null")):
+			kind = TokenNull
+		default:
+			return TokenInvalid, nil, ErrUnexpectedToken
+		}
+	case '{':
+		kind = TokenBeginObject
+	case '}':
+		kind = TokenEndObject
+	case '[':
+		kind = TokenBeginArray
+	case ']':
+		kind = TokenEndArray
+	case ':':
+		kind = TokenColon
+	case ',':
+		kind = TokenComma
+	default:
+		return TokenInvalid, nil, s.unexpectedToken()
+	}
+	if kind >= TokenBeginObject {
+		s.cur++ // delimiters are a single byte
+	}
+	return kind, s.data[start:s.cur], nil
+}
+
+// scanString validates the string at the current position and skips it,
+// without decoding its escape sequences, with the checks of parseString (see
+// ScannerFlagStrictSurrogates for instance). A string that runs into the end
+// of the data fails with ErrUnexpectedEOF.
+func (s *Scanner) scanString() error {
+	s.cur++ // opening quote
+	start := s.cur
+	for s.cur < len(s.data) {
+		c := s.data[s.cur]
+		switch {
+		case c <= 0x1F:
+			return ErrInvalidString
+		case c == '"':
+			if s.flags&ScannerFlagStrictUTF8 != 0 && !utf8.Valid(s.data[start:s.cur]) {
+				return ErrInvalidString
+			}
+			if s.flags&ScannerFlagRejectJSUnsafe != 0 {
+				if i := jsUnsafeIndex(s.data[start:s.cur]); i >= 0 {
+					s.cur = start + i
+					return ErrJSUnsafeString
+				}
+			}
+			s.cur++
+			return nil
+		case c == '\\':
+			if s.cur+1 >= len(s.data) {
+				s.cur = len(s.data)
+				return ErrUnexpectedEOF
+			}
+			switch s.data[s.cur+1] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+				s.cur += 2
+			case 'u':
+				s.cur += 2
+				if _, err := s.parseUnicodeEscape(); err != nil {
+					return err
+				}
+			default:
+				return ErrInvalidString
+			}
+		default:
+			s.cur++
+		}
+	}
+	return ErrUnexpectedEOF
+}
+
+// isHex reports whether b consists of hexadecimal digits
+func isHex(b []byte) bool {
+	for _, c := range b {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
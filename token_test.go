@@ -0,0 +1,93 @@
+package jsn
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadRawToken(t *testing.T) {
+	type token struct {
+		kind TokenKind
+		text string
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		opts    []any
+		want    []token
+		wantErr error
+	}{
+		{name: "empty", input: " ", want: nil},
+		{name: "document", input: ` {"a" : [1, -2.5e3, true, false, null]} `, want: []token{
+			{TokenBeginObject, "{"}, {TokenString, `"a"`}, {TokenColon, ":"}, {TokenBeginArray, "["},
+			{TokenNumber, "1"}, {TokenComma, ","}, {TokenNumber, "-2.5e3"}, {TokenComma, ","},
+			{TokenTrue, "true"}, {TokenComma, ","}, {TokenFalse, "false"}, {TokenComma, ","},
+			{TokenNull, "null"}, {TokenEndArray, "]"}, {TokenEndObject, "}"},
+		}},
+		{name: "escapes kept", input: `"a\\ \"\u00e9\n"`, want: []token{{TokenString, `"a\\ \"\u00e9\n"`}}},
+		{name: "grammar not checked", input: `] : "x" "y"`, want: []token{
+			{TokenEndArray, "]"}, {TokenColon, ":"}, {TokenString, `"x"`}, {TokenString, `"y"`},
+		}},
+		{name: "unterminated string", input: `"abc`, wantErr: ErrUnexpectedEOF},
+		{name: "escaped quote at end", input: `"abc\"`, wantErr: ErrUnexpectedEOF},
+		{name: "invalid escape", input: `"\x"`, wantErr: ErrInvalidString},
+		{name: "invalid unicode escape", input: `"\u12g4"`, wantErr: ErrInvalidUnicodeEscape},
+		{name: "short unicode escape", input: `"\u12"`, wantErr: ErrInvalidUnicodeEscape},
+		{name: "control character", input: "\"a\tb\"", wantErr: ErrInvalidString},
+		{name: "lone surrogate", input: `"\ud800"`, want: []token{{TokenString, `"\ud800"`}}},
+		{name: "strict surrogate pair", input: `"\ud83d\ude00"`, opts: []any{ScannerFlagStrictSurrogates},
+			want: []token{{TokenString, `"\ud83d\ude00"`}}},
+		{name: "strict lone surrogate", input: `"\ud800"`, opts: []any{ScannerFlagStrictSurrogates}, wantErr: ErrInvalidUnicodeEscape},
+		{name: "strict unpaired surrogate", input: `"\ud800\u0041"`, opts: []any{ScannerFlagStrictRFC8259}, wantErr: ErrInvalidUnicodeEscape},
+		{name: "strict invalid UTF-8", input: "\"\xff\"", opts: []any{ScannerFlagStrictRFC8259}, wantErr: ErrInvalidString},
+		{name: "invalid number", input: `[01]`, want: []token{{TokenBeginArray, "["}}, wantErr: ErrInvalidNumber},
+		{name: "invalid literal", input: `nul`, wantErr: ErrUnexpectedToken},
+		{name: "invalid character", input: `1 @`, want: []token{{TokenNumber, "1"}}, wantErr: ErrUnexpectedToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner([]byte(tt.input), tt.opts...)
+			var got []token
+			var err error
+			for {
+				var kind TokenKind
+				var text []byte
+				kind, text, err = s.ReadRawToken()
+				if err != nil {
+					if kind != TokenInvalid || text != nil {
+						t.Errorf("ReadRawToken() = %v, %q along with error %v", kind, text, err)
+					}
+					break
+				}
+				if kind == TokenEOF {
+					break
+				}
+				got = append(got, token{kind, string(text)})
+			}
+			if err != tt.wantErr {
+				t.Errorf("ReadRawToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadRawToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadRawTokenAllocs(t *testing.T) {
+	data := []byte(`{"a": ["x\ny", 1.5, true, null]}`)
+	allocs := testing.AllocsPerRun(100, func() {
+		s := Scanner{data: data}
+		for {
+			kind, _, err := s.ReadRawToken()
+			if err != nil || kind == TokenEOF {
+				break
+			}
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("ReadRawToken() allocated %v times, want 0", allocs)
+	}
+}
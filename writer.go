@@ -1,8 +1,10 @@
 package jsn
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 )
 
@@ -20,20 +22,55 @@ type ArrMarshaler interface {
 
 // ObjMarshaler is implemented by types that can marshal themselves into a JSON object.
 // This interface provides full control over the object's JSON representation.
+//
+// All marshaler interfaces share the MarshalJSN method name, so a type can
+// implement only one of them and the kind of the output is fixed by its type.
+// Embedding two types with MarshalJSN methods at the same depth cancels both
+// out, the struct then implements none. A type can, however, implement one of
+// them and encoding.TextMarshaler, or implement different interfaces with its
+// value and pointer receivers. The interface with the highest precedence wins,
+// in the order ObjMarshaler, ArrMarshaler, StrMarshaler, json.Marshaler,
+// encoding.TextMarshaler, regardless of the receivers.
 type ObjMarshaler interface {
 	MarshalJSN(w ObjectWriter) error
 }
 
-// ArrayWriter defines the interface for writing JSON arrays
+// ArrayWriter defines the interface for writing JSON arrays.
+//
+// Once marshaling has failed (a nested value returned an error or the output
+// could not be written), further calls are ignored, the output produced so far
+// is a well-formed prefix of the intended JSON text.
 type ArrayWriter interface {
 	// Element writes supported value as an array element.
 	Element(v any)
+
+	// Elements writes each of the values as an array element, in order.
+	Elements(vs []any)
+
+	// Context returns the context passed to MarshalContext, or
+	// context.Background() for the other marshaling functions.
+	Context() context.Context
 }
 
-// ObjectWriter defines the interface for writing JSON objects
+// ObjectWriter defines the interface for writing JSON objects, errors are
+// handled the same way as in ArrayWriter.
 type ObjectWriter interface {
 	// Member writes a key-value pair as an object member.
 	Member(key string, v any)
+
+	// Members writes each of the pairs as an object member, in order.
+	Members(pairs []KV)
+
+	// Context returns the context passed to MarshalContext, or
+	// context.Background() for the other marshaling functions.
+	Context() context.Context
+}
+
+// KV is a key-value pair for ObjectWriter.Members, a slice of pairs keeps the
+// members in a fixed order, unlike a map.
+type KV struct {
+	Key   string
+	Value any
 }
 
 // arrayWriter is the implementation of ArrayWriter interface
@@ -44,60 +81,442 @@ type arrayWriter struct {
 
 // Value writes supported value as an array element.
 func (w *arrayWriter) Element(v any) {
+	if w.d.hadError() || w.d.canceled() {
+		return
+	}
 	w.d.arrayElement(w.elementCounter == 0)
 	w.elementCounter++
 	w.d.marshalValue(v)
 }
 
+// Elements writes each of the values as an array element.
+func (w *arrayWriter) Elements(vs []any) {
+	for _, v := range vs {
+		w.Element(v)
+	}
+}
+
+// Context returns the marshaling context.
+func (w *arrayWriter) Context() context.Context {
+	return w.d.context()
+}
+
 // objectWriter is used to marshal objects into JSON.
 type objectWriter struct {
 	d            *decorator
 	fieldCounter int
+	buffered     []bufferedMember // members held back for sorting
+}
+
+// bufferedMember is an object member with its value already marshaled
+type bufferedMember struct {
+	key  string
+	text string
 }
 
 // Value writes any Go value as an array element.
 func (w *objectWriter) Member(key string, v any) {
+	if w.d.hadError() || w.d.canceled() || (w.d.omitNulls && isNull(reflect.ValueOf(v))) {
+		return
+	}
+	if w.d.sortObjectKeys {
+		w.fieldCounter++
+		var sb strings.Builder
+		sub := decorator{out: &sb, marshalOptions: w.d.marshalOptions, ctx: w.d.ctx, depth: w.d.depth + 1}
+		sub.marshalValue(v)
+		if sub.err != nil {
+			w.d.handleError(sub.err)
+			return
+		}
+		w.buffered = append(w.buffered, bufferedMember{key: key, text: sb.String()})
+		return
+	}
 	w.d.objectField(key, w.fieldCounter == 0)
 	w.fieldCounter++
 	w.d.marshalValue(v)
 }
 
+// Members writes each of the pairs as an object member.
+func (w *objectWriter) Members(pairs []KV) {
+	for _, p := range pairs {
+		w.Member(p.Key, p.Value)
+	}
+}
+
+// Context returns the marshaling context.
+func (w *objectWriter) Context() context.Context {
+	return w.d.context()
+}
+
+// end emits the buffered members, if any, and closes the object
+func (w *objectWriter) end() {
+	if len(w.buffered) != 0 {
+		sort.SliceStable(w.buffered, func(i, j int) bool { return w.d.lessKey(w.buffered[i].key, w.buffered[j].key) })
+		for i, m := range w.buffered {
+			w.d.objectField(m.key, i == 0)
+			w.d.put(m.text)
+		}
+		w.buffered = nil
+	}
+	w.d.objectEnd(w.fieldCounter == 0)
+}
+
 // FloatPrecision specifies the number of decimal places to use when formatting floating-point numbers
 type FloatPrecision struct {
 	Precision int
 }
 
-func parseMarshalOptions(opts []any) (precision int, err error) {
-	precision = 6
+// Precise is a float64 that marshals with its own precision, in place of
+// FloatPrecision (and of the shortest formatting of PreserveNumbers), e.g. to
+// keep one field exact among coarse display values. A negative Precision
+// selects the shortest formatting that parses back to the same value.
+// IntegralFloats still applies.
+type Precise struct {
+	Value     float64
+	Precision int
+}
+
+// Quoted marshals a number or boolean Value as a JSON string holding its usual
+// text, e.g. "9007199254740993" or "true", like the `,string` struct tag
+// modifier of encoding/json. Use it for single members written through
+// ObjectWriter, Decode reads them back into fields tagged `jsn:"name,string"`.
+// A nil Value marshals as null, other kinds fail with *UnsupportedTypeError.
+type Quoted struct {
+	Value any
+}
+
+// NumbersAsStrings makes all numbers marshal as JSON strings holding their
+// text, e.g. "42" or "1.5", for JavaScript consumers that would lose the
+// precision of integers beyond 2^53. It applies to integers, unsigned integers
+// and floats alike, including Number, Precise and time.Duration values, after
+// they are formatted as usual (see FloatPrecision and IntegralFloats).
+// Infinities and NaN are represented as selected by NonFiniteFloats, map keys
+// are not affected.
+type NumbersAsStrings struct{}
+
+// NonFiniteFloats selects how infinities and NaN, which have no JSON
+// representation, are marshaled
+type NonFiniteFloats struct {
+	Mode NonFiniteMode
+}
+
+// NonFiniteMode is the representation of infinities and NaN, see
+// NonFiniteFloats
+type NonFiniteMode int
+
+const (
+	NonFiniteError   NonFiniteMode = iota // fail with an error (the default)
+	NonFiniteNull                         // null
+	NonFiniteString                       // "Infinity", "-Infinity" or "NaN"
+	NonFiniteLiteral                      // bare Infinity, -Infinity or NaN, as in JSON5, not valid JSON
+)
+
+// IntegralFloats makes floating-point numbers that have no fractional part
+// marshal as plain integers (e.g. 1e15 becomes 1000000000000000) as long as
+// they fit into int64; other values, such as 1e20, use the regular float
+// formatting
+type IntegralFloats struct{}
+
+// EmptyStructAsObject makes structs that have no exported fields and do not
+// implement any of the marshaler interfaces marshal as an empty JSON object
+// instead of failing with UnsupportedTypeError
+type EmptyStructAsObject struct{}
+
+// SortObjectWriterKeys makes members written through ObjectWriter (by
+// ObjMarshaler implementations and func(ObjectWriter) callbacks) come out
+// sorted by key, like the members of Go maps. This produces canonical output
+// at the cost of buffering each object's members in memory until the object
+// is complete. Members with duplicate keys are all kept, in call order.
+type SortObjectWriterKeys struct{}
+
+// PreserveNumbers makes Number values (see ScannerFlagUseNumber) marshal
+// verbatim and float64 values use the shortest formatting that parses back to
+// the same value, ignoring FloatPrecision. Together with ScannerFlagUseNumber
+// this reproduces the numbers of the source document exactly.
+type PreserveNumbers struct{}
+
+// ErrorsAsString makes values implementing the error interface marshal as a
+// JSON string holding their Error() message, which is convenient for logging
+// structured events. Marshaler interfaces implemented by the value take
+// precedence.
+type ErrorsAsString struct{}
+
+// TrailingNewline appends a single newline after the marshaled value, so that
+// generated files follow the POSIX text file convention
+type TrailingNewline struct{}
+
+// OmitNullMembers makes map entries and ObjectWriter members whose value would
+// marshal as null (nil, or a nil pointer or interface) be left out of the
+// output, which is more compact for sparse structures. By default such members
+// are written as explicit nulls, preserving the round-trip semantics. Array
+// elements are not affected.
+type OmitNullMembers struct{}
+
+// EscapeNonPrintable makes strings and keys escape DEL (U+007F) and every rune
+// that is not printable according to unicode.IsPrint, such as zero-width and
+// other format characters, line and paragraph separators and non-ASCII spaces,
+// in addition to the control characters that JSON requires to be escaped. The
+// output is then safe to paste into terminals and logs. Runes outside the BMP
+// are escaped as UTF-16 surrogate pairs, invalid UTF-8 is written as is.
+type EscapeNonPrintable struct{}
+
+// EscapeNonASCII makes strings and keys escape every non-ASCII rune as \uXXXX,
+// runes outside the BMP as a UTF-16 surrogate pair (e.g. U+1D11E becomes
+// \ud834\udd1e), so that the output is pure ASCII. Invalid UTF-8 is written as
+// \ufffd. It can be combined with EscapeNonPrintable to escape DEL as well.
+type EscapeNonASCII struct{}
+
+// DurationAsString makes time.Duration values marshal as a JSON string in the
+// format of Duration.String (e.g. "1h30m0s"). By default, as an int64, a
+// duration marshals as its number of nanoseconds.
+type DurationAsString struct{}
+
+// UnixTime is an option for Marshal and Decode that represents time.Time
+// values as Unix timestamps: numbers of seconds since the epoch, or of
+// milliseconds with Millis, as many APIs send them. Marshal writes the
+// sub-second part as a fraction, e.g. 1700000000.25, Decode reads fractions
+// down to the nanosecond and returns times in UTC. Decode still accepts
+// RFC 3339 strings. The `jsn:"name,unix"` and `jsn:"name,unixmilli"` tag
+// modifiers enable the same for a single field when decoding.
+type UnixTime struct {
+	Millis bool
+}
+
+// unixUnit is the unit of a Unix timestamp, see UnixTime
+type unixUnit int
+
+const (
+	unixNone unixUnit = iota // not a timestamp
+	unixSeconds
+	unixMillis
+)
+
+func (u UnixTime) unit() unixUnit {
+	if u.Millis {
+		return unixMillis
+	}
+	return unixSeconds
+}
+
+// ComplexAsObject makes complex64 and complex128 values marshal as an object
+// with "real" and "imag" members, formatted like floats (see FloatPrecision).
+// Without it complex numbers, which have no JSON representation, fail with
+// *UnsupportedTypeError. Decode reverses the mapping for complex targets.
+type ComplexAsObject struct{}
+
+// BytesAsArray makes byte slices and arrays marshal as arrays of numbers, e.g.
+// [1,2,3], instead of strings holding the bytes as text. It applies to slices
+// and arrays of named byte types too (e.g. []Level with type Level uint8),
+// which are otherwise marshaled like []byte, unless the element type has a
+// marshaler, in which case they are arrays of the marshaled elements anyway.
+type BytesAsArray struct{}
+
+// Indent makes the output span multiple lines, with each array element and
+// object member on its own line, indented by one copy of the string per
+// nesting level, and a space after the colon of each member. Empty arrays and
+// objects stay on one line. See also MarshalIndent.
+type Indent string
+
+// IndentFunc is like Indent, but calls the function for the indentation of
+// each line, with the nesting depth of the line: 1 for the elements and
+// members of the top-level value, 0 for its closing bracket. It takes
+// precedence over Indent.
+type IndentFunc func(depth int) string
+
+// TrailingCommas puts a comma after the last element of every array and the
+// last member of every object, e.g. for templates that are edited by hand,
+// where lines are then added and reordered without fixing up commas.
+//
+// The output is NOT valid JSON (RFC 8259), most parsers reject it, including
+// this package's Scanner. The option only applies to indented output (see
+// Indent and MarshalIndent), compact output is unaffected.
+type TrailingCommas struct{}
+
+// KeyComparator replaces the byte-wise ascending order of the keys of Go maps,
+// and of the members sorted with SortObjectWriterKeys, e.g. for natural
+// ordering where "item2" comes before "item10". The function reports whether
+// key a sorts before key b, it must define a strict weak ordering. Keys that
+// compare equal are kept in an unspecified order for maps, and in call order
+// for ObjectWriter members.
+type KeyComparator func(a, b string) bool
+
+// ByteKeys makes maps keyed by byte arrays ([N]byte, e.g. hashes, and named
+// types of them) marshal as objects, with each key encoded as a string. The
+// keys are sorted by their encoded form. Without it such maps fail with
+// *UnsupportedTypeError. Byte slices are not comparable, so they cannot key a
+// map.
+type ByteKeys struct {
+	Encoding ByteKeyEncoding // ByteKeysHex by default
+}
+
+// ByteKeyEncoding is the string encoding of byte array keys, see ByteKeys
+type ByteKeyEncoding int
+
+const (
+	ByteKeysHex    ByteKeyEncoding = iota // lowercase hexadecimal
+	ByteKeysBase64                        // standard base64 with padding
+)
+
+// marshalOptions holds the settings collected from the options passed to Marshal
+type marshalOptions struct {
+	floatPrecision int           // Precision used when formatting floating-point numbers
+	integralFloats bool          // Format whole floats within int64 range as integers
+	nonFinite      NonFiniteMode // Representation of infinities and NaN
+	numbersAsStr   bool          // Quote all numbers
+	emptyStructObj bool          // Marshal structs without exported fields as {}
+	sortObjectKeys bool          // Buffer and sort members written through ObjectWriter
+	preserveNums   bool          // Emit Number verbatim and floats in shortest form
+	errorsAsString bool          // Marshal error values as their message
+	trailingNL     bool          // Append a newline after the top-level value
+	omitNulls      bool          // Skip map entries and object members that are null
+	escapeNonPrint bool          // Escape DEL and non-printable runes in strings
+	escapeNonASCII bool          // Escape all non-ASCII runes in strings
+	durationAsStr  bool          // Marshal time.Duration as its String()
+	unixTime       unixUnit      // Marshal time.Time as a Unix timestamp
+	complexAsObj   bool          // Marshal complex numbers as {"real":..,"imag":..}
+	bytesAsArr     bool          // Marshal byte slices and arrays as arrays of numbers
+	indent         string        // Indentation per nesting level, empty for compact output
+	indentFunc     IndentFunc    // Indentation for a given depth, overrides indent
+	keyLess        KeyComparator // Order of sorted keys, byte-wise if nil
+	trailingCommas bool          // Comma after the last element and member, indented output only
+	byteKeys       bool          // Marshal maps keyed by byte arrays
+	byteKeyEnc     ByteKeyEncoding
+}
+
+func parseMarshalOptions(opts []any) (mo marshalOptions, err error) {
+	mo.floatPrecision = 6
 
 	for _, opt := range opts {
 		switch v := opt.(type) {
 		case FloatPrecision:
 			if v.Precision < 0 {
-				return 0, fmt.Errorf("invalid float precision: %d", v.Precision)
+				return mo, fmt.Errorf("invalid float precision: %d", v.Precision)
+			}
+			mo.floatPrecision = v.Precision
+		case NonFiniteFloats:
+			if v.Mode < NonFiniteError || v.Mode > NonFiniteLiteral {
+				return mo, fmt.Errorf("invalid non-finite float mode: %d", v.Mode)
 			}
-			precision = v.Precision
+			mo.nonFinite = v.Mode
+		case IntegralFloats:
+			mo.integralFloats = true
+		case NumbersAsStrings:
+			mo.numbersAsStr = true
+		case EmptyStructAsObject:
+			mo.emptyStructObj = true
+		case SortObjectWriterKeys:
+			mo.sortObjectKeys = true
+		case PreserveNumbers:
+			mo.preserveNums = true
+		case ErrorsAsString:
+			mo.errorsAsString = true
+		case TrailingNewline:
+			mo.trailingNL = true
+		case OmitNullMembers:
+			mo.omitNulls = true
+		case EscapeNonPrintable:
+			mo.escapeNonPrint = true
+		case EscapeNonASCII:
+			mo.escapeNonASCII = true
+		case DurationAsString:
+			mo.durationAsStr = true
+		case UnixTime:
+			mo.unixTime = v.unit()
+		case ComplexAsObject:
+			mo.complexAsObj = true
+		case BytesAsArray:
+			mo.bytesAsArr = true
+		case Indent:
+			mo.indent = string(v)
+		case IndentFunc:
+			mo.indentFunc = v
+		case TrailingCommas:
+			mo.trailingCommas = true
+		case KeyComparator:
+			mo.keyLess = v
+		case ByteKeys:
+			if v.Encoding != ByteKeysHex && v.Encoding != ByteKeysBase64 {
+				return mo, fmt.Errorf("invalid byte key encoding: %d", v.Encoding)
+			}
+			mo.byteKeys = true
+			mo.byteKeyEnc = v.Encoding
 		}
 	}
-	return precision, nil
+	return mo, nil
 }
 
 // Marshal marshals any supported value into a JSON string.
+//
+// Named types without a marshaler (e.g. type Status string) are marshaled as
+// their underlying kind, fmt.Stringer is not taken into account. The types of
+// sync/atomic (atomic.Int64, atomic.Bool, atomic.Value, etc.) marshal as their
+// loaded value.
 func Marshal(v any, opts ...any) (string, error) {
-	precision, err := parseMarshalOptions(opts)
+	return marshal(context.Background(), v, opts)
+}
+
+// MarshalIndent is like Marshal with the Indent option: each element and member
+// goes on its own line, indented by indent per nesting level.
+func MarshalIndent(v any, indent string, opts ...any) (string, error) {
+	return marshal(context.Background(), v, append([]any{Indent(indent)}, opts...))
+}
+
+// MarshalContext is like Marshal, but stops with the context's error once ctx
+// is done. The context is checked before each array element and object member
+// is written, and is available to marshalers and callbacks through the
+// Context method of ArrayWriter and ObjectWriter, so that expensive member
+// computations (e.g. streaming database rows) can be abandoned as well.
+func MarshalContext(ctx context.Context, v any, opts ...any) (string, error) {
+	return marshal(ctx, v, opts)
+}
+
+func marshal(ctx context.Context, v any, opts []any) (string, error) {
+	mo, err := parseMarshalOptions(opts)
 	if err != nil {
 		return "", err
 	}
 
 	striungBuilder := strings.Builder{}
-	d := decorator{out: &striungBuilder, floatPrecision: precision}
-	d.marshalValue(v)
+	d := decorator{out: &striungBuilder, marshalOptions: mo, ctx: ctx}
+	d.marshalDocument(v)
 	if d.err != nil {
 		return "", d.err
 	}
 	return striungBuilder.String(), nil
 }
 
+// EstimateSize returns the length in bytes of the JSON text Marshal would
+// produce for v with the same options, without keeping the output. This allows
+// sizing buffers or rejecting oversized payloads before encoding them.
+//
+// The value is marshaled into a counting writer, so escaping is fully
+// accounted for and the result is exact rather than an upper bound. Note that
+// marshalers and callbacks are invoked, just as with Marshal.
+func EstimateSize(v any, opts ...any) (int, error) {
+	mo, err := parseMarshalOptions(opts)
+	if err != nil {
+		return 0, err
+	}
+
+	var cw countingWriter
+	d := decorator{out: &cw, marshalOptions: mo}
+	d.marshalDocument(v)
+	if d.err != nil {
+		return 0, d.err
+	}
+	return cw.n, nil
+}
+
+// countingWriter discards the data written to it, keeping only its length
+type countingWriter struct {
+	n int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += len(p)
+	return len(p), nil
+}
+
 // UnsupportedTypeError is returned when marshaling encounters a type
 // that cannot be converted into JSON.
 type UnsupportedTypeError struct {
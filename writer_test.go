@@ -1,9 +1,18 @@
 package jsn
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+	"unicode/utf8"
 )
 
 func ExampleMarshal_primitives() {
@@ -352,6 +361,87 @@ func TestMarshalSlice(t *testing.T) {
 	}
 }
 
+type namedByte byte
+
+func TestMarshalFixedArrays(t *testing.T) {
+	bytes := [4]byte{'a', 'b', 'c', 'd'}
+	named := [3]namedByte{'x', 'y', 'z'}
+	nested := [2][2]byte{{'a', 'b'}, {'c', 'd'}}
+	tests := []struct {
+		name  string
+		input any
+		want  string
+	}{
+		{name: "byte array by value", input: bytes, want: `"abcd"`},
+		{name: "byte array via pointer", input: &bytes, want: `"abcd"`},
+		{name: "named byte array by value", input: named, want: `"xyz"`},
+		{name: "named byte array via pointer", input: &named, want: `"xyz"`},
+		{name: "named byte slice", input: []namedByte{'q'}, want: `"q"`},
+		{name: "empty byte array", input: [0]byte{}, want: `""`},
+		{name: "string array", input: [3]string{"a", "b", "c"}, want: `["a","b","c"]`},
+		{name: "empty int array", input: [0]int{}, want: `[]`},
+		{name: "two-dimensional array", input: [2][3]int{{1, 2, 3}, {4, 5, 6}}, want: `[[1,2,3],[4,5,6]]`},
+		{name: "array of byte arrays", input: nested, want: `["ab","cd"]`},
+		{name: "array of byte arrays via pointer", input: &nested, want: `["ab","cd"]`},
+		{name: "slice of byte arrays", input: [][2]byte{{'e', 'f'}}, want: `["ef"]`},
+		{name: "map of byte arrays", input: map[string][2]byte{"k": {'g', 'h'}}, want: `{"k":"gh"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input)
+			if err != nil {
+				t.Fatalf("Marshal() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// levelByte is a numeric byte with a marshaler of its own
+type levelByte uint8
+
+func (l levelByte) MarshalText() ([]byte, error) { return []byte{'L', '0' + byte(l)}, nil }
+
+// flagByte has a marshaler with a pointer receiver
+type flagByte uint8
+
+func (f *flagByte) MarshalJSON() ([]byte, error) { return []byte(strconv.Itoa(int(*f) * 10)), nil }
+
+func TestMarshalByteSlices(t *testing.T) {
+	tests := []struct {
+		name  string
+		input any
+		opts  []any
+		want  string
+	}{
+		{name: "bytes", input: []byte("ab"), want: `"ab"`},
+		{name: "named bytes", input: []namedByte{'a', 'b'}, want: `"ab"`},
+		{name: "element marshaler", input: []levelByte{1, 2}, want: `["L1","L2"]`},
+		{name: "element marshaler array", input: [2]levelByte{3, 4}, want: `["L3","L4"]`},
+		{name: "pointer receiver marshaler", input: []flagByte{1, 2}, want: `[10,20]`},
+		{name: "bytes as array", input: []byte("ab"), opts: []any{BytesAsArray{}}, want: `[97,98]`},
+		{name: "named bytes as array", input: []namedByte{1}, opts: []any{BytesAsArray{}}, want: `[1]`},
+		{name: "byte array as array", input: [3]byte{1, 2, 3}, opts: []any{BytesAsArray{}}, want: `[1,2,3]`},
+		{name: "empty bytes as array", input: []byte{}, opts: []any{BytesAsArray{}}, want: `[]`},
+		{name: "marshaler as array", input: []levelByte{5}, opts: []any{BytesAsArray{}}, want: `["L5"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, tt.opts...)
+			if err != nil {
+				t.Fatalf("Marshal() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestMarshalUnsupportedTypes(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -810,3 +900,1248 @@ func TestMarshalEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestMarshalIntegralFloats(t *testing.T) {
+	tests := []struct {
+		name  string
+		input any
+		want  string
+	}{
+		{name: "small whole number", input: 42.0, want: "42"},
+		{name: "negative whole number", input: -42.0, want: "-42"},
+		{name: "large whole number", input: 1e20 / 1e5, want: "1000000000000000"},
+		{name: "beyond default precision", input: 1234567.0, want: "1234567"},
+		{name: "negative zero", input: math.Copysign(0, -1), want: "0"},
+		{name: "float32", input: float32(16777216), want: "16777216"},
+		{name: "fraction", input: 3.5, want: "3.5"},
+		{name: "beyond int64", input: 1e20, want: "1e+20"},
+		{name: "min int64", input: float64(math.MinInt64), want: "-9223372036854775808"},
+		{name: "in array", input: []float64{1, 2.5, 3}, want: "[1,2.5,3]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, IntegralFloats{})
+			if err != nil {
+				t.Errorf("Marshal() unexpected error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type namedID int64
+type namedStatus string
+type namedFlag bool
+type namedCelsius float64
+type namedLevel uint8
+
+// namedColor implements fmt.Stringer, which must not affect marshaling
+type namedColor int
+
+func (c namedColor) String() string { return "color" }
+
+func TestMarshalNamedPrimitives(t *testing.T) {
+	tests := []struct {
+		name  string
+		input any
+		want  string
+	}{
+		{name: "named int64", input: namedID(9007199254740993), want: "9007199254740993"},
+		{name: "named string", input: namedStatus("active"), want: `"active"`},
+		{name: "named bool", input: namedFlag(true), want: "true"},
+		{name: "named float64", input: namedCelsius(36.6), want: "36.6"},
+		{name: "named uint8", input: namedLevel(7), want: "7"},
+		{name: "named Stringer", input: namedColor(2), want: "2"},
+		{name: "pointer to named", input: func() *namedStatus { s := namedStatus("ptr"); return &s }(), want: `"ptr"`},
+		{name: "slice of named", input: []namedFlag{true, false}, want: "[true,false]"},
+		{name: "map of named", input: map[string]namedID{"a": 1}, want: `{"a":1}`},
+		{name: "named values in any", input: []any{namedID(1), namedStatus("s"), namedColor(3)}, want: `[1,"s",3]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input)
+			if err != nil {
+				t.Errorf("Marshal() unexpected error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalEmptyStructAsObject(t *testing.T) {
+	type marker struct{}
+	type private struct {
+		id int
+	}
+	type public struct {
+		ID int
+	}
+
+	tests := []struct {
+		name    string
+		input   any
+		opts    []any
+		want    string
+		wantErr bool
+	}{
+		{name: "empty struct without option", input: struct{}{}, wantErr: true},
+		{name: "empty struct", input: struct{}{}, opts: []any{EmptyStructAsObject{}}, want: "{}"},
+		{name: "named marker", input: marker{}, opts: []any{EmptyStructAsObject{}}, want: "{}"},
+		{name: "unexported fields only", input: private{id: 1}, opts: []any{EmptyStructAsObject{}}, want: "{}"},
+		{name: "pointer to marker", input: &marker{}, opts: []any{EmptyStructAsObject{}}, want: "{}"},
+		{
+			name:  "marker inside structure",
+			input: map[string]any{"a": marker{}, "b": []any{marker{}, 1}},
+			opts:  []any{EmptyStructAsObject{}},
+			want:  `{"a":{},"b":[{},1]}`,
+		},
+		{name: "exported fields", input: public{ID: 1}, opts: []any{EmptyStructAsObject{}}, wantErr: true},
+		{name: "marshaler takes precedence", input: customObjMarshaler{name: "x"}, opts: []any{EmptyStructAsObject{}}, want: `{"name":"x","value":0}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Marshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("Marshal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalSortObjectWriterKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		input any
+		want  string
+	}{
+		{
+			name:  "obj marshaler",
+			input: customObjMarshaler{name: "test", value: 42},
+			want:  `{"name":"test","value":42}`,
+		},
+		{
+			name: "functional object",
+			input: func(w ObjectWriter) {
+				w.Member("zeta", 1)
+				w.Member("alpha", 2)
+				w.Member("mid", 3)
+			},
+			want: `{"alpha":2,"mid":3,"zeta":1}`,
+		},
+		{
+			name: "nested objects",
+			input: func(w ObjectWriter) error {
+				w.Member("b", func(w ObjectWriter) error {
+					w.Member("y", []int{1})
+					w.Member("x", map[string]int{"q": 1, "p": 2})
+					return nil
+				})
+				w.Member("a", recursiveObj{name: "n", children: nil})
+				return nil
+			},
+			want: `{"a":{"children":null,"name":"n"},"b":{"x":{"p":2,"q":1},"y":[1]}}`,
+		},
+		{
+			name: "duplicate keys keep call order",
+			input: func(w ObjectWriter) {
+				w.Member("b", 1)
+				w.Member("a", 2)
+				w.Member("b", 3)
+			},
+			want: `{"a":2,"b":1,"b":3}`,
+		},
+		{
+			name:  "empty object",
+			input: func(w ObjectWriter) {},
+			want:  `{}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, SortObjectWriterKeys{})
+			if err != nil {
+				t.Errorf("Marshal() unexpected error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	customErr := errors.New("member error")
+	_, err := Marshal(func(w ObjectWriter) {
+		w.Member("a", 1)
+		w.Member("b", errorStrMarshaler{err: customErr})
+	}, SortObjectWriterKeys{})
+	if err != customErr {
+		t.Errorf("Marshal() error = %v, want %v", err, customErr)
+	}
+}
+
+type codeError string
+
+func (e codeError) Error() string { return "code " + string(e) }
+
+type pathError struct{ path string }
+
+func (e *pathError) Error() string { return "bad path " + e.path }
+
+type marshaledError struct{}
+
+func (marshaledError) Error() string               { return "message" }
+func (marshaledError) MarshalJSN() (string, error) { return "custom", nil }
+
+func TestMarshalErrorsAsString(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   any
+		want    string
+		wantErr bool
+	}{
+		{name: "errors.New", input: errors.New("boom"), want: `"boom"`},
+		{name: "wrapped", input: fmt.Errorf("read: %w", errors.New("eof")), want: `"read: eof"`},
+		{name: "named string", input: codeError("42"), want: `"code 42"`},
+		{name: "pointer receiver", input: &pathError{path: "/a"}, want: `"bad path /a"`},
+		{name: "marshaler wins", input: marshaledError{}, want: `"custom"`},
+		{name: "nil error", input: map[string]error{"err": nil}, want: `{"err":null}`},
+		{name: "in event", input: map[string]any{"msg": "failed", "err": errors.New("x\ny")}, want: `{"err":"x\ny","msg":"failed"}`},
+		{name: "in slice", input: []error{codeError("1"), &pathError{path: "p"}}, want: `["code 1","bad path p"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, ErrorsAsString{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Marshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// without the option errors are marshaled by their kind
+	if _, err := Marshal(errors.New("boom")); err == nil {
+		t.Error("Marshal() of an error without ErrorsAsString expected an error")
+	}
+	if got, _ := Marshal(codeError("42")); got != `"42"` {
+		t.Errorf("Marshal() = %v, want %v", got, `"42"`)
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	tests := []struct {
+		name  string
+		input any
+		opts  []any
+	}{
+		{name: "null", input: nil},
+		{name: "number", input: 3.14159, opts: []any{FloatPrecision{Precision: 2}}},
+		{name: "escapes", input: "line\nbreak \"quoted\" \x01 é"},
+		{name: "nested", input: map[string]any{"a": []any{1, "two", true, nil}, "b\t": map[string]int{"x": 1}}},
+		{name: "functional", input: func(w ObjectWriter) {
+			w.Member("z", []string{"a", "b"})
+			w.Member("a", 1)
+		}},
+		{name: "sorted", input: func(w ObjectWriter) {
+			w.Member("z", 1)
+			w.Member("a", 2)
+		}, opts: []any{SortObjectWriterKeys{}}},
+		{name: "empty", input: []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := Marshal(tt.input, tt.opts...)
+			if err != nil {
+				t.Fatalf("Marshal() unexpected error = %v", err)
+			}
+			got, err := EstimateSize(tt.input, tt.opts...)
+			if err != nil {
+				t.Fatalf("EstimateSize() unexpected error = %v", err)
+			}
+			if got != len(want) {
+				t.Errorf("EstimateSize() = %d, want %d", got, len(want))
+			}
+		})
+	}
+
+	if _, err := EstimateSize(make(chan int)); err == nil {
+		t.Error("EstimateSize() of an unsupported type expected an error")
+	}
+	if _, err := EstimateSize(1.0, FloatPrecision{Precision: -1}); err == nil {
+		t.Error("EstimateSize() with an invalid option expected an error")
+	}
+}
+
+func TestMarshalTrailingNewline(t *testing.T) {
+	tests := []struct {
+		name  string
+		input any
+		opts  []any
+		want  string
+	}{
+		{name: "default", input: map[string]int{"a": 1}, want: `{"a":1}`},
+		{name: "object", input: map[string]int{"a": 1}, opts: []any{TrailingNewline{}}, want: "{\"a\":1}\n"},
+		{name: "scalar", input: "s", opts: []any{TrailingNewline{}}, want: "\"s\"\n"},
+		{name: "nested values unaffected", input: []any{[]int{1}, map[string]any{}}, opts: []any{TrailingNewline{}}, want: "[[1],{}]\n"},
+		{name: "specified twice", input: 1, opts: []any{TrailingNewline{}, TrailingNewline{}}, want: "1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, tt.opts...)
+			if err != nil {
+				t.Fatalf("Marshal() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %q, want %q", got, tt.want)
+			}
+			if n, _ := EstimateSize(tt.input, tt.opts...); n != len(tt.want) {
+				t.Errorf("EstimateSize() = %d, want %d", n, len(tt.want))
+			}
+		})
+	}
+
+	if got, err := Marshal(make(chan int), TrailingNewline{}); err == nil || got != "" {
+		t.Errorf("Marshal() = %q, %v, want an error", got, err)
+	}
+}
+
+func TestWriterBulkMethods(t *testing.T) {
+	tests := []struct {
+		name  string
+		input any
+		opts  []any
+		want  string
+	}{
+		{
+			name: "members keep order",
+			input: func(w ObjectWriter) {
+				w.Members([]KV{{"z", 1}, {"a", []int{2}}, {"m", nil}})
+			},
+			want: `{"z":1,"a":[2],"m":null}`,
+		},
+		{
+			name: "members mixed with member",
+			input: func(w ObjectWriter) {
+				w.Member("first", true)
+				w.Members([]KV{{Key: "second", Value: "x"}})
+				w.Members(nil)
+			},
+			want: `{"first":true,"second":"x"}`,
+		},
+		{
+			name: "members sorted",
+			input: func(w ObjectWriter) {
+				w.Members([]KV{{"z", 1}, {"a", 2}})
+			},
+			opts: []any{SortObjectWriterKeys{}},
+			want: `{"a":2,"z":1}`,
+		},
+		{
+			name: "no members",
+			input: func(w ObjectWriter) {
+				w.Members([]KV{})
+			},
+			want: `{}`,
+		},
+		{
+			name: "elements",
+			input: func(w ArrayWriter) {
+				w.Elements([]any{1, "two", []any{3}})
+				w.Element(4)
+			},
+			want: `[1,"two",[3],4]`,
+		},
+		{
+			name: "no elements",
+			input: func(w ArrayWriter) {
+				w.Elements(nil)
+			},
+			want: `[]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, tt.opts...)
+			if err != nil {
+				t.Fatalf("Marshal() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type valueRecvObj struct{ n int }
+
+func (v valueRecvObj) MarshalJSN(w ObjectWriter) error {
+	w.Member("v", v.n)
+	return nil
+}
+
+type ptrRecvObj struct{ n int }
+
+func (p *ptrRecvObj) MarshalJSN(w ObjectWriter) error {
+	w.Member("p", p.n)
+	return nil
+}
+
+type valueRecvArr struct{ n int }
+
+func (v valueRecvArr) MarshalJSN(w ArrayWriter) error {
+	w.Element(v.n)
+	return nil
+}
+
+type ptrRecvArr struct{ n int }
+
+func (p *ptrRecvArr) MarshalJSN(w ArrayWriter) error {
+	w.Element(p.n)
+	return nil
+}
+
+type valueRecvStr struct{ s string }
+
+func (v valueRecvStr) MarshalJSN() (string, error) { return v.s, nil }
+
+type ptrRecvStr struct{ s string }
+
+func (p *ptrRecvStr) MarshalJSN() (string, error) { return p.s, nil }
+
+type ptrRecvText struct{ s string }
+
+func (p *ptrRecvText) MarshalText() ([]byte, error) { return []byte(p.s), nil }
+
+func TestMarshalReceiverMatrix(t *testing.T) {
+	kinds := []struct {
+		name  string
+		value any // by value
+		ptr   any // by pointer
+		want  string
+	}{
+		{name: "value receiver obj", value: valueRecvObj{1}, ptr: &valueRecvObj{1}, want: `{"v":1}`},
+		{name: "pointer receiver obj", value: ptrRecvObj{2}, ptr: &ptrRecvObj{2}, want: `{"p":2}`},
+		{name: "value receiver arr", value: valueRecvArr{3}, ptr: &valueRecvArr{3}, want: `[3]`},
+		{name: "pointer receiver arr", value: ptrRecvArr{4}, ptr: &ptrRecvArr{4}, want: `[4]`},
+		{name: "value receiver str", value: valueRecvStr{"a"}, ptr: &valueRecvStr{"a"}, want: `"a"`},
+		{name: "pointer receiver str", value: ptrRecvStr{"b"}, ptr: &ptrRecvStr{"b"}, want: `"b"`},
+		{name: "pointer receiver text", value: ptrRecvText{"c"}, ptr: &ptrRecvText{"c"}, want: `"c"`},
+	}
+
+	// wrap places v inside a container of its own dynamic type
+	wraps := []struct {
+		name string
+		wrap func(v any) any
+		want func(s string) string
+	}{
+		{"bare", func(v any) any { return v }, func(s string) string { return s }},
+		{"interface slice", func(v any) any { return []any{v} }, func(s string) string { return "[" + s + "]" }},
+		{"typed slice", func(v any) any {
+			sl := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(v)), 1, 1)
+			sl.Index(0).Set(reflect.ValueOf(v))
+			return sl.Interface()
+		}, func(s string) string { return "[" + s + "]" }},
+		{"typed array", func(v any) any {
+			arr := reflect.New(reflect.ArrayOf(1, reflect.TypeOf(v))).Elem()
+			arr.Index(0).Set(reflect.ValueOf(v))
+			return arr.Interface()
+		}, func(s string) string { return "[" + s + "]" }},
+		{"typed map", func(v any) any {
+			m := reflect.MakeMap(reflect.MapOf(reflect.TypeOf(""), reflect.TypeOf(v)))
+			m.SetMapIndex(reflect.ValueOf("k"), reflect.ValueOf(v))
+			return m.Interface()
+		}, func(s string) string { return `{"k":` + s + "}" }},
+	}
+
+	for _, k := range kinds {
+		for _, w := range wraps {
+			for _, byPtr := range []bool{false, true} {
+				v, how := k.value, "by value"
+				if byPtr {
+					v, how = k.ptr, "by pointer"
+				}
+				t.Run(k.name+"/"+w.name+"/"+how, func(t *testing.T) {
+					got, err := Marshal(w.wrap(v))
+					if err != nil {
+						t.Fatalf("Marshal() error = %v", err)
+					}
+					if want := w.want(k.want); got != want {
+						t.Errorf("Marshal() = %s, want %s", got, want)
+					}
+				})
+			}
+		}
+	}
+
+	// nil pointers marshal as a single null regardless of the receiver kind
+	for _, v := range []any{(*valueRecvObj)(nil), (*ptrRecvObj)(nil), (*int)(nil)} {
+		got, err := Marshal(v)
+		if err != nil || got != "null" {
+			t.Errorf("Marshal(%T(nil)) = %q, %v, want null", v, got, err)
+		}
+	}
+}
+
+func TestMarshalOmitNullMembers(t *testing.T) {
+	var nilPtr *int
+	var nilErr error
+	one := 1
+	tests := []struct {
+		name  string
+		input any
+		opts  []any
+		want  string
+	}{
+		{name: "default keeps nulls", input: map[string]any{"a": nil, "b": 1}, want: `{"a":null,"b":1}`},
+		{name: "map", input: map[string]any{"a": nil, "b": 1, "c": nilPtr, "d": nilErr}, opts: []any{OmitNullMembers{}}, want: `{"b":1}`},
+		{name: "typed map", input: map[string]*int{"a": nil, "b": &one}, opts: []any{OmitNullMembers{}}, want: `{"b":1}`},
+		{name: "all null", input: map[string]any{"a": nil}, opts: []any{OmitNullMembers{}}, want: `{}`},
+		{name: "empty values kept", input: map[string]any{"a": []int(nil), "b": "", "c": map[string]int(nil)}, opts: []any{OmitNullMembers{}},
+			want: `{"a":[],"b":"","c":{}}`},
+		{name: "array elements kept", input: []any{nil, map[string]any{"a": nil}}, opts: []any{OmitNullMembers{}}, want: `[null,{}]`},
+		{name: "object writer", input: func(w ObjectWriter) {
+			w.Member("a", nil)
+			w.Member("b", 1)
+			w.Member("c", nilPtr)
+			w.Members([]KV{{"d", nil}, {"e", 2}})
+		}, opts: []any{OmitNullMembers{}}, want: `{"b":1,"e":2}`},
+		{name: "object writer sorted", input: func(w ObjectWriter) {
+			w.Member("b", nil)
+			w.Member("a", 1)
+		}, opts: []any{OmitNullMembers{}, SortObjectWriterKeys{}}, want: `{"a":1}`},
+		{name: "object writer all null", input: func(w ObjectWriter) {
+			w.Member("a", nil)
+		}, opts: []any{OmitNullMembers{}}, want: `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, tt.opts...)
+			if err != nil {
+				t.Fatalf("Marshal() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalEscapeNonPrintable(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "plain", input: "h\u00e9llo", want: "\"h\u00e9llo\""},
+		{name: "control", input: "a\x01\n", want: `"a\u0001\n"`},
+		{name: "DEL", input: "a\x7fb", want: `"a\u007fb"`},
+		{name: "zero-width space", input: "a\u200bb", want: `"a\u200bb"`},
+		{name: "line separators", input: "\u2028\u2029", want: `"\u2028\u2029"`},
+		{name: "no-break space", input: "a\u00a0b", want: `"a\u00a0b"`},
+		{name: "byte order mark", input: "\ufeffx", want: `"\ufeffx"`},
+		{name: "outside BMP", input: "\U000e0001", want: `"\udb40\udc01"`},
+		{name: "emoji kept", input: "\U0001f600", want: "\"\U0001f600\""},
+		{name: "invalid UTF-8 kept", input: "a\xffb", want: "\"a\xffb\""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, EscapeNonPrintable{})
+			if err != nil {
+				t.Fatalf("Marshal() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+			if got := string(AppendString(nil, tt.input, EscapeNonPrintable{})); got != tt.want {
+				t.Errorf("AppendString() = %s, want %s", got, tt.want)
+			}
+			if !utf8.ValidString(tt.input) {
+				return
+			}
+			if v, err := Parse([]byte(got)); err != nil || v != tt.input {
+				t.Errorf("Parse() = %q, %v, want %q", v, err, tt.input)
+			}
+		})
+	}
+
+	// keys are escaped too, the default output is unchanged
+	m := map[string]any{"k\u200b": "\x7f"}
+	if got, _ := Marshal(m, EscapeNonPrintable{}); got != `{"k\u200b":"\u007f"}` {
+		t.Errorf("Marshal() = %s", got)
+	}
+	if got, _ := Marshal(m); got != "{\"k\u200b\":\"\x7f\"}" {
+		t.Errorf("Marshal() = %s", got)
+	}
+}
+
+func TestMarshalEscapeNonASCII(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "ascii", input: "plain \"text\"\n", want: `"plain \"text\"\n"`},
+		{name: "latin", input: "h\u00e9llo", want: `"h\u00e9llo"`},
+		{name: "last of the BMP", input: "\uffef", want: `"\uffef"`},
+		{name: "G clef", input: "\U0001d11e", want: `"\ud834\udd1e"`},
+		{name: "emoji", input: "\U0001f600", want: `"\ud83d\ude00"`},
+		{name: "first outside the BMP", input: "\U00010000", want: `"\ud800\udc00"`},
+		{name: "last code point", input: "\U0010ffff", want: `"\udbff\udfff"`},
+		{name: "mixed", input: "a\U0001d11eb\u00e9", want: `"a\ud834\udd1eb\u00e9"`},
+		{name: "invalid UTF-8", input: "a\xffb", want: `"a\ufffdb"`},
+		{name: "DEL kept", input: "\x7f", want: "\"\x7f\""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, EscapeNonASCII{})
+			if err != nil {
+				t.Fatalf("Marshal() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+			if q := Quote(tt.input, EscapeNonASCII{}); q != tt.want {
+				t.Errorf("Quote() = %s, want %s", q, tt.want)
+			}
+			if !utf8.ValidString(tt.input) {
+				return
+			}
+			// the surrogate pairs decode back to the original code points
+			if v, err := Parse([]byte(got)); err != nil || v != tt.input {
+				t.Errorf("Parse() = %q, %v, want %q", v, err, tt.input)
+			}
+		})
+	}
+
+	m := map[string]any{"\U0001f600": "\x7f"}
+	if got, _ := Marshal(m, EscapeNonASCII{}, EscapeNonPrintable{}); got != `{"\ud83d\ude00":"\u007f"}` {
+		t.Errorf("Marshal() = %s", got)
+	}
+}
+
+func TestMarshalContext(t *testing.T) {
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "v")
+
+	// the context is available to callbacks
+	got, err := MarshalContext(ctx, func(w ObjectWriter) {
+		w.Member("a", func(w ArrayWriter) { w.Element(w.Context().Value(ctxKey{})) })
+		w.Member("b", w.Context().Value(ctxKey{}))
+	})
+	if err != nil || got != `{"a":["v"],"b":"v"}` {
+		t.Errorf("MarshalContext() = %s, %v", got, err)
+	}
+	if got, err := Marshal(func(w ObjectWriter) { w.Member("ok", w.Context() != nil) }); err != nil || got != `{"ok":true}` {
+		t.Errorf("Marshal() = %s, %v", got, err)
+	}
+
+	// canceling stops the output between members
+	ctx, cancel := context.WithCancel(context.Background())
+	rows := 0
+	_, err = MarshalContext(ctx, func(w ArrayWriter) error {
+		for i := 0; i < 100; i++ {
+			if err := w.Context().Err(); err != nil {
+				return err
+			}
+			rows++
+			if i == 2 {
+				cancel()
+			}
+			w.Element(i)
+		}
+		return nil
+	})
+	if err != context.Canceled || rows != 3 {
+		t.Errorf("MarshalContext() error = %v after %d rows, want %v after 3", err, rows, context.Canceled)
+	}
+
+	// values without callbacks are checked as well
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	for _, v := range []any{[]int{1, 2}, map[string]int{"a": 1}, func(w ObjectWriter) { w.Member("a", 1) }} {
+		if _, err := MarshalContext(ctx, v); err != context.Canceled {
+			t.Errorf("MarshalContext(%T) error = %v, want %v", v, err, context.Canceled)
+		}
+	}
+	if got, err := MarshalContext(ctx, 1); err != nil || got != "1" {
+		t.Errorf("MarshalContext() = %s, %v, want 1", got, err)
+	}
+}
+
+func TestMarshalDurationAndAtomics(t *testing.T) {
+	var (
+		i64  atomic.Int64
+		u32  atomic.Uint32
+		b    atomic.Bool
+		v    atomic.Value
+		nilv atomic.Value
+		p    atomic.Pointer[string]
+		nilp atomic.Pointer[string]
+		arr  [2]atomic.Int32
+	)
+	i64.Store(-7)
+	u32.Store(7)
+	b.Store(true)
+	v.Store(map[string]int{"x": 1})
+	str := "s"
+	p.Store(&str)
+	arr[1].Store(3)
+	d := 90 * time.Minute
+
+	tests := []struct {
+		name  string
+		input any
+		opts  []any
+		want  string
+	}{
+		{name: "duration as nanoseconds", input: d, want: `5400000000000`},
+		{name: "duration as string", input: d, opts: []any{DurationAsString{}}, want: `"1h30m0s"`},
+		{name: "duration pointer", input: &d, opts: []any{DurationAsString{}}, want: `"1h30m0s"`},
+		{name: "durations in containers", input: map[string]any{"a": []time.Duration{time.Second, 1500 * time.Millisecond}},
+			opts: []any{DurationAsString{}}, want: `{"a":["1s","1.5s"]}`},
+		{name: "int64 unaffected", input: int64(5), opts: []any{DurationAsString{}}, want: `5`},
+		{name: "atomic.Int64", input: &i64, want: `-7`},
+		{name: "atomic.Uint32", input: &u32, want: `7`},
+		{name: "atomic.Bool", input: &b, want: `true`},
+		{name: "atomic.Value", input: &v, want: `{"x":1}`},
+		{name: "empty atomic.Value", input: &nilv, want: `null`},
+		{name: "atomic.Pointer", input: &p, want: `"s"`},
+		{name: "nil atomic.Pointer", input: &nilp, want: `null`},
+		{name: "atomics in containers", input: []any{&i64, map[string]*atomic.Bool{"b": &b}}, want: `[-7,{"b":true}]`},
+		// obtained through reflection, as vet rejects copying atomics directly
+		{name: "atomics by value", input: reflect.ValueOf(&arr).Elem().Interface(), want: `[0,3]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, tt.opts...)
+			if err != nil {
+				t.Fatalf("Marshal() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalUnixTime(t *testing.T) {
+	ts := time.Unix(1700000000, 250000000)
+	tests := []struct {
+		name  string
+		input any
+		opts  []any
+		want  string
+	}{
+		{name: "default", input: time.Unix(0, 0).UTC(), want: `"1970-01-01T00:00:00Z"`},
+		{name: "seconds", input: time.Unix(1700000000, 0), opts: []any{UnixTime{}}, want: `1700000000`},
+		{name: "fractional seconds", input: ts, opts: []any{UnixTime{}}, want: `1700000000.25`},
+		{name: "nanoseconds", input: time.Unix(1, 1), opts: []any{UnixTime{}}, want: `1.000000001`},
+		{name: "millis", input: ts, opts: []any{UnixTime{Millis: true}}, want: `1700000000250`},
+		{name: "fractional millis", input: time.Unix(0, 1500), opts: []any{UnixTime{Millis: true}}, want: `0.0015`},
+		{name: "before epoch", input: time.Unix(-2, 500000000), opts: []any{UnixTime{}}, want: `-1.5`},
+		{name: "just before epoch", input: time.Unix(-1, 750000000), opts: []any{UnixTime{}}, want: `-0.25`},
+		{name: "millis before epoch", input: time.Unix(0, -1500), opts: []any{UnixTime{Millis: true}}, want: `-0.0015`},
+		{name: "pointer in container", input: map[string]any{"t": &ts}, opts: []any{UnixTime{}}, want: `{"t":1700000000.25}`},
+		{name: "as string", input: ts, opts: []any{UnixTime{}, NumbersAsStrings{}}, want: `"1700000000.25"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, tt.opts...)
+			if err != nil {
+				t.Fatalf("Marshal() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalComplexAsObject(t *testing.T) {
+	tests := []struct {
+		name  string
+		input any
+		opts  []any
+		want  string
+	}{
+		{name: "complex128", input: complex(1.5, -2), want: `{"real":1.5,"imag":-2}`},
+		{name: "complex64", input: complex64(complex(0.25, 4)), want: `{"real":0.25,"imag":4}`},
+		{name: "precision", input: complex(1.0/3, 2.0/3), opts: []any{FloatPrecision{Precision: 2}}, want: `{"real":0.33,"imag":0.67}`},
+		{name: "in containers", input: map[string]any{"z": []complex128{0}}, want: `{"z":[{"real":0,"imag":0}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, append(tt.opts, ComplexAsObject{})...)
+			if err != nil {
+				t.Fatalf("Marshal() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalIndent(t *testing.T) {
+	nested := map[string]any{"a": []any{1, map[string]any{}}, "b": []any{}, "c": map[string]any{"d": "e"}}
+	sorted := func(w ObjectWriter) {
+		w.Member("y", []int{1})
+		w.Member("x", map[string]int{"z": 2})
+	}
+
+	tests := []struct {
+		name  string
+		input any
+		opts  []any
+		want  string
+	}{
+		{name: "scalar", input: 1, opts: []any{Indent("  ")}, want: "1"},
+		{name: "empty containers", input: []any{}, opts: []any{Indent("  ")}, want: "[]"},
+		{name: "nested", input: nested, opts: []any{Indent("  ")},
+			want: "{\n  \"a\": [\n    1,\n    {}\n  ],\n  \"b\": [],\n  \"c\": {\n    \"d\": \"e\"\n  }\n}"},
+		{name: "tabs", input: []any{[]any{true}}, opts: []any{Indent("\t")}, want: "[\n\t[\n\t\ttrue\n\t]\n]"},
+		{name: "indent func", input: map[string]any{"a": []int{1}},
+			opts: []any{IndentFunc(func(depth int) string { return strings.Repeat(">", depth) })},
+			want: "{\n>\"a\": [\n>>1\n>]\n}"},
+		{name: "indent func overrides indent", input: []int{1},
+			opts: []any{IndentFunc(func(depth int) string { return strconv.Itoa(depth) }), Indent("  ")},
+			want: "[\n11\n0]"},
+		{name: "sorted members", input: sorted, opts: []any{Indent(" "), SortObjectWriterKeys{}},
+			want: "{\n \"x\": {\n  \"z\": 2\n },\n \"y\": [\n  1\n ]\n}"},
+		{name: "trailing newline", input: []int{1}, opts: []any{Indent(" "), TrailingNewline{}}, want: "[\n 1\n]\n"},
+		{name: "json marshaler", input: stdPoint{1, 2}, opts: []any{Indent(" ")}, want: "{\n \"x\": 1,\n \"y\": 2\n}"},
+		{name: "trailing commas", input: nested, opts: []any{Indent("  "), TrailingCommas{}},
+			want: "{\n  \"a\": [\n    1,\n    {},\n  ],\n  \"b\": [],\n  \"c\": {\n    \"d\": \"e\",\n  },\n}"},
+		{name: "trailing commas sorted", input: sorted, opts: []any{Indent(" "), SortObjectWriterKeys{}, TrailingCommas{}},
+			want: "{\n \"x\": {\n  \"z\": 2,\n },\n \"y\": [\n  1,\n ],\n}"},
+		{name: "trailing commas compact", input: nested, opts: []any{TrailingCommas{}},
+			want: `{"a":[1,{}],"b":[],"c":{"d":"e"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, tt.opts...)
+			if err != nil {
+				t.Fatalf("Marshal() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	got, err := MarshalIndent(map[string]any{"a": 1}, "\t")
+	if err != nil || got != "{\n\t\"a\": 1\n}" {
+		t.Errorf("MarshalIndent() = %q, %v", got, err)
+	}
+}
+
+// naturalLess orders keys with a common prefix by their numeric suffix
+func naturalLess(a, b string) bool {
+	ta, tb := strings.TrimLeft(a, "abcdefghijklmnopqrstuvwxyz"), strings.TrimLeft(b, "abcdefghijklmnopqrstuvwxyz")
+	pa, pb := a[:len(a)-len(ta)], b[:len(b)-len(tb)]
+	if pa != pb {
+		return pa < pb
+	}
+	na, _ := strconv.Atoi(ta)
+	nb, _ := strconv.Atoi(tb)
+	return na < nb
+}
+
+func TestMarshalKeyComparator(t *testing.T) {
+	m := map[string]int{"item10": 10, "item2": 2, "item1": 1, "a": 0}
+	members := func(w ObjectWriter) {
+		w.Member("x10", 1)
+		w.Member("x9", 2)
+		w.Member("x09", 3) // equal to x9, kept in call order
+	}
+
+	tests := []struct {
+		name  string
+		input any
+		opts  []any
+		want  string
+	}{
+		{name: "map default", input: m, want: `{"a":0,"item1":1,"item10":10,"item2":2}`},
+		{name: "map natural", input: m, opts: []any{KeyComparator(naturalLess)}, want: `{"a":0,"item1":1,"item2":2,"item10":10}`},
+		{name: "object writer natural", input: members, opts: []any{SortObjectWriterKeys{}, KeyComparator(naturalLess)},
+			want: `{"x9":2,"x09":3,"x10":1}`},
+		{name: "object writer unsorted", input: members, opts: []any{KeyComparator(naturalLess)}, want: `{"x10":1,"x9":2,"x09":3}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, tt.opts...)
+			if err != nil {
+				t.Fatalf("Marshal() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalNumbersAsStrings(t *testing.T) {
+	type id uint64
+	tests := []struct {
+		name  string
+		input any
+		opts  []any
+		want  string
+	}{
+		{name: "integers", input: []any{int8(-1), 1 << 62, uint64(math.MaxUint64), id(7)},
+			want: `["-1","4611686018427387904","18446744073709551615","7"]`},
+		{name: "floats", input: []any{1.5, float32(0.25), 1e21}, want: `["1.5","0.25","1e+21"]`},
+		{name: "float precision", input: math.Pi, opts: []any{FloatPrecision{Precision: 3}}, want: `"3.14"`},
+		{name: "integral floats", input: 1e15, opts: []any{IntegralFloats{}}, want: `"1000000000000000"`},
+		{name: "precise", input: Precise{Value: math.Pi, Precision: 2}, want: `"3.1"`},
+		{name: "number", input: Number("1.50"), opts: []any{PreserveNumbers{}}, want: `"1.50"`},
+		{name: "duration", input: time.Second, want: `"1000000000"`},
+		{name: "complex", input: complex(1, -2), opts: []any{ComplexAsObject{}}, want: `{"real":"1","imag":"-2"}`},
+		{name: "map values only", input: map[string]int{"1": 1}, want: `{"1":"1"}`},
+		{name: "non-finite", input: []float64{math.Inf(1), math.NaN()}, opts: []any{NonFiniteFloats{Mode: NonFiniteString}},
+			want: `["Infinity","NaN"]`},
+		{name: "other values", input: []any{"s", true, nil}, want: `["s",true,null]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, append([]any{NumbersAsStrings{}}, tt.opts...)...)
+			if err != nil {
+				t.Fatalf("Marshal() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	var sb strings.Builder
+	if err := CopyValue(&sb, NewScanner([]byte(`{"a":[1,-2.5e3]}`)), NumbersAsStrings{}); err != nil {
+		t.Fatalf("CopyValue() unexpected error = %v", err)
+	}
+	if want := `{"a":["1","-2.5e3"]}`; sb.String() != want {
+		t.Errorf("CopyValue() = %s, want %s", sb.String(), want)
+	}
+}
+
+func TestMarshalQuoted(t *testing.T) {
+	n := 42
+	var nilPtr *int
+	tests := []struct {
+		name    string
+		input   any
+		opts    []any
+		want    string
+		wantErr bool
+	}{
+		{name: "int64 beyond 2^53", input: Quoted{int64(1<<53 + 1)}, want: `"9007199254740993"`},
+		{name: "float", input: Quoted{2.5}, want: `"2.5"`},
+		{name: "float precision", input: Quoted{math.Pi}, opts: []any{FloatPrecision{Precision: 3}}, want: `"3.14"`},
+		{name: "precise", input: Quoted{Precise{Value: math.Pi, Precision: 2}}, want: `"3.1"`},
+		{name: "number", input: Quoted{Number("1.50")}, opts: []any{PreserveNumbers{}}, want: `"1.50"`},
+		{name: "bools", input: []any{Quoted{true}, Quoted{false}}, want: `["true","false"]`},
+		{name: "pointer", input: Quoted{&n}, want: `"42"`},
+		{name: "nil", input: []any{Quoted{}, Quoted{nilPtr}}, want: `[null,null]`},
+		{name: "others unaffected", input: []any{Quoted{1}, 2}, want: `["1",2]`},
+		{name: "string", input: Quoted{"x"}, wantErr: true},
+		{name: "array", input: Quoted{[]int{1}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Marshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalNonFiniteFloats(t *testing.T) {
+	input := []any{math.Inf(1), math.Inf(-1), math.NaN(), float32(math.Inf(1)), Precise{Value: math.NaN(), Precision: 2}}
+
+	tests := []struct {
+		name    string
+		opts    []any
+		want    string
+		wantErr bool
+	}{
+		{name: "default", wantErr: true},
+		{name: "error", opts: []any{NonFiniteFloats{Mode: NonFiniteError}}, wantErr: true},
+		{name: "null", opts: []any{NonFiniteFloats{Mode: NonFiniteNull}}, want: `[null,null,null,null,null]`},
+		{name: "string", opts: []any{NonFiniteFloats{Mode: NonFiniteString}},
+			want: `["Infinity","-Infinity","NaN","Infinity","NaN"]`},
+		{name: "literal", opts: []any{NonFiniteFloats{Mode: NonFiniteLiteral}}, want: `[Infinity,-Infinity,NaN,Infinity,NaN]`},
+		{name: "invalid mode", opts: []any{NonFiniteFloats{Mode: 7}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(input, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Marshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// finite values are unaffected
+	if got, err := Marshal(1.5, NonFiniteFloats{Mode: NonFiniteNull}); err != nil || got != "1.5" {
+		t.Errorf("Marshal() = %s, %v, want 1.5", got, err)
+	}
+}
+
+func TestMarshalByteKeys(t *testing.T) {
+	type hash [2]byte
+	m := map[hash]int{{0xff, 0x00}: 1, {0x00, 0x01}: 2}
+
+	tests := []struct {
+		name    string
+		input   any
+		opts    []any
+		want    string
+		wantErr bool
+	}{
+		{name: "hex", input: m, opts: []any{ByteKeys{}}, want: `{"0001":2,"ff00":1}`},
+		{name: "base64 sorted by encoding", input: m, opts: []any{ByteKeys{Encoding: ByteKeysBase64}}, want: `{"/wA=":1,"AAE=":2}`},
+		{name: "empty array key", input: map[[0]byte]int{{}: 1}, opts: []any{ByteKeys{}}, want: `{"":1}`},
+		{name: "without option", input: m, wantErr: true},
+		{name: "invalid encoding", input: m, opts: []any{ByteKeys{Encoding: 5}}, wantErr: true},
+		{name: "other array keys", input: map[[2]int]int{{1, 2}: 1}, opts: []any{ByteKeys{}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Marshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalPrecise(t *testing.T) {
+	reading := func(w ObjectWriter) {
+		w.Member("display", 3.14159265)
+		w.Member("exact", Precise{Value: 3.14159265, Precision: -1})
+		w.Member("coarse", Precise{Value: 3.14159265, Precision: 2})
+	}
+
+	tests := []struct {
+		name  string
+		input any
+		opts  []any
+		want  string
+	}{
+		{name: "default precision", input: reading, want: `{"display":3.14159,"exact":3.14159265,"coarse":3.1}`},
+		{name: "global precision", input: reading, opts: []any{FloatPrecision{Precision: 3}}, want: `{"display":3.14,"exact":3.14159265,"coarse":3.1}`},
+		{name: "preserve numbers", input: reading, opts: []any{PreserveNumbers{}}, want: `{"display":3.14159265,"exact":3.14159265,"coarse":3.1}`},
+		{name: "in containers", input: []any{Precise{Value: 1.0 / 3, Precision: 3}, 1.0 / 3, &Precise{Value: 2.0 / 3, Precision: 1}},
+			want: `[0.333,0.333333,0.7]`},
+		{name: "integral floats", input: Precise{Value: 1e6, Precision: 2}, opts: []any{IntegralFloats{}}, want: `1000000`},
+		{name: "large value", input: Precise{Value: 123456789, Precision: 4}, want: `1.235e+08`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, tt.opts...)
+			if err != nil {
+				t.Fatalf("Marshal() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Marshal(Precise{Value: math.Inf(1)}); err == nil {
+		t.Error("Marshal() expected error for infinite Precise value, got nil")
+	}
+}
+
+type textPart struct{}
+
+func (textPart) MarshalText() ([]byte, error) { return []byte("text"), nil }
+
+type strPart struct{}
+
+func (strPart) MarshalJSN() (string, error) { return "str", nil }
+
+type objPart struct{}
+
+func (objPart) MarshalJSN(w ObjectWriter) error {
+	w.Member("obj", true)
+	return nil
+}
+
+// textWithPtrObj is a TextMarshaler by value and an ObjMarshaler by pointer
+type textWithPtrObj struct{}
+
+func (textWithPtrObj) MarshalText() ([]byte, error) { return []byte("text"), nil }
+
+func (*textWithPtrObj) MarshalJSN(w ObjectWriter) error {
+	w.Member("obj", true)
+	return nil
+}
+
+func TestMarshalMarshalerPrecedence(t *testing.T) {
+	type strAndText struct {
+		strPart
+		textPart
+	}
+	type objAndText struct {
+		textPart
+		*objPart
+	}
+	// the MarshalJSN methods of both conflict at the same depth, so neither
+	// is promoted and the type implements no marshaler at all
+	type objAndStr struct {
+		objPart
+		strPart
+	}
+	// at different depths the shallower MarshalJSN wins, as per Go's rules:
+	// objPart's is promoted from depth 1, hiding strPart's from depth 2
+	type strWrapper struct{ strPart }
+	type objOverStr struct {
+		objPart
+		strWrapper
+	}
+
+	tests := []struct {
+		name    string
+		input   any
+		want    string
+		wantErr bool
+	}{
+		{name: "str over text", input: strAndText{}, want: `"str"`},
+		{name: "obj over text", input: objAndText{objPart: &objPart{}}, want: `{"obj":true}`},
+		{name: "pointer obj over value text", input: textWithPtrObj{}, want: `{"obj":true}`},
+		{name: "pointer obj over value text, by pointer", input: &textWithPtrObj{}, want: `{"obj":true}`},
+		{name: "pointer obj over value text, in slice", input: []textWithPtrObj{{}}, want: `[{"obj":true}]`},
+		{name: "ambiguous", input: objAndStr{}, wantErr: true},
+		{name: "shallower", input: objOverStr{}, want: `{"obj":true}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Marshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+type stdPoint struct{ x, y int }
+
+func (p stdPoint) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`{ "x": %d, "y": %d }`, p.x, p.y)), nil
+}
+
+type stdPtrRecv struct{ s string }
+
+func (p *stdPtrRecv) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + p.s + `"`), nil
+}
+
+type stdInvalid struct{ out string }
+
+func (v stdInvalid) MarshalJSON() ([]byte, error) { return []byte(v.out), nil }
+
+type stdFailing struct{}
+
+func (stdFailing) MarshalJSON() ([]byte, error) { return nil, errors.New("failed") }
+
+// bothMarshalers implements MarshalJSN and MarshalJSON
+type bothMarshalers struct{}
+
+func (bothMarshalers) MarshalJSN() (string, error)  { return "jsn", nil }
+func (bothMarshalers) MarshalJSON() ([]byte, error) { return []byte(`"json"`), nil }
+
+// jsonAndText implements json.Marshaler and encoding.TextMarshaler
+type jsonAndText struct{}
+
+func (jsonAndText) MarshalJSON() ([]byte, error) { return []byte(`["json"]`), nil }
+func (jsonAndText) MarshalText() ([]byte, error) { return []byte("text"), nil }
+
+func TestMarshalJSONMarshaler(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   any
+		opts    []any
+		want    string
+		wantErr bool
+	}{
+		{name: "compacted", input: stdPoint{1, 2}, want: `{"x":1,"y":2}`},
+		{name: "pointer receiver", input: stdPtrRecv{"a"}, want: `"a"`},
+		{name: "raw message", input: json.RawMessage(`[1.50, "A"]`), want: `[1.50,"A"]`},
+		{name: "MarshalJSN wins", input: bothMarshalers{}, want: `"jsn"`},
+		{name: "MarshalJSON over MarshalText", input: jsonAndText{}, want: `["json"]`},
+		{name: "mixed", input: map[string]any{
+			"std":  stdPoint{3, 4},
+			"jsn":  customObjMarshaler{name: "n", value: 5},
+			"list": []any{&stdPtrRecv{"b"}, customArrMarshaler{values: []int{6}}, json.RawMessage(`null`)},
+		}, want: `{"jsn":{"name":"n","value":5},"list":["b",[6],null],"std":{"x":3,"y":4}}`},
+		{name: "options applied", input: func(w ObjectWriter) {
+			w.Member("s", json.RawMessage("\"\\u00e9\u200b\""))
+		}, opts: []any{EscapeNonPrintable{}}, want: "{\"s\":\"\u00e9\\u200b\"}"},
+		{name: "invalid output", input: stdInvalid{`{"a":}`}, wantErr: true},
+		{name: "trailing data", input: stdInvalid{`1 2`}, wantErr: true},
+		{name: "empty output", input: stdInvalid{``}, wantErr: true},
+		{name: "error", input: []any{stdFailing{}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.input, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Marshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Marshal(stdInvalid{`[`}); !errors.Is(err, ErrUnexpectedEOF) {
+		t.Errorf("Marshal() error = %v, want it to wrap %v", err, ErrUnexpectedEOF)
+	}
+}